@@ -1,7 +1,46 @@
 package db
 
+import "time"
+
 // KVStore represents the database schema
 type KVStore struct {
 	Key   string `gorm:"primaryKey;uniqueIndex"`
 	Value any    `gorm:"serializer:json"`
 }
+
+// SMSMessage is a text message in the inbox or outbox
+type SMSMessage struct {
+	ID        uint   `gorm:"primaryKey"`
+	Number    string // Sender for received messages, recipient for sent ones
+	Body      string
+	Timestamp time.Time `gorm:"index"`
+	Outgoing  bool      `gorm:"index"`
+	Read      bool
+}
+
+// CallLog is an entry in the call register
+type CallLog struct {
+	ID        uint `gorm:"primaryKey"`
+	Number    string
+	Inbound   bool      `gorm:"index"`
+	Answered  bool      `gorm:"index"`
+	HungUp    bool      // Inbound calls only, set when rejected on this phone
+	StartTime time.Time `gorm:"index"`
+	Duration  time.Duration
+	Seen      bool // Missed calls only, cleared once the missed list is opened
+}
+
+// Contact is a phonebook entry
+type Contact struct {
+	ID       uint   `gorm:"primaryKey"`
+	Name     string `gorm:"index"`
+	Ringtone string // One of misc.RingtoneNames, empty for the default
+	Numbers  []ContactNumber
+}
+
+// ContactNumber is one of a contact's phone numbers
+type ContactNumber struct {
+	ID        uint `gorm:"primaryKey"`
+	ContactID uint `gorm:"index"`
+	Number    string
+}
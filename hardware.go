@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+
+	"keypad"
+	"misc"
+	"phone"
+	"sh1107"
+	"tones"
+)
+
+// Hardware bundles the peripherals the OS talks to. Modem is where the modem
+// is plugged in, which may not have anything there yet.
+type Hardware struct {
+	Display   *sh1107.SH1107
+	Keypad    keypad.KeypadSource
+	Player    tones.TonePlayer
+	Backlight misc.Backlight
+	Modem     phone.Device
+	onClose   func()
+}
+
+// Close releases the display and anything else opened with the hardware.
+func (hw *Hardware) Close() {
+	hw.Display.Close()
+	if hw.onClose != nil {
+		hw.onClose()
+	}
+}
+
+// Opens the modem used when running headless. Setting RAKIAN_SCENARIO to a
+// scenario file (see phone.ScenarioStep) runs the AT command simulator behind
+// a real pty, which can be reopened like the USB port; otherwise every command
+// is simply answered with OK.
+func openVirtualModem(debug bool) (phone.Device, func(), error) {
+	path := os.Getenv("RAKIAN_SCENARIO")
+	if path == "" {
+		return phone.StaticDevice(phone.NewMemoryTransport()), nil, nil
+	}
+
+	steps, err := phone.LoadScenario(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load scenario: %w", err)
+	}
+
+	sim, err := phone.NewSimulator(debug)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		if err := sim.Play(ctx, steps); err != nil && err != context.Canceled {
+			log.Println("⚠️ Scenario stopped:", err)
+		} else if err == nil {
+			log.Println("🧪 Scenario finished")
+		}
+	}()
+
+	log.Println("🧪 Running modem scenario", path, "on", sim.Port())
+	return phone.SerialDevice{Path: sim.Port(), Baud: 115200}, func() {
+		cancel()
+		sim.Close()
+	}, nil
+}
@@ -0,0 +1,61 @@
+//go:build !emulator
+
+package main
+
+import (
+	"fmt"
+
+	"keypad"
+	"misc"
+	"phone"
+	"sh1107"
+	"tones"
+)
+
+// Set in the emulator build, which always runs headless
+const emulated = false
+
+// openHardware opens the peripherals on the device, or their in-memory
+// stand-ins when running headless.
+func openHardware(headless bool, debug bool) (*Hardware, error) {
+	if headless {
+		modem, closeModem, err := openVirtualModem(debug)
+		if err != nil {
+			return nil, err
+		}
+
+		return &Hardware{
+			Display:   sh1107.NewWithDisplay(sh1107.NewMemoryDisplay(128, 128), sh1107.UpsideDown, 128, 128),
+			Keypad:    keypad.NewMemory(),
+			Player:    tones.NewSilent(),
+			Backlight: misc.NewMemoryBacklight(),
+			Modem:     modem,
+			onClose:   closeModem,
+		}, nil
+	}
+
+	display, err := sh1107.New(0x3c, 0, sh1107.UpsideDown, 128, 128)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open display: %w", err)
+	}
+
+	player, err := tones.New()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open tone player: %w", err)
+	}
+
+	backlight, err := misc.NewGPIOBacklight()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open key lights: %w", err)
+	}
+
+	// A missing modem isn't fatal, the phone just has no service until it
+	// shows up
+	return &Hardware{
+		Display:   display,
+		Keypad:    keypad.NewGPIO(debug),
+		Player:    player,
+		Backlight: backlight,
+		Modem:     phone.SerialDevice{Path: "/dev/ttyUSB2", Baud: 115200},
+	}, nil
+}
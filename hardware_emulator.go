@@ -0,0 +1,166 @@
+//go:build emulator
+
+package main
+
+// Desktop emulator build, see the emulate script. The screen is drawn to the
+// terminal with block characters, or written as a PNG to RAKIAN_PNG (scaled
+// by RAKIAN_SCALE) when it is set. Keys are read from stdin.
+
+import (
+	"fmt"
+	"image"
+	"image/png"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/sys/unix"
+
+	"keypad"
+	"misc"
+	"sh1107"
+	"tones"
+)
+
+// The emulator always runs headless
+const emulated = true
+
+func openHardware(headless bool, debug bool) (*Hardware, error) {
+	var on_frame func(frame *image.Gray, on bool)
+
+	if path := os.Getenv("RAKIAN_PNG"); path != "" {
+		scale := 4
+		if val, err := strconv.Atoi(os.Getenv("RAKIAN_SCALE")); err == nil && val > 0 {
+			scale = val
+		}
+		on_frame = func(frame *image.Gray, on bool) {
+			if err := writeFramePNG(path, frame, on, scale); err != nil {
+				log.Println("⚠️ Failed to write frame:", err)
+			}
+		}
+	} else {
+		// Keep log output from scrolling the screen away
+		if f, err := os.OpenFile("emulator.log", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644); err == nil {
+			log.SetOutput(f)
+		}
+		terminal := &terminalScreen{}
+		on_frame = terminal.draw
+	}
+
+	modem, closeModem, err := openVirtualModem(debug)
+	if err != nil {
+		return nil, err
+	}
+
+	// Read single keystrokes without waiting for Enter
+	restore := rawTerminal(int(os.Stdin.Fd()))
+
+	return &Hardware{
+		Display:   sh1107.NewWithDisplay(sh1107.NewVirtualDisplay(128, 128, on_frame), sh1107.UpsideDown, 128, 128),
+		Keypad:    keypad.NewTerminal(os.Stdin, debug),
+		Player:    tones.NewSilent(),
+		Backlight: misc.NewMemoryBacklight(),
+		Modem:     modem,
+		onClose: func() {
+			restore()
+			if closeModem != nil {
+				closeModem()
+			}
+		},
+	}, nil
+}
+
+// Puts the terminal into non-canonical mode with echo off, and returns a
+// function that puts it back. Does nothing if stdin isn't a terminal.
+func rawTerminal(fd int) func() {
+	saved, err := unix.IoctlGetTermios(fd, unix.TCGETS)
+	if err != nil {
+		return func() {}
+	}
+
+	raw := *saved
+	raw.Lflag &^= unix.ICANON | unix.ECHO
+	raw.Cc[unix.VMIN] = 1
+	raw.Cc[unix.VTIME] = 0
+	if err := unix.IoctlSetTermios(fd, unix.TCSETS, &raw); err != nil {
+		return func() {}
+	}
+
+	fmt.Print("\x1b[?25l\x1b[2J") // Hide cursor and clear
+	return func() {
+		unix.IoctlSetTermios(fd, unix.TCSETS, saved)
+		fmt.Print("\x1b[?25h\n") // Show cursor
+	}
+}
+
+// Draws frames with half-block characters, two pixel rows per line
+type terminalScreen struct {
+	lock sync.Mutex
+	last string
+}
+
+func (t *terminalScreen) draw(frame *image.Gray, on bool) {
+	bounds := frame.Bounds()
+
+	var sb strings.Builder
+	sb.WriteString("\x1b[H")
+	for y := bounds.Min.Y; y < bounds.Max.Y; y += 2 {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			top := on && frame.GrayAt(x, y).Y > 127
+			bottom := on && frame.GrayAt(x, y+1).Y > 127
+			switch {
+			case top && bottom:
+				sb.WriteRune('█')
+			case top:
+				sb.WriteRune('▀')
+			case bottom:
+				sb.WriteRune('▄')
+			default:
+				sb.WriteRune(' ')
+			}
+		}
+		sb.WriteString("\r\n")
+	}
+	sb.WriteString("[S]elect [C]lear [U]p [D]own [P]ower, L for long press\r\n")
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	// Menus redraw constantly, only repaint when something changed
+	if out := sb.String(); out != t.last {
+		t.last = out
+		os.Stdout.WriteString(out)
+	}
+}
+
+// Writes the frame as a scaled PNG, replacing the file atomically so viewers
+// that watch it never see a half-written image
+func writeFramePNG(path string, frame *image.Gray, on bool, scale int) error {
+	bounds := frame.Bounds()
+	scaled := image.NewGray(image.Rect(0, 0, bounds.Dx()*scale, bounds.Dy()*scale))
+	if on {
+		for y := range scaled.Bounds().Dy() {
+			for x := range scaled.Bounds().Dx() {
+				scaled.SetGray(x, y, frame.GrayAt(bounds.Min.X+x/scale, bounds.Min.Y+y/scale))
+			}
+		}
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".frame-*.png")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := png.Encode(tmp, scaled); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
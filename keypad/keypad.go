@@ -71,12 +71,27 @@ func stop(colPins [5]*PinOut) {
 	}
 }
 
-func Run(ctx context.Context, debug bool) <-chan *KeypadEvent {
+// KeypadSource produces keypad events until the given context is cancelled.
+type KeypadSource interface {
+	Start(ctx context.Context) (<-chan *KeypadEvent, error)
+}
+
+// GPIOKeypad scans the Nokia 5110 key matrix and power button over GPIO.
+type GPIOKeypad struct {
+	debug bool
+}
+
+func NewGPIO(debug bool) *GPIOKeypad {
+	return &GPIOKeypad{debug: debug}
+}
+
+func (k *GPIOKeypad) Start(ctx context.Context) (<-chan *KeypadEvent, error) {
 	eventsChan := make(chan *KeypadEvent, 10)
+	debug := k.debug
 
 	// Must be first
 	if _, err := host.Init(); err != nil {
-		panic(err)
+		return nil, err
 	}
 
 	// Setup GPIOs AFTER host.Init()
@@ -98,27 +113,27 @@ func Run(ctx context.Context, debug bool) <-chan *KeypadEvent {
 
 	// Check for nil pins
 	for i, pin := range rowPins {
-		if pin == nil {
-			panic("⚠️ Row pin " + fmt.Sprint(i) + " not found!")
+		if pin.PinIn == nil {
+			return nil, fmt.Errorf("row pin %d (%s) not found", i, pin.Label)
 		}
 		if err := pin.In(gpio.PullDown, gpio.NoEdge); err != nil {
-			panic("⚠️ Failed to init row " + fmt.Sprint(i) + ": " + err.Error())
+			return nil, fmt.Errorf("failed to init row %d: %w", i, err)
 		}
 	}
 
 	for i, pin := range colPins {
-		if pin == nil {
-			panic("⚠️ Col pin " + fmt.Sprint(i) + " not found!")
+		if pin.PinOut == nil {
+			return nil, fmt.Errorf("col pin %d (%s) not found", i, pin.Label)
 		}
 		if err := pin.Out(gpio.Low); err != nil {
-			panic("⚠️ Failed to init col " + fmt.Sprint(i) + ": " + err.Error())
+			return nil, fmt.Errorf("failed to init col %d: %w", i, err)
 		}
 	}
 
 	// Bind power button
 	powerButton := &PinIn{"GPIO3", gpioreg.ByName("GPIO3")}
-	if powerButton == nil {
-		panic("⚠️ Failed to bind to GPIO3 (Power button)")
+	if powerButton.PinIn == nil {
+		return nil, fmt.Errorf("failed to bind to GPIO3 (Power button)")
 	}
 
 	// Scanner loop
@@ -249,5 +264,5 @@ func Run(ctx context.Context, debug bool) <-chan *KeypadEvent {
 		}
 	}()
 
-	return eventsChan
+	return eventsChan, nil
 }
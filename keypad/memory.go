@@ -0,0 +1,34 @@
+package keypad
+
+import (
+	"context"
+	"time"
+)
+
+// MemoryKeypad is an in-memory KeypadSource. Key presses are injected with
+// Press and Hold instead of being scanned from GPIO.
+type MemoryKeypad struct {
+	events chan *KeypadEvent
+}
+
+func NewMemory() *MemoryKeypad {
+	return &MemoryKeypad{
+		events: make(chan *KeypadEvent, 10),
+	}
+}
+
+func (k *MemoryKeypad) Start(ctx context.Context) (<-chan *KeypadEvent, error) {
+	return k.events, nil
+}
+
+// Press sends a short press and release of the given key.
+func (k *MemoryKeypad) Press(key rune) {
+	k.Hold(key, 100*time.Millisecond)
+}
+
+// Hold sends a press of the given key, followed by a release reporting the
+// given hold duration. It does not block for the duration itself.
+func (k *MemoryKeypad) Hold(key rune, duration time.Duration) {
+	k.events <- &KeypadEvent{State: true, Key: key}
+	k.events <- &KeypadEvent{State: false, Key: key, Duration: duration.Seconds()}
+}
@@ -0,0 +1,109 @@
+package keypad
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"log"
+	"slices"
+	"time"
+
+	"timers"
+)
+
+// How long a key is "held" for, and how long a long press lasts
+const (
+	terminalPressDuration = 50 * time.Millisecond
+	terminalLongDuration  = 1500 * time.Millisecond
+)
+
+// TerminalKeypad turns keystrokes from a terminal into keypad events. Keys are
+// typed as the runes in KeyMap (S, C, U, D, digits, * and #) plus P for the
+// power button, in either case. Enter, Backspace and the up/down arrows also
+// map to S, C, U and D, and typing L makes the next key a long press.
+type TerminalKeypad struct {
+	in    io.Reader
+	debug bool
+}
+
+func NewTerminal(in io.Reader, debug bool) *TerminalKeypad {
+	return &TerminalKeypad{in: in, debug: debug}
+}
+
+func (k *TerminalKeypad) Start(ctx context.Context) (<-chan *KeypadEvent, error) {
+	eventsChan := make(chan *KeypadEvent, 10)
+
+	// Keys the real keypad can produce
+	valid := []rune{'P'}
+	for _, key := range KeyMap {
+		valid = append(valid, key)
+	}
+
+	go func() {
+		reader := bufio.NewReader(k.in)
+		long := false
+		for {
+			r, _, err := reader.ReadRune()
+			if err != nil {
+				if err != io.EOF {
+					log.Println("⚠️ Terminal keypad read error:", err)
+				}
+				return
+			}
+
+			var key rune
+			switch r {
+			case '\r', '\n':
+				key = 'S'
+			case 0x7f, 0x08: // Backspace
+				key = 'C'
+			case 0x1b: // Arrow keys arrive as ESC [ A / ESC [ B
+				if next, _, _ := reader.ReadRune(); next != '[' {
+					continue
+				}
+				switch arrow, _, _ := reader.ReadRune(); arrow {
+				case 'A':
+					key = 'U'
+				case 'B':
+					key = 'D'
+				}
+			case 'l', 'L':
+				long = true
+				continue
+			default:
+				if r >= 'a' && r <= 'z' {
+					r -= 'a' - 'A'
+				}
+				key = r
+			}
+
+			if !slices.Contains(valid, key) {
+				continue
+			}
+
+			duration := terminalPressDuration
+			if long {
+				duration = terminalLongDuration
+				long = false
+			}
+
+			if k.debug {
+				log.Printf("⌨️  Keypress from terminal (%c, %s)", key, duration)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case eventsChan <- &KeypadEvent{State: true, Key: key}:
+			}
+			timers.SleepWithContext(duration, ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case eventsChan <- &KeypadEvent{State: false, Key: key, Duration: duration.Seconds()}:
+			}
+		}
+	}()
+
+	return eventsChan, nil
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"image"
 	"log"
@@ -13,13 +14,11 @@ import (
 	"golang.org/x/sys/unix"
 
 	"db"
-	"keypad"
 	"menu"
 	"misc"
 	"phone"
 	"sh1107"
 	"timers"
-	"tones"
 
 	"github.com/Wifx/gonetworkmanager/v3"
 	"github.com/glebarez/sqlite"
@@ -29,6 +28,10 @@ import (
 
 // go build -ldflags "-X 'main.DEBUG_MODE=false'" .
 var DEBUG_MODE string = "true"
+
+// go build -ldflags "-X 'main.HEADLESS_MODE=true' -X 'main.DATABASE_PATH=kvstore.db'" .
+var HEADLESS_MODE string = "false"
+var DATABASE_PATH string = "/root/rakian/kvstore.db"
 var FW_VERSION string = "0.1.18 (2.17.2026)"
 var EXIT_MODE uint8 = 0 // 0 - none, 1 - shutdown, 2 - reboot, 3 - soft restart
 var SPRITE_LIST = []string{
@@ -52,6 +55,12 @@ var SPRITE_LIST = []string{
 	"battery/10",
 	"battery/unknown",
 
+	// Message sprites
+	"message/unread",
+
+	// Call sprites
+	"call/divert",
+
 	// Cellular network sprites
 	"cell/0",
 	"cell/1",
@@ -138,6 +147,7 @@ func main() {
 	// Handle system exit
 	defer exit()
 	debug := (DEBUG_MODE == "true")
+	headless := (HEADLESS_MODE == "true") || emulated
 
 	// Setup crash logging in deploy mode
 	if !debug {
@@ -162,51 +172,64 @@ func main() {
 	var lastVeryLowBattTime time.Time
 
 	/* Create new instance of gonetworkmanager */
-	nm, err := gonetworkmanager.NewNetworkManager()
-	if err != nil {
-		panic(err)
-	}
-
-	devices, err := nm.GetDevices()
-	if err != nil {
-		panic(err)
-	}
-
-	var wifi_device_raw dbus.ObjectPath
-	for _, device := range devices {
+	var nm gonetworkmanager.NetworkManager
+	var wifi_device gonetworkmanager.DeviceWireless
+	if !headless {
+		var err error
+		nm, err = gonetworkmanager.NewNetworkManager()
+		if err != nil {
+			panic(err)
+		}
 
-		device_interface, err := device.GetPropertyInterface()
+		devices, err := nm.GetDevices()
 		if err != nil {
 			panic(err)
 		}
 
-		if device_interface == "wlan0" {
-			wifi_device_raw = device.GetPath()
-			break
+		var wifi_device_raw dbus.ObjectPath
+		for _, device := range devices {
+
+			device_interface, err := device.GetPropertyInterface()
+			if err != nil {
+				panic(err)
+			}
+
+			if device_interface == "wlan0" {
+				wifi_device_raw = device.GetPath()
+				break
+			}
 		}
-	}
 
-	if wifi_device_raw == "" {
-		panic("No wifi device found")
+		if wifi_device_raw == "" {
+			panic("No wifi device found")
+		}
+
+		wifi_device, err = gonetworkmanager.NewDeviceWireless(wifi_device_raw)
+		if err != nil {
+			panic(err)
+		}
 	}
 
-	wifi_device, err := gonetworkmanager.NewDeviceWireless(wifi_device_raw)
+	// Init db
+	database, err := gorm.Open(sqlite.Open(DATABASE_PATH), &gorm.Config{})
 	if err != nil {
 		panic(err)
 	}
+	database.AutoMigrate(&db.KVStore{}, &db.SMSMessage{}, &db.CallLog{}, &db.Contact{}, &db.ContactNumber{})
 
-	// Init db
-	database, err := gorm.Open(sqlite.Open("/root/rakian/kvstore.db"), &gorm.Config{})
+	// Initialize the hardware
+	hw, err := openHardware(headless, debug)
 	if err != nil {
 		panic(err)
 	}
-	database.AutoMigrate(&db.KVStore{})
-
-	// Initialize the display
-	display := sh1107.New(0x3c, 0, sh1107.UpsideDown, 128, 128)
-	defer display.Close()
-
-	if _, capacity, _, read_err := misc.GetBatteryStatus(); read_err == nil && capacity <= 1 {
+	display := hw.Display
+	backlight := hw.Backlight
+	player := hw.Player
+	defer hw.Close()
+
+	if _, capacity, _, read_err := misc.GetBatteryStatus(); headless {
+		log.Println("🖥️ Running headless, skipping battery check")
+	} else if read_err == nil && capacity <= 1 {
 		alert, err := sh1107.LoadSprite("sprites/battery_needs_charge.bmp")
 		if err != nil {
 			log.Fatalf("⚠️ Failed to load alert image: %v", err)
@@ -241,9 +264,15 @@ func main() {
 	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
 
 	// Initialize components
-	player := tones.New()
-	keypadEvents := keypad.Run(ctx, debug)
-	modem := phone.Run(debug)
+	keypadEvents, err := hw.Keypad.Start(ctx)
+	if err != nil {
+		panic(err)
+	}
+
+	var modem *phone.Modem
+	if hw.Modem != nil {
+		modem = phone.Run(ctx, hw.Modem, debug)
+	}
 
 	// Boot logo
 	logo, err := sh1107.LoadSprite("sprites/logo.bmp")
@@ -260,7 +289,7 @@ func main() {
 
 	draw_logo()
 	display.On()
-	misc.KeyLightsOn()
+	backlight.On()
 
 	// Load sprites
 	sprites := make(map[string]image.Image, len(SPRITE_LIST))
@@ -281,6 +310,7 @@ func main() {
 		sprites,
 		modem,
 		player,
+		backlight,
 		global_quit,
 		keypadEvents,
 		database,
@@ -306,6 +336,15 @@ func main() {
 	menus.Register("selector", menus.NewSelector())
 	menus.Register("settings", menus.NewSettingsMenu())
 	menus.Register("phonebook", menus.NewPhonebookMenu())
+	menus.Register("messages", menus.NewMessagesMenu())
+	menus.Register("call_register", menus.NewCallRegisterMenu())
+	menus.Register("call_divert", menus.NewCallDivertMenu())
+	menus.Register("sim_lock", menus.NewSIMLockMenu())
+	menus.Register("ussd", menus.NewUSSDMenu())
+	menus.Register("alert", menus.NewGenericAlert())
+	menus.Register("emergency", menus.NewEmergencyMenu())
+	menus.Register("cell_info", menus.NewCellInfoMenu())
+	menus.Register("redial", menus.NewRedialMenu())
 
 	// Setup global required keys
 	menus.Set("DebugMode", (debug))
@@ -313,6 +352,21 @@ func main() {
 	menus.CreateOrLoadPersist("CanVibrate", false)
 	menus.CreateOrLoadPersist("CanRing", false)
 	menus.CreateOrLoadPersist("BeepOnly", false)
+	menus.CreateOrLoadPersist("CallDivertActive", false)
+	menus.CreateOrLoadPersist("CellularData", false)
+	menus.CreateOrLoadPersist("APN", "")
+	menus.CreateOrLoadPersist("APNUser", "")
+	menus.CreateOrLoadPersist("APNPassword", "")
+	menus.CreateOrLoadPersist("APNAuth", "None")
+	menus.CreateOrLoadPersist("NetworkMode", "Automatic")
+	menus.CreateOrLoadPersist("ClockMode", menu.ClockNetwork)
+	menus.CreateOrLoadPersist("CellInfoDisplay", false)
+	menus.CreateOrLoadPersist("AutoRedial", "Off")
+	menus.CreateOrLoadPersist("AutoAnswer", "Off")
+	menus.CreateOrLoadPersist("AutoAnswerContactsOnly", false)
+	for _, key := range menu.SpeedDialKeys {
+		menus.CreateOrLoadPersist(menu.SpeedDialSetting(key), "")
+	}
 	menus.Set("InitialKey", ' ')
 	menus.Set("BatteryOK", true)
 	menus.Set("BatteryVoltage", "")
@@ -320,6 +374,8 @@ func main() {
 	menus.Set("BatteryScaledPercent", 0)
 	menus.Set("BatteryCharging", false)
 	menus.Set("BluetoothEnabled", false)
+	menus.UpdateUnreadMessages()
+	menus.UpdateMissedCalls()
 
 	// Load fonts
 	display.Load_Font_Time()
@@ -346,89 +402,204 @@ func main() {
 		time.Sleep(2 * time.Second)
 	}
 
-	// Failsafe
-	enabled, _ := nm.GetPropertyWirelessEnabled()
-	if !enabled {
-		log.Println("WiFi was off, emergency re-enabling...")
-		nm.SetPropertyWirelessEnabled(true)
-		menus.RenderAlert("ok", []string{"WiFi", "failsafe", "triggered!"})
-		go menus.PlayAlert()
-		time.Sleep(5 * time.Second) // Give it a moment to breathe
-	}
+	// WiFi and Bluetooth are managed by the host when running headless
+	menus.Set("WiFi_Connected", false)
+	menus.Set("WiFi_SSID", "")
+	menus.Set("WiFi_Strength", 0)
+	menus.Set("WiFi_IP", "")
+
+	if !headless {
+		// Failsafe
+		enabled, _ := nm.GetPropertyWirelessEnabled()
+		if !enabled {
+			log.Println("WiFi was off, emergency re-enabling...")
+			nm.SetPropertyWirelessEnabled(true)
+			menus.RenderAlert("ok", []string{"WiFi", "failsafe", "triggered!"})
+			go menus.PlayAlert()
+			time.Sleep(5 * time.Second) // Give it a moment to breathe
+		}
 
-	// Set initial WiFi status values
-	connected, ssid, strength, ipaddr := misc.GetWiFiStatus()
-	menus.Set("WiFi_Connected", connected)
-	menus.Set("WiFi_SSID", ssid)
-	menus.Set("WiFi_Strength", strength)
-	menus.Set("WiFi_IP", ipaddr)
+		// Set initial WiFi status values
+		connected, ssid, strength, ipaddr := misc.GetWiFiStatus()
+		menus.Set("WiFi_Connected", connected)
+		menus.Set("WiFi_SSID", ssid)
+		menus.Set("WiFi_Strength", strength)
+		menus.Set("WiFi_IP", ipaddr)
 
-	// Update WiFi state
-	go func() {
-		for {
-			select {
-			case <-ctx.Done():
-				return
-			case <-time.After(100 * time.Millisecond):
-				connected, ssid, strength, ipaddr = misc.GetWiFiStatus()
-				menus.Set("WiFi_Connected", connected)
-				menus.Set("WiFi_SSID", ssid)
-				menus.Set("WiFi_Strength", strength)
-				menus.Set("WiFi_IP", ipaddr)
+		// Update WiFi state
+		go func() {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(100 * time.Millisecond):
+					connected, ssid, strength, ipaddr = misc.GetWiFiStatus()
+					menus.Set("WiFi_Connected", connected)
+					menus.Set("WiFi_SSID", ssid)
+					menus.Set("WiFi_Strength", strength)
+					menus.Set("WiFi_IP", ipaddr)
+				}
 			}
-		}
-	}()
+		}()
 
-	// Set initial bluetooth state
-	bt_enabled := misc.IsBluetoothEnabled()
-	menus.Set("BluetoothEnabled", bt_enabled)
+		// Set initial bluetooth state
+		bt_enabled := misc.IsBluetoothEnabled()
+		menus.Set("BluetoothEnabled", bt_enabled)
 
-	// Update bluetooth state
-	go func() {
-		for {
-			select {
-			case <-ctx.Done():
-				return
-			case <-time.After(100 * time.Millisecond):
-				menus.Set("BluetoothEnabled", misc.IsBluetoothEnabled())
+		// Update bluetooth state
+		go func() {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(100 * time.Millisecond):
+					menus.Set("BluetoothEnabled", misc.IsBluetoothEnabled())
+				}
 			}
-		}
-	}()
+		}()
+	}
+
+	// Keep the data icon in line with the real link state
+	if modem != nil {
+		modem.SetDataEnabled(menus.Get("CellularData").(bool))
+		go func() {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(time.Second):
+					connected, _ := misc.GetCellularStatus(phone.DataInterface)
+					modem.SetDataConnected(connected)
+				}
+			}
+		}()
+	}
 
 	// Handle modem events
 	if modem != nil {
+		events, unsubscribe := modem.Subscribe()
 		go func() {
+			defer unsubscribe()
+
+			// Whether the call that just ended never got through
+			redial := false
+
+			// Until when a failed call's alert stays up before CallEnded
+			// takes us home
+			var alertUntil time.Time
+
 			for {
+				var event phone.Event
 				select {
 				case <-ctx.Done():
 					return
+				case event = <-events:
+				}
+
+				switch e := event.(type) {
+				case phone.CallEvent:
+					switch e.Kind {
+					case phone.CallRinging:
+						go menus.ToMenu("ring")
+						backlight.On()
+						menus.Timers["keypad"].Stop()
+						menus.Timers["oled"].Stop()
+
+					case phone.CallStarted:
+						go menus.ToMenu("phone")
+						menus.Timers["oled"].Restart()
+						menus.Timers["keypad"].Restart()
+
+					case phone.CallWaiting:
+						backlight.On()
+						menus.Timers["keypad"].Restart()
+						go menus.PlayAlert()
+
+					case phone.CallFailed:
+						log.Println("⚠️ Call failed:", e.Err)
+						if errors.Is(e.Err, phone.ErrCallBarred) {
+							go menus.RenderAlert("prohibited", []string{"Call", "barred."})
+						} else {
+							go menus.RenderAlert("alert", []string{"Call", "failed."})
+							redial = true
+						}
+						menus.Timers["oled"].Restart()
+						menus.Timers["keypad"].Restart()
+						backlight.On()
+						go menus.PlayAlert()
+						alertUntil = time.Now().Add(2 * time.Second)
+
+					case phone.CallEnded:
+						go modem.EndEmergency()
+						go modem.ResetCallAudio()
+
+						// Waits out the alert off the event loop, so events
+						// keep flowing meanwhile
+						go func(redial bool, wait time.Duration) {
+							time.Sleep(wait)
+							if redial {
+								menus.Redial()
+							} else {
+								menus.ToStart()
+							}
+						}(redial, time.Until(alertUntil))
+						redial = false
+						backlight.On()
+						menus.Timers["oled"].Restart()
+						menus.Timers["keypad"].Restart()
+
+					case phone.CallLogged:
+						menus.SaveCallLog(e.Record)
+
+						// The last call to end decides whether to redial
+						redial = e.Record.Failed()
+
+					case phone.CallMissed:
+						backlight.On()
+						menus.Timers["keypad"].Restart()
+					}
+
+				case phone.RegistrationEvent:
+					if e.Denied {
+						log.Println("⚠️ Network registration denied")
+						backlight.On()
+						menus.Timers["keypad"].Restart()
+						go menus.PushWithArgs("alert", &menu.GenericAlertConfig{
+							Icon:     "prohibited",
+							Label:    []string{"Registration", "denied"},
+							BeepType: menu.BeepTypeGeneric,
+						})
+					}
+
+				case phone.HealthEvent:
+					if e.Fault {
+						log.Println("⚠️ Modem lost")
+						continue
+					}
 
-				case <-modem.RingingChan:
-					go menus.ToMenu("ring")
-					misc.KeyLightsOn()
-					menus.Timers["keypad"].Stop()
-					menus.Timers["oled"].Stop()
-
-				case <-modem.CallStartChan:
-					go menus.ToMenu("phone")
-					menus.Timers["oled"].Restart()
-					menus.Timers["keypad"].Restart()
-
-				case <-modem.CallErrorChan:
-					log.Println("⚠️ Call failed")
-					go menus.RenderAlert("alert", []string{"Call", "failed."})
-					menus.Timers["oled"].Restart()
-					menus.Timers["keypad"].Restart()
-					misc.KeyLightsOn()
-					menus.PlayAlert()
-					time.Sleep(2 * time.Second)
-					modem.CallHandledChan <- true
-
-				case <-modem.CallEndChan:
-					go menus.ToStart()
-					misc.KeyLightsOn()
-					menus.Timers["oled"].Restart()
-					menus.Timers["keypad"].Restart()
+					// The modem forgets its settings when it restarts
+					log.Println("📡 Modem back")
+					go menus.UpdateDivertStatus()
+					go menus.ApplyNetworkMode()
+					go menus.ApplyCellInfoDisplay()
+					if modem.State().SIMLock != "" {
+						go menus.ToStart()
+					}
+
+				case phone.ClockEvent:
+					go menus.SetNetworkTime(e.Time, e.DST)
+
+				case phone.SMSEvent:
+					menus.SaveIncomingMessage(e.Message)
+					backlight.On()
+					if menus.Get("CanVibrate").(bool) {
+						go misc.VibrateAlert(player, ctx)
+					}
+					if menus.Get("BeepOnly").(bool) {
+						go menus.PlayAlert()
+					} else if menus.Get("CanRing").(bool) {
+						go misc.PlayMessage(player, ctx)
+					}
 				}
 			}
 		}()
@@ -507,12 +678,25 @@ func main() {
 	}()
 
 	// Show alert if there's something wrong with the SIM state
-	if modem != nil && !modem.SimCardInserted {
-		menus.RenderAlert("prohibited", []string{"No SIM", "card", "inserted."})
-		if menus.Get("CanRing").(bool) || menus.Get("BeepOnly").(bool) {
-			go menus.PlayAlert()
+	if modem != nil {
+		if state := modem.State(); !state.Fault && !state.SimCardInserted && state.SIMLock == "" {
+			menus.RenderAlert("prohibited", []string{"No SIM", "card", "inserted."})
+			if menus.Get("CanRing").(bool) || menus.Get("BeepOnly").(bool) {
+				go menus.PlayAlert()
+			}
+			time.Sleep(3 * time.Second)
 		}
-		time.Sleep(3 * time.Second)
+	}
+
+	// The network may have changed the divert since we last asked
+	go menus.UpdateDivertStatus()
+	go menus.ApplyNetworkMode()
+	go menus.ApplyCellInfoDisplay()
+
+	// The network may have sent the time before we were listening
+	go menus.ApplyClockMode()
+	if modem != nil {
+		go modem.RequestNetworkTime()
 	}
 
 	// Persist screen for a moment
@@ -525,7 +709,7 @@ func main() {
 		menus.Push("screensaver")
 	})
 	menus.Timers["keypad"] = timers.New(ctx, 5*time.Second, false, func() {
-		misc.KeyLightsOff()
+		backlight.Off()
 	})
 
 	// Run home menu
@@ -540,30 +724,30 @@ func main() {
 
 			case <-BatteryChargedChan:
 				go menus.ToMenu("battery_charged")
-				misc.KeyLightsOn()
+				backlight.On()
 				menus.Timers["keypad"].Restart()
 				menus.Timers["oled"].Restart()
 
 			case <-BatteryChargingChan:
 				go menus.ToMenu("battery_charging")
-				misc.KeyLightsOn()
+				backlight.On()
 				menus.Timers["keypad"].Restart()
 				menus.Timers["oled"].Restart()
 
 			case <-VeryLowBattChan:
 				go menus.ToMenu("very_low_battery")
-				misc.KeyLightsOn()
+				backlight.On()
 				menus.Timers["keypad"].Restart()
 				menus.Timers["oled"].Restart()
 
 			case <-LowBattChan:
 				go menus.ToMenu("low_battery")
-				misc.KeyLightsOn()
+				backlight.On()
 				menus.Timers["keypad"].Restart()
 				menus.Timers["oled"].Restart()
 
 			case <-DeadBattChan:
-				misc.KeyLightsOn()
+				backlight.On()
 				menus.Timers["keypad"].Stop()
 				menus.Timers["oled"].Stop()
 				go menus.ToMenu("dead_battery")
@@ -591,7 +775,7 @@ func main() {
 	display.DrawImage(logo, 20, 70)
 	display.Render()
 	display.On()
-	misc.KeyLightsOn()
+	backlight.On()
 	time.Sleep(500 * time.Millisecond)
 	player.Stop()
 	if debug {
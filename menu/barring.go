@@ -0,0 +1,329 @@
+package menu
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"phone"
+)
+
+// Call barring services, by menu label
+var barringServices = map[string]string{
+	"Outgoing calls":      phone.BarAllOutgoing,
+	"International calls": phone.BarOutgoingInternational,
+	"Incoming calls":      phone.BarAllIncoming,
+	"Incoming if abroad":  phone.BarIncomingRoaming,
+}
+
+// Tells the user the network didn't take a request, and why if it said
+func (instance *SettingsMenu) requestFailed(err error) {
+	log.Println("⚠️ Network request failed:", err)
+
+	var cme *phone.CMEError
+	errors.As(err, &cme)
+	switch {
+	case cme != nil && cme.Code == phone.CMEIncorrectPassword:
+		instance.parent.RenderAlert("alert", []string{"Wrong", "password"})
+	case cme != nil && (cme.Code == phone.CMENotAllowed || cme.Code == phone.CMENotSupported):
+		instance.parent.RenderAlert("alert", []string{"Not", "supported"})
+	default:
+		instance.parent.RenderAlert("alert", []string{"Request", "not", "confirmed"})
+	}
+	go instance.parent.PlayAlert()
+	time.Sleep(2 * time.Second)
+}
+
+// ShowCallBarring lists the calls that can be barred, and what can be done
+// with each.
+func (instance *SettingsMenu) ShowCallBarring() int {
+	go instance.parent.PushWithArgs("selector", &SelectorArgs{
+		SelectionClass: "settings.barring",
+		Title:          "Call barring service",
+		Options: [][]string{
+			{"Outgoing calls", "Activate", "Cancel", "Check status"},
+			{"International calls", "Activate", "Cancel", "Check status"},
+			{"Incoming calls", "Activate", "Cancel", "Check status"},
+			{"Incoming if abroad", "Activate", "Cancel", "Check status"},
+			{"Cancel all barrings"},
+		},
+		ButtonLabel: "Select",
+		VisibleRows: 3,
+	})
+	return SettingsActionSubmenuPushed
+}
+
+// CallBarring runs an action picked for a barring service, asking for the
+// barring password for anything but a status check.
+func (instance *SettingsMenu) CallBarring(selection_path []string) int {
+	facility, ok := barringServices[selection_path[0]]
+	action := "Cancel"
+	switch {
+	case selection_path[0] == "Cancel all barrings":
+		facility = phone.BarAll
+	case !ok || len(selection_path) < 2:
+		return SettingsActionShowSelector
+	default:
+		action = selection_path[1]
+	}
+
+	if !instance.parent.NetworkAvailable() {
+		return SettingsActionShowSelector
+	}
+
+	if action == "Check status" {
+		instance.parent.RenderAlert("loading", []string{"Requesting"})
+		barred, err := instance.parent.Modem.QueryBarring(instance.ctx, facility)
+		if err != nil {
+			instance.requestFailed(err)
+			return SettingsActionShowSelector
+		}
+		if barred {
+			instance.parent.RenderAlert("info", []string{selection_path[0], "barred"})
+		} else {
+			instance.parent.RenderAlert("info", []string{selection_path[0], "not barred"})
+		}
+		time.Sleep(2 * time.Second)
+		return SettingsActionShowSelector
+	}
+
+	password := instance.parent.EnterCode("Barring password", "", instance.ctx)
+	if password == "" {
+		return SettingsActionShowSelector
+	}
+
+	instance.parent.RenderAlert("loading", []string{"Requesting"})
+	if err := instance.parent.Modem.SetBarring(instance.ctx, facility, action == "Activate", password); err != nil {
+		instance.requestFailed(err)
+		return SettingsActionShowSelector
+	}
+
+	if action == "Activate" {
+		instance.parent.RenderAlert("ok", []string{"Barring", "activated"})
+	} else {
+		instance.parent.RenderAlert("ok", []string{"Barring", "cancelled"})
+	}
+	time.Sleep(2 * time.Second)
+	return SettingsActionShowSelector
+}
+
+// ShowFixedDialing lets fixed dialing be turned on or off, marking the
+// current setting, or the numbers it allows be edited.
+func (instance *SettingsMenu) ShowFixedDialing() int {
+	if !instance.parent.SIMAvailable() {
+		return SettingsActionShowSelector
+	}
+
+	enabled, err := instance.parent.Modem.FixedDialing(instance.ctx)
+	if err != nil {
+		log.Println("⚠️ Failed to check fixed dialing:", err)
+	}
+
+	options := [][]string{{"On"}, {"Off"}, {"Number list"}}
+	if enabled {
+		options[0][0] = "On (current)"
+	} else {
+		options[1][0] = "Off (current)"
+	}
+
+	go instance.parent.PushWithArgs("selector", &SelectorArgs{
+		SelectionClass: "settings.fixed_dialing",
+		Title:          "Fixed dialing",
+		Options:        options,
+		ButtonLabel:    "Select",
+		VisibleRows:    3,
+	})
+	return SettingsActionSubmenuPushed
+}
+
+// SetFixedDialing asks for the PIN2 and turns fixed dialing on or off, or
+// shows the number list.
+func (instance *SettingsMenu) SetFixedDialing(label string) int {
+	if label == "Number list" {
+		return instance.ShowFixedNumbers()
+	}
+	if !instance.parent.SIMAvailable() {
+		return SettingsActionShowSelector
+	}
+
+	pin2 := instance.parent.EnterCode("PIN2 code", instance.codeHint(phone.FacilityPIN2), instance.ctx)
+	if pin2 == "" {
+		return SettingsActionShowSelector
+	}
+
+	enabled := strings.HasPrefix(label, "On")
+	if err := instance.parent.Modem.SetFixedDialing(instance.ctx, enabled, pin2); err != nil {
+		return instance.codeError(err)
+	}
+
+	if enabled {
+		instance.parent.RenderAlert("ok", []string{"Fixed", "dialing on"})
+	} else {
+		instance.parent.RenderAlert("ok", []string{"Fixed", "dialing off"})
+	}
+	time.Sleep(2 * time.Second)
+	return SettingsActionShowSelector
+}
+
+// ShowFixedNumbers lists the numbers on the SIM's fixed dialing list, each
+// of which can be edited or deleted, and lets one be added.
+func (instance *SettingsMenu) ShowFixedNumbers() int {
+	if !instance.parent.SIMAvailable() {
+		return SettingsActionShowSelector
+	}
+
+	instance.parent.RenderAlert("loading", []string{"Reading", "SIM card"})
+	contacts, err := instance.parent.Modem.ReadSIMPhonebook(instance.ctx, phone.FacilityFixedDialing)
+	if err != nil {
+		log.Println("⚠️ Failed to read fixed dialing list:", err)
+		instance.parent.RenderAlert("alert", []string{"Couldn't", "read", "SIM card"})
+		go instance.parent.PlayAlert()
+		time.Sleep(2 * time.Second)
+		return SettingsActionShowSelector
+	}
+
+	instance.fdn_cache = make(map[string]phone.SIMContact)
+	options := [][]string{{"Add number"}}
+	for _, contact := range contacts {
+		label := contact.Name
+		if label == "" {
+			label = contact.Number
+		}
+		if _, taken := instance.fdn_cache[label]; taken {
+			label = fmt.Sprintf("%s (%s)", label, contact.Number)
+		}
+		instance.fdn_cache[label] = contact
+		options = append(options, []string{label, "Edit", "Delete"})
+	}
+
+	go instance.parent.PushWithArgs("selector", &SelectorArgs{
+		SelectionClass: "settings.fixed_numbers",
+		Title:          "Number list",
+		Options:        options,
+		ButtonLabel:    "Select",
+		VisibleRows:    3,
+	})
+	return SettingsActionSubmenuPushed
+}
+
+// FixedNumber adds a number to the fixed dialing list, or edits or deletes
+// the one picked. Every change needs the PIN2.
+func (instance *SettingsMenu) FixedNumber(selection_path []string) int {
+	if !instance.parent.SIMAvailable() {
+		return SettingsActionShowSelector
+	}
+
+	var contact phone.SIMContact
+	action := "Add number"
+	if selection_path[0] != action {
+		var ok bool
+		contact, ok = instance.fdn_cache[selection_path[0]]
+		if !ok || len(selection_path) < 2 {
+			return SettingsActionShowSelector
+		}
+		action = selection_path[1]
+	}
+
+	if action != "Delete" {
+		contact.Number = instance.parent.EnterPhoneNumber("Number", contact.Number, instance.ctx)
+		if contact.Number == "" {
+			return SettingsActionShowSelector
+		}
+		contact.Name = instance.parent.EnterTextWithDefault("Name", contact.Name, instance.ctx)
+		if instance.ctx.Err() != nil {
+			return SettingsActionShowSelector
+		}
+	} else if !instance.parent.Confirm([]string{"Delete", selection_path[0] + "?"}, instance.ctx) {
+		return SettingsActionShowSelector
+	}
+
+	pin2 := instance.parent.EnterCode("PIN2 code", instance.codeHint(phone.FacilityPIN2), instance.ctx)
+	if pin2 == "" {
+		return SettingsActionShowSelector
+	}
+
+	var err error
+	if action == "Delete" {
+		err = instance.parent.Modem.DeleteFixedNumber(instance.ctx, contact.Index, pin2)
+	} else {
+		err = instance.parent.Modem.WriteFixedNumber(instance.ctx, contact.Index, contact.Number, contact.Name, pin2)
+	}
+	if err != nil {
+		return instance.codeError(err)
+	}
+
+	if action == "Delete" {
+		instance.parent.RenderAlert("ok", []string{"Number", "deleted"})
+	} else {
+		instance.parent.RenderAlert("ok", []string{"Number", "saved"})
+	}
+	time.Sleep(2 * time.Second)
+	return SettingsActionShowSelector
+}
+
+// ShowCUG lets calls be made in the subscription's closed user group, a
+// group picked by its index, or none, marking the current setting.
+func (instance *SettingsMenu) ShowCUG() int {
+	if !instance.parent.SIMAvailable() {
+		return SettingsActionShowSelector
+	}
+
+	options := [][]string{{"Default"}, {"On"}, {"Off"}}
+	setting, err := instance.parent.Modem.CUG(instance.ctx)
+	switch {
+	case err != nil:
+		log.Println("⚠️ Failed to check closed user group:", err)
+	case !setting.Enabled:
+		options[2][0] = "Off (current)"
+	case setting.Index == phone.CUGPreferred:
+		options[0][0] = "Default (current)"
+	default:
+		options[1][0] = fmt.Sprintf("On (current, %d)", setting.Index)
+	}
+
+	go instance.parent.PushWithArgs("selector", &SelectorArgs{
+		SelectionClass: "settings.cug",
+		Title:          "Closed user group",
+		Options:        options,
+		ButtonLabel:    "Select",
+		VisibleRows:    3,
+	})
+	return SettingsActionSubmenuPushed
+}
+
+// SetCUG picks the closed user group calls are made in. On asks for the
+// group's index.
+func (instance *SettingsMenu) SetCUG(label string) int {
+	if !instance.parent.SIMAvailable() {
+		return SettingsActionShowSelector
+	}
+
+	enabled := !strings.HasPrefix(label, "Off")
+	index := phone.CUGPreferred
+	if strings.HasPrefix(label, "On") {
+		entered := digitsOnly(instance.parent.EnterTextInMode("Group index", "", T9Numbers, instance.ctx))
+		if entered == "" {
+			return SettingsActionShowSelector
+		}
+		var err error
+		index, err = strconv.Atoi(entered)
+		if err != nil || index > 9 {
+			instance.parent.RenderAlert("alert", []string{"Invalid", "index"})
+			go instance.parent.PlayAlert()
+			time.Sleep(2 * time.Second)
+			return SettingsActionShowSelector
+		}
+	}
+
+	if err := instance.parent.Modem.SetCUG(instance.ctx, enabled, index); err != nil {
+		instance.requestFailed(err)
+		return SettingsActionShowSelector
+	}
+
+	instance.parent.RenderAlert("ok", []string{"Closed user", "group set"})
+	time.Sleep(2 * time.Second)
+	return SettingsActionShowSelector
+}
@@ -9,7 +9,6 @@ import (
 	"sync"
 	"time"
 
-	"misc"
 	"sh1107"
 )
 
@@ -298,7 +297,7 @@ func (instance *CalculatorMenu) Run() {
 				instance.parent.Timers["keypad"].Reset()
 				instance.parent.Timers["oled"].Reset()
 				instance.parent.Display.On()
-				misc.KeyLightsOn()
+				instance.parent.Backlight.On()
 				go instance.parent.PlayKey()
 
 				switch evt.Key {
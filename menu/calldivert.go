@@ -0,0 +1,359 @@
+package menu
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"phone"
+	"sh1107"
+)
+
+const (
+	CallDivertActionExit = iota
+	CallDivertActionShowSelector
+	CallDivertActionSubmenuPushed
+)
+
+// Menu labels for each divert reason
+var divertReasons = map[string]int{
+	"All calls":     phone.DivertUnconditional,
+	"If busy":       phone.DivertBusy,
+	"Not answered":  phone.DivertNoReply,
+	"Not reachable": phone.DivertNotReachable,
+}
+
+// How long a call rings before it's diverted when not answered
+var divertDelays = [][]string{
+	{"5 seconds"}, {"10 seconds"}, {"15 seconds"}, {"20 seconds"}, {"25 seconds"}, {"30 seconds"},
+}
+
+type CallDivertMenu struct {
+	ctx               context.Context
+	configured        bool
+	cancelFn          context.CancelFunc
+	parent            *Menu
+	wg                sync.WaitGroup
+	process_selection bool
+	selection_class   string
+	selection_path    []string
+	options           [][]string
+	divert_number     string // Waiting for a delay to be picked
+}
+
+func (*CallDivertMenu) Label() string {
+	return "Call Divert Menu"
+}
+
+func (m *Menu) NewCallDivertMenu() *CallDivertMenu {
+	return &CallDivertMenu{
+		parent:            m,
+		process_selection: false,
+		selection_path:    []string{},
+		options: [][]string{
+			{"All calls", "Activate", "Cancel", "Check status"},
+			{"If busy", "Activate", "Cancel", "Check status"},
+			{"Not answered", "Activate", "Cancel", "Check status"},
+			{"Not reachable", "Activate", "Cancel", "Check status"},
+			{"Cancel all"},
+		},
+	}
+}
+
+// UpdateDivertStatus asks the network whether all voice calls are diverted,
+// which drives the indicator in the status bar. The last known state is kept
+// if the network can't be asked.
+func (m *Menu) UpdateDivertStatus() {
+	if m.Modem == nil || !m.Modem.State().SimCardInserted {
+		return
+	}
+	rule, err := m.Modem.QueryDivert(m.GlobalContext, phone.DivertUnconditional)
+	if err != nil {
+		log.Println("⚠️ Failed to check call divert:", err)
+		return
+	}
+	m.Set("CallDivertActive", rule.Active)
+}
+
+func (instance *CallDivertMenu) Configure() {
+	// Reset context
+	instance.configured = true
+	instance.ctx, instance.cancelFn = context.WithCancel(instance.parent.GlobalContext)
+}
+
+func (instance *CallDivertMenu) ConfigureWithArgs(args ...any) {
+
+	// Check if we have args
+	if len(args) > 0 {
+
+		// Most likely our arg is a SelectorReturn from the selector.
+		selection, ok := args[0].(*SelectorReturn)
+		if !ok {
+			panic("(*CallDivertMenu).ConfigureWithArgs() Type error: argument must be a *SelectorReturn type")
+		}
+
+		instance.process_selection = true
+		instance.selection_path = selection.SelectionPath
+		instance.selection_class = selection.SelectionClass
+	}
+
+	instance.Configure()
+}
+
+// CallDivertMain handles an entry picked from the main call divert menu.
+func (instance *CallDivertMenu) CallDivertMain(selection_path []string) int {
+	if selection_path[0] == "Cancel all" {
+		instance.Erase(phone.DivertAll)
+		return CallDivertActionShowSelector
+	}
+
+	reason, ok := divertReasons[selection_path[0]]
+	if !ok || len(selection_path) < 2 {
+		return CallDivertActionShowSelector
+	}
+
+	switch selection_path[1] {
+	case "Activate":
+		number := instance.parent.EnterPhoneNumber("Divert to", "", instance.ctx)
+		if number == "" {
+			break
+		}
+
+		// Ask how long to ring first
+		if reason == phone.DivertNoReply {
+			instance.divert_number = number
+			go instance.parent.PushWithArgs("selector", &SelectorArgs{
+				SelectionClass: "call_divert.delay",
+				Title:          "Delay",
+				Options:        divertDelays,
+				ButtonLabel:    "Select",
+				VisibleRows:    3,
+			})
+			return CallDivertActionSubmenuPushed
+		}
+		instance.Register(reason, number, 0)
+
+	case "Cancel":
+		instance.Erase(reason)
+
+	case "Check status":
+		return instance.ShowStatus(selection_path[0], reason)
+	}
+
+	return CallDivertActionShowSelector
+}
+
+// Register sends a divert to the network and shows whether it took.
+func (instance *CallDivertMenu) Register(reason int, number string, delay int) {
+	if !instance.parent.NetworkAvailable() {
+		return
+	}
+
+	instance.parent.RenderAlert("loading", []string{"Requesting"})
+	if err := instance.parent.Modem.RegisterDivert(instance.ctx, reason, number, delay); err != nil {
+		log.Println("⚠️ Failed to activate call divert:", err)
+		instance.parent.RenderAlert("alert", []string{"Request", "not", "confirmed"})
+		go instance.parent.PlayAlert()
+	} else {
+		instance.parent.UpdateDivertStatus()
+		instance.parent.RenderAlert("ok", []string{"Divert", "activated"})
+	}
+	time.Sleep(2 * time.Second)
+}
+
+// Erase cancels a divert, or several of them with phone.DivertAll.
+func (instance *CallDivertMenu) Erase(reason int) {
+	if !instance.parent.NetworkAvailable() {
+		return
+	}
+
+	instance.parent.RenderAlert("loading", []string{"Requesting"})
+	if err := instance.parent.Modem.EraseDivert(instance.ctx, reason); err != nil {
+		log.Println("⚠️ Failed to cancel call divert:", err)
+		instance.parent.RenderAlert("alert", []string{"Request", "not", "confirmed"})
+		go instance.parent.PlayAlert()
+	} else {
+		instance.parent.UpdateDivertStatus()
+		instance.parent.RenderAlert("ok", []string{"Divert", "cancelled"})
+	}
+	time.Sleep(2 * time.Second)
+}
+
+// ShowStatus asks the network where calls are diverted to for a reason.
+func (instance *CallDivertMenu) ShowStatus(title string, reason int) int {
+	if !instance.parent.NetworkAvailable() {
+		return CallDivertActionShowSelector
+	}
+
+	instance.parent.RenderAlert("loading", []string{"Requesting"})
+	rule, err := instance.parent.Modem.QueryDivert(instance.ctx, reason)
+	if err != nil {
+		log.Println("⚠️ Failed to check call divert:", err)
+		instance.parent.RenderAlert("alert", []string{"Request", "not", "confirmed"})
+		go instance.parent.PlayAlert()
+		time.Sleep(2 * time.Second)
+		return CallDivertActionShowSelector
+	}
+
+	if reason == phone.DivertUnconditional {
+		instance.parent.Set("CallDivertActive", rule.Active)
+	}
+
+	display := instance.parent.Display
+	display.Clear(sh1107.Black)
+
+	font := display.Use_Font8_Normal()
+	display.DrawTextAligned(0, 20, font, title, false, sh1107.AlignRight, sh1107.AlignNone)
+
+	display.SetColor(sh1107.White)
+	display.SetLineWidth(1)
+	display.DrawLine(0, 33, 127, 33)
+	display.Stroke()
+
+	if rule.Active {
+		display.DrawText(0, 38, font, "Active, diverting to:", false)
+		display.DrawText(0, 49, font, instance.parent.ContactName(rule.Number), false)
+		if rule.Time > 0 {
+			display.DrawText(0, 60, font, fmt.Sprintf("After %d seconds", rule.Time), false)
+		}
+	} else {
+		display.DrawText(0, 38, font, "Not active", false)
+	}
+
+	font = display.Use_Font8_Bold()
+	display.DrawTextAligned(64, 105, font, "OK", false, sh1107.AlignCenter, sh1107.AlignNone)
+	display.Render()
+
+	for {
+		select {
+		case <-instance.ctx.Done():
+			return CallDivertActionSubmenuPushed
+		case evt := <-instance.parent.KeypadEvents:
+			if !evt.State {
+				continue
+			}
+
+			instance.parent.Timers["keypad"].Reset()
+			instance.parent.Timers["oled"].Reset()
+			instance.parent.Display.On()
+			instance.parent.Backlight.On()
+			go instance.parent.PlayKey()
+
+			switch evt.Key {
+			case 'P':
+				go instance.parent.Push("power")
+				return CallDivertActionSubmenuPushed
+			case 'S', 'C':
+				return CallDivertActionShowSelector
+			}
+		}
+	}
+}
+
+func (instance *CallDivertMenu) Run() {
+	if !instance.configured {
+		panic("Attempted to call (*CallDivertMenu).Run() before (*CallDivertMenu).Configure()!")
+	}
+
+	log.Println("↪️ Call divert started")
+
+	if !instance.process_selection {
+		// Start the selector with the base call divert menu
+		log.Println("↪️ Call divert switching to selector")
+		go instance.parent.PushWithArgs("selector", &SelectorArgs{
+			Title:                      "Call Divert",
+			SelectionClass:             "call_divert.main",
+			Options:                    instance.options,
+			ButtonLabel:                "Select",
+			VisibleRows:                3,
+			ShowElemNumberInTitle:      true,
+			ShowElemNumbersInSelection: true,
+			AllowNumberKeyShortcut:     true,
+			PersistLastState:           true,
+		})
+		return
+	}
+
+	log.Printf("↪️ Call divert %s: %s", instance.selection_class, instance.selection_path)
+
+	switch instance.selection_class {
+	case "call_divert.main":
+
+		// Exit to main menu
+		if len(instance.selection_path) == 0 {
+			log.Println("↪️ Call divert path selected is empty, exiting...")
+			go instance.parent.Pop()
+			return
+		}
+
+		action := instance.CallDivertMain(instance.selection_path)
+
+		switch action {
+		case CallDivertActionExit:
+			log.Println("↪️ Call divert exiting")
+			go instance.parent.Pop()
+			return
+		case CallDivertActionSubmenuPushed:
+			// Do nothing, wait for submenu to return
+			return
+		}
+
+		// Paused part way through, the power menu is on top
+		if instance.ctx.Err() != nil {
+			return
+		}
+
+	case "call_divert.delay":
+		if len(instance.selection_path) > 0 && instance.divert_number != "" {
+			var delay int
+			fmt.Sscanf(instance.selection_path[0], "%d", &delay)
+			instance.Register(phone.DivertNoReply, instance.divert_number, delay)
+		}
+		instance.divert_number = ""
+		if instance.ctx.Err() != nil {
+			return
+		}
+	}
+
+	instance.process_selection = false
+	log.Println("↪️ Call divert switching back to selector")
+	go instance.parent.PushWithArgs("selector", &SelectorArgs{
+		Title:                      "Call Divert",
+		SelectionClass:             "call_divert.main",
+		Options:                    instance.options,
+		ButtonLabel:                "Select",
+		VisibleRows:                3,
+		ShowElemNumberInTitle:      true,
+		ShowElemNumbersInSelection: true,
+		AllowNumberKeyShortcut:     true,
+		PersistLastState:           true,
+	})
+}
+
+func (instance *CallDivertMenu) Pause() {
+	instance.process_selection = true
+	instance.cancelFn()
+	if ok := waitWithTimeout(&instance.wg, 1*time.Second); !ok {
+		log.Println("⚠️ Call divert handler pause timed out — goroutines may be stuck")
+		// Optional: escalate here
+	}
+}
+
+func (instance *CallDivertMenu) Stop() {
+	instance.process_selection = false
+	instance.cancelFn()
+	if ok := waitWithTimeout(&instance.wg, 1*time.Second); !ok {
+		log.Println("⚠️ Call divert handler stop timed out — goroutines may be stuck")
+		// Optional: escalate here
+	} else {
+		go instance.cleanup()
+	}
+}
+
+func (instance *CallDivertMenu) cleanup() {
+	instance.process_selection = false
+	instance.selection_path = []string{}
+	instance.divert_number = ""
+}
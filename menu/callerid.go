@@ -0,0 +1,138 @@
+package menu
+
+import (
+	"log"
+	"strings"
+
+	"db"
+)
+
+// Numbers shorter than this only match exactly, so short codes and extensions
+// don't match the end of some unrelated number
+const minSuffixMatchDigits = 7
+
+// A phonebook number with the name it belongs to
+type contactEntry struct {
+	ContactID uint
+	Name      string
+	Number    string
+}
+
+// normalizeNumber reduces a number to the digits that identify it: formatting
+// and the + are dropped, as are leading zeros, which covers both the 00
+// international prefix and the 0 trunk prefix.
+func normalizeNumber(number string) string {
+	var sb strings.Builder
+	for _, r := range number {
+		if (r >= '0' && r <= '9') || r == '*' || r == '#' {
+			sb.WriteRune(r)
+		}
+	}
+	return strings.TrimLeft(sb.String(), "0")
+}
+
+// numbersMatch reports whether two numbers reach the same line. A number
+// written with its country code matches the same number written without it,
+// as long as enough digits are left to be sure.
+func numbersMatch(a, b string) bool {
+	a, b = normalizeNumber(a), normalizeNumber(b)
+	if a == "" || b == "" {
+		return false
+	}
+	if a == b {
+		return true
+	}
+	if len(a) < len(b) {
+		a, b = b, a
+	}
+	return len(b) >= minSuffixMatchDigits && strings.HasSuffix(a, b)
+}
+
+// Loads every number in the phonebook along with its contact's name
+func (m *Menu) contactEntries() []contactEntry {
+	var entries []contactEntry
+	res := m.PersistStore.Model(&db.ContactNumber{}).
+		Select("contact_numbers.contact_id, contacts.name, contact_numbers.number").
+		Joins("JOIN contacts ON contacts.id = contact_numbers.contact_id").
+		Scan(&entries)
+	if res.Error != nil {
+		log.Println("⚠️ Failed to load phonebook numbers:", res.Error)
+	}
+	return entries
+}
+
+// Finds the entry for a number, preferring an exact match over one that
+// differs by country code
+func findContactEntry(entries []contactEntry, number string) *contactEntry {
+	normalized := normalizeNumber(number)
+	var found *contactEntry
+	for i := range entries {
+		if !numbersMatch(entries[i].Number, number) {
+			continue
+		}
+		if normalizeNumber(entries[i].Number) == normalized {
+			return &entries[i]
+		}
+		if found == nil {
+			found = &entries[i]
+		}
+	}
+	return found
+}
+
+// LookupContact returns the contact a number belongs to, or nil if it isn't
+// in the phonebook.
+func (m *Menu) LookupContact(number string) *db.Contact {
+	entry := findContactEntry(m.contactEntries(), number)
+	if entry == nil {
+		return nil
+	}
+
+	var contact db.Contact
+	if res := m.PersistStore.Preload("Numbers").First(&contact, entry.ContactID); res.Error != nil {
+		log.Println("⚠️ Failed to load contact:", res.Error)
+		return nil
+	}
+	return &contact
+}
+
+// ContactName returns the name a number is saved under, or the number itself
+// if it isn't in the phonebook.
+func (m *Menu) ContactName(number string) string {
+	if entry := findContactEntry(m.contactEntries(), number); entry != nil {
+		return entry.Name
+	}
+	return number
+}
+
+// Remembers the name last looked up for a number, so screens that redraw
+// several times a second don't query the phonebook each time
+type callerID struct {
+	number string
+	name   string
+}
+
+// Name returns the contact name for number, or "" if it isn't in the phonebook
+func (c *callerID) Name(m *Menu, number string) string {
+	if number != c.number {
+		c.number = number
+		c.name = ""
+		if entry := findContactEntry(m.contactEntries(), number); entry != nil {
+			c.name = entry.Name
+		}
+	}
+	return c.name
+}
+
+// Draws who's on the other end of a call: the contact name with the number
+// underneath, or just the number if it isn't in the phonebook
+func (m *Menu) renderCaller(caller *callerID, number string) {
+	display := m.Display
+	name := caller.Name(m, number)
+	if name == "" {
+		display.DrawText(0, 45, display.Use_Font16(), number, false)
+		return
+	}
+	display.DrawText(0, 38, display.Use_Font16(), name, false)
+	display.DrawText(0, 54, display.Use_Font8_Normal(), number, false)
+}
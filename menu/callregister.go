@@ -0,0 +1,539 @@
+package menu
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"db"
+	"phone"
+	"sh1107"
+
+	"gorm.io/gorm"
+)
+
+const (
+	CallRegisterActionExit = iota
+	CallRegisterActionShowSelector
+	CallRegisterActionSubmenuPushed
+)
+
+// How many calls each list keeps
+const callRegisterListSize = 20
+
+type CallRegisterMenu struct {
+	ctx               context.Context
+	configured        bool
+	cancelFn          context.CancelFunc
+	parent            *Menu
+	wg                sync.WaitGroup
+	process_selection bool
+	selection_class   string
+	selection_path    []string
+	options           [][]string
+	call_cache        map[string]uint // Selector label -> call log ID
+	list              string          // Which list call_cache was built from
+	current_target    uint
+}
+
+func (*CallRegisterMenu) Label() string {
+	return "Call Register Menu"
+}
+
+func (m *Menu) NewCallRegisterMenu() *CallRegisterMenu {
+	return &CallRegisterMenu{
+		parent:            m,
+		process_selection: false,
+		selection_path:    []string{},
+		call_cache:        make(map[string]uint),
+		options: [][]string{
+			{"Missed calls"},
+			{"Received calls"},
+			{"Dialed numbers"},
+			{"Clear logs", "All", "Missed", "Received", "Dialed"},
+			{"Call timers", "Last call", "Received calls", "Dialed calls", "All calls"},
+		},
+	}
+}
+
+// SaveCallLog stores a finished call in the call register.
+func (m *Menu) SaveCallLog(record *phone.CallRecord) {
+	entry := &db.CallLog{
+		Number:    record.Number,
+		Inbound:   record.Inbound,
+		Answered:  record.Answered,
+		HungUp:    record.HungUp,
+		StartTime: record.StartTime,
+		Duration:  record.Duration,
+		Seen:      !record.Missed(),
+	}
+	if res := m.PersistStore.Create(entry); res.Error != nil {
+		log.Println("⚠️ Failed to save call log:", res.Error)
+	}
+	m.UpdateMissedCalls()
+}
+
+// UpdateMissedCalls recounts the missed calls that haven't been looked at,
+// which drives the notice on the home screen.
+func (m *Menu) UpdateMissedCalls() {
+	var count int64
+	res := m.PersistStore.Model(&db.CallLog{}).Where("inbound = ? AND answered = ? AND hung_up = ? AND seen = ?", true, false, false, false).Count(&count)
+	if res.Error != nil {
+		log.Println("⚠️ Failed to count missed calls:", res.Error)
+		return
+	}
+	m.Set("MissedCalls", int(count))
+}
+
+// DismissMissedCalls marks every missed call as seen.
+func (m *Menu) DismissMissedCalls() {
+	res := m.PersistStore.Model(&db.CallLog{}).Where("seen = ?", false).Update("seen", true)
+	if res.Error != nil {
+		log.Println("⚠️ Failed to dismiss missed calls:", res.Error)
+	}
+	m.UpdateMissedCalls()
+}
+
+// Narrows a call log query down to one of the lists
+func callListQuery(store *gorm.DB, list string) *gorm.DB {
+	store = store.Model(&db.CallLog{})
+	switch list {
+	case "Missed":
+		return store.Where("inbound = ? AND answered = ? AND hung_up = ?", true, false, false)
+	case "Received":
+		// Calls rejected here were seen, so they count as received
+		return store.Where("inbound = ? AND (answered = ? OR hung_up = ?)", true, true, true)
+	case "Dialed":
+		return store.Where("inbound = ?", false)
+	}
+	return store
+}
+
+func (instance *CallRegisterMenu) Configure() {
+	// Reset context
+	instance.configured = true
+	instance.ctx, instance.cancelFn = context.WithCancel(instance.parent.GlobalContext)
+}
+
+func (instance *CallRegisterMenu) ConfigureWithArgs(args ...any) {
+
+	// Check if we have args
+	if len(args) > 0 {
+
+		// Most likely our arg is a SelectorReturn from the selector.
+		selection, ok := args[0].(*SelectorReturn)
+		if !ok {
+			panic("(*CallRegisterMenu).ConfigureWithArgs() Type error: argument must be a *SelectorReturn type")
+		}
+
+		instance.process_selection = true
+		instance.selection_path = selection.SelectionPath
+		instance.selection_class = selection.SelectionClass
+	}
+
+	instance.Configure()
+}
+
+// CallRegisterMain handles an entry picked from the main call register menu.
+func (instance *CallRegisterMenu) CallRegisterMain(selection_path []string) int {
+	switch selection_path[0] {
+	case "Missed calls":
+		return instance.ShowList("Missed")
+	case "Received calls":
+		return instance.ShowList("Received")
+	case "Dialed numbers":
+		return instance.ShowList("Dialed")
+	case "Clear logs":
+		if len(selection_path) > 1 {
+			instance.ClearLogs(selection_path[1])
+		}
+	case "Call timers":
+		if len(selection_path) > 1 {
+			return instance.ShowTimer(selection_path[1])
+		}
+	}
+
+	return CallRegisterActionShowSelector
+}
+
+// ShowList lists the calls in the missed, received or dialed list, newest
+// first. Opening the missed list dismisses the missed call notice.
+func (instance *CallRegisterMenu) ShowList(list string) int {
+	var calls []db.CallLog
+	res := callListQuery(instance.parent.PersistStore, list).Order("start_time desc").Limit(callRegisterListSize).Find(&calls)
+	if res.Error != nil {
+		log.Println("⚠️ Failed to load call log:", res.Error)
+	}
+
+	if list == "Missed" {
+		instance.parent.DismissMissedCalls()
+	}
+
+	if len(calls) == 0 {
+		instance.parent.RenderAlert("info", []string{"No", "numbers"})
+		time.Sleep(2 * time.Second)
+		return CallRegisterActionShowSelector
+	}
+
+	instance.list = list
+	instance.call_cache = make(map[string]uint)
+
+	entries := instance.parent.contactEntries()
+
+	var options [][]string
+	for _, call := range calls {
+		key := call.Number
+		if entry := findContactEntry(entries, call.Number); entry != nil {
+			key = entry.Name
+		}
+		if _, exists := instance.call_cache[key]; exists {
+			key = fmt.Sprintf("%s (%s)", key, call.StartTime.Local().Format("01/02 15:04"))
+		}
+		instance.call_cache[key] = call.ID
+		options = append(options, []string{key})
+	}
+
+	titles := map[string]string{
+		"Missed":   "Missed calls",
+		"Received": "Received calls",
+		"Dialed":   "Dialed numbers",
+	}
+
+	go instance.parent.PushWithArgs("selector", &SelectorArgs{
+		SelectionClass:             "call_register.list",
+		Title:                      titles[list],
+		Options:                    options,
+		ButtonLabel:                "Details",
+		VisibleRows:                3,
+		ShowElemNumberInTitle:      true,
+		ShowElemNumbersInSelection: true,
+	})
+	return CallRegisterActionSubmenuPushed
+}
+
+func (instance *CallRegisterMenu) renderCall(call *db.CallLog) {
+	display := instance.parent.Display
+
+	display.Clear(sh1107.Black)
+
+	name := instance.parent.ContactName(call.Number)
+
+	font := display.Use_Font8_Normal()
+	display.DrawTextAligned(0, 20, font, name, false, sh1107.AlignRight, sh1107.AlignNone)
+
+	display.SetColor(sh1107.White)
+	display.SetLineWidth(1)
+	display.DrawLine(0, 33, 127, 33)
+	display.Stroke()
+
+	start := call.StartTime.Local()
+	display.DrawText(0, 38, font, "Date: "+start.Format("01/02/06"), false)
+	display.DrawText(0, 49, font, "Time: "+start.Format("15:04"), false)
+	if call.Answered {
+		display.DrawText(0, 60, font, "Duration: "+formatDuration(call.Duration), false)
+	} else if call.Inbound && call.HungUp {
+		display.DrawText(0, 60, font, "Rejected", false)
+	} else if call.Inbound {
+		display.DrawText(0, 60, font, "Not answered", false)
+	} else {
+		display.DrawText(0, 60, font, "Not connected", false)
+	}
+	if name != call.Number {
+		display.DrawText(0, 71, font, call.Number, false)
+	}
+
+	font = display.Use_Font8_Bold()
+	display.DrawTextAligned(64, 105, font, "Options", false, sh1107.AlignCenter, sh1107.AlignNone)
+
+	display.Render()
+}
+
+// ShowCall shows when a call happened and how long it lasted. It returns
+// true if Options was pressed, or false if the details were closed.
+func (instance *CallRegisterMenu) ShowCall(id uint) bool {
+	var call db.CallLog
+	if res := instance.parent.PersistStore.First(&call, id); res.Error != nil {
+		log.Println("⚠️ Failed to load call log:", res.Error)
+		instance.parent.RenderAlert("alert", []string{"Call", "not found"})
+		time.Sleep(2 * time.Second)
+		return false
+	}
+
+	instance.renderCall(&call)
+	for {
+		select {
+		case <-instance.ctx.Done():
+			return false
+		case evt := <-instance.parent.KeypadEvents:
+			if !evt.State {
+				continue
+			}
+
+			instance.parent.Timers["keypad"].Reset()
+			instance.parent.Timers["oled"].Reset()
+			instance.parent.Display.On()
+			instance.parent.Backlight.On()
+			go instance.parent.PlayKey()
+
+			switch evt.Key {
+			case 'P':
+				go instance.parent.Push("power")
+				return false
+			case 'S':
+				return true
+			case 'C':
+				return false
+			}
+		}
+	}
+}
+
+// ClearLogs erases one of the lists, or all of them.
+func (instance *CallRegisterMenu) ClearLogs(list string) {
+	// Clearing everything has no conditions, which gorm refuses by default
+	store := instance.parent.PersistStore.Session(&gorm.Session{AllowGlobalUpdate: true})
+	res := callListQuery(store, list).Delete(&db.CallLog{})
+	if res.Error != nil {
+		log.Println("⚠️ Failed to clear call log:", res.Error)
+		instance.parent.RenderAlert("alert", []string{"Error", "clearing"})
+	} else {
+		instance.parent.UpdateMissedCalls()
+		instance.parent.RenderAlert("ok", []string{"Call", "lists", "cleared"})
+	}
+	time.Sleep(2 * time.Second)
+}
+
+// ShowTimer shows the length of the last call, or the total of a list.
+func (instance *CallRegisterMenu) ShowTimer(timer string) int {
+	var total time.Duration
+	store := instance.parent.PersistStore.Model(&db.CallLog{}).Where("answered = ?", true)
+
+	switch timer {
+	case "Last call":
+		var call db.CallLog
+		if res := store.Order("start_time desc").Limit(1).Find(&call); res.Error != nil {
+			log.Println("⚠️ Failed to load call log:", res.Error)
+		}
+		total = call.Duration
+	default:
+		switch timer {
+		case "Received calls":
+			store = store.Where("inbound = ?", true)
+		case "Dialed calls":
+			store = store.Where("inbound = ?", false)
+		}
+		var sum int64
+		if res := store.Select("COALESCE(SUM(duration), 0)").Scan(&sum); res.Error != nil {
+			log.Println("⚠️ Failed to total call timers:", res.Error)
+		}
+		total = time.Duration(sum)
+	}
+
+	display := instance.parent.Display
+	display.Clear(sh1107.Black)
+
+	font := display.Use_Font8_Normal()
+	display.DrawTextAligned(0, 20, font, timer, false, sh1107.AlignRight, sh1107.AlignNone)
+
+	display.SetColor(sh1107.White)
+	display.SetLineWidth(1)
+	display.DrawLine(0, 33, 127, 33)
+	display.Stroke()
+
+	font = display.Use_Font_Time()
+	display.DrawTextAligned(64, 60, font, formatDuration(total), false, sh1107.AlignCenter, sh1107.AlignNone)
+
+	font = display.Use_Font8_Bold()
+	display.DrawTextAligned(64, 105, font, "OK", false, sh1107.AlignCenter, sh1107.AlignNone)
+	display.Render()
+
+	for {
+		select {
+		case <-instance.ctx.Done():
+			return CallRegisterActionSubmenuPushed
+		case evt := <-instance.parent.KeypadEvents:
+			if !evt.State {
+				continue
+			}
+
+			instance.parent.Timers["keypad"].Reset()
+			instance.parent.Timers["oled"].Reset()
+			instance.parent.Display.On()
+			instance.parent.Backlight.On()
+			go instance.parent.PlayKey()
+
+			switch evt.Key {
+			case 'P':
+				go instance.parent.Push("power")
+				return CallRegisterActionSubmenuPushed
+			case 'S', 'C':
+				return CallRegisterActionShowSelector
+			}
+		}
+	}
+}
+
+// CallAction runs an option picked for the call being shown.
+func (instance *CallRegisterMenu) CallAction(action string, call *db.CallLog) {
+	switch action {
+	case "Call":
+		if reason := instance.parent.DialBlockedReason(call.Number); reason != nil {
+			instance.parent.RenderAlert("prohibited", reason)
+			go instance.parent.PlayAlert()
+			time.Sleep(2 * time.Second)
+			return
+		}
+		instance.parent.Dial(call.Number)
+
+	case "Send message":
+		body := instance.parent.EnterText("Message", instance.ctx)
+		if body != "" {
+			instance.parent.SendMessage(call.Number, body)
+		}
+
+	case "Delete":
+		if res := instance.parent.PersistStore.Delete(call); res.Error != nil {
+			log.Println("⚠️ Failed to delete call log:", res.Error)
+			instance.parent.RenderAlert("alert", []string{"Error", "deleting"})
+		} else {
+			instance.parent.UpdateMissedCalls()
+			instance.parent.RenderAlert("ok", []string{"Number", "deleted"})
+		}
+		time.Sleep(2 * time.Second)
+	}
+}
+
+func (instance *CallRegisterMenu) Run() {
+	if !instance.configured {
+		panic("Attempted to call (*CallRegisterMenu).Run() before (*CallRegisterMenu).Configure()!")
+	}
+
+	log.Println("☎️ Call register started")
+
+	if !instance.process_selection {
+		// Start the selector with the base call register menu
+		log.Println("☎️ Call register switching to selector")
+		go instance.parent.PushWithArgs("selector", &SelectorArgs{
+			Title:                      "Call Register",
+			SelectionClass:             "call_register.main",
+			Options:                    instance.options,
+			ButtonLabel:                "Select",
+			VisibleRows:                3,
+			ShowElemNumberInTitle:      true,
+			ShowElemNumbersInSelection: true,
+			AllowNumberKeyShortcut:     true,
+			PersistLastState:           true,
+		})
+		return
+	}
+
+	log.Printf("☎️ Call register %s: %s", instance.selection_class, instance.selection_path)
+
+	switch instance.selection_class {
+	case "call_register.main":
+
+		// Exit to main menu
+		if len(instance.selection_path) == 0 {
+			log.Println("☎️ Call register path selected is empty, exiting...")
+			go instance.parent.Pop()
+			return
+		}
+
+		action := instance.CallRegisterMain(instance.selection_path)
+
+		switch action {
+		case CallRegisterActionExit:
+			log.Println("☎️ Call register exiting")
+			go instance.parent.Pop()
+			return
+		case CallRegisterActionSubmenuPushed:
+			// Do nothing, wait for submenu to return
+			return
+		}
+
+	case "call_register.list":
+		if len(instance.selection_path) > 0 {
+			id, ok := instance.call_cache[instance.selection_path[0]]
+			if !ok {
+				break
+			}
+			instance.current_target = id
+
+			if !instance.ShowCall(id) {
+				if instance.ctx.Err() != nil {
+					return
+				}
+				instance.ShowList(instance.list)
+				return
+			}
+
+			go instance.parent.PushWithArgs("selector", &SelectorArgs{
+				SelectionClass: "call_register.call_action",
+				Title:          "Options",
+				Options:        [][]string{{"Call"}, {"Send message"}, {"Delete"}},
+				ButtonLabel:    "Select",
+				VisibleRows:    3,
+			})
+			return
+		}
+
+	case "call_register.call_action":
+		if len(instance.selection_path) > 0 && instance.current_target != 0 {
+			var call db.CallLog
+			if res := instance.parent.PersistStore.First(&call, instance.current_target); res.Error == nil {
+				instance.CallAction(instance.selection_path[0], &call)
+			}
+			instance.current_target = 0
+			if instance.ctx.Err() != nil {
+				return
+			}
+		}
+
+		// Go back to the list the call was in
+		if instance.ShowList(instance.list) == CallRegisterActionSubmenuPushed {
+			return
+		}
+	}
+
+	instance.process_selection = false
+	log.Println("☎️ Call register switching back to selector")
+	go instance.parent.PushWithArgs("selector", &SelectorArgs{
+		Title:                      "Call Register",
+		SelectionClass:             "call_register.main",
+		Options:                    instance.options,
+		ButtonLabel:                "Select",
+		VisibleRows:                3,
+		ShowElemNumberInTitle:      true,
+		ShowElemNumbersInSelection: true,
+		AllowNumberKeyShortcut:     true,
+		PersistLastState:           true,
+	})
+}
+
+func (instance *CallRegisterMenu) Pause() {
+	instance.process_selection = true
+	instance.cancelFn()
+	if ok := waitWithTimeout(&instance.wg, 1*time.Second); !ok {
+		log.Println("⚠️ Call register handler pause timed out — goroutines may be stuck")
+		// Optional: escalate here
+	}
+}
+
+func (instance *CallRegisterMenu) Stop() {
+	instance.process_selection = false
+	instance.cancelFn()
+	if ok := waitWithTimeout(&instance.wg, 1*time.Second); !ok {
+		log.Println("⚠️ Call register handler stop timed out — goroutines may be stuck")
+		// Optional: escalate here
+	} else {
+		go instance.cleanup()
+	}
+}
+
+func (instance *CallRegisterMenu) cleanup() {
+	instance.process_selection = false
+	instance.selection_path = []string{}
+	instance.current_target = 0
+}
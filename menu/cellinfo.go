@@ -0,0 +1,279 @@
+package menu
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"phone"
+	"sh1107"
+)
+
+// How often the field test screen asks the modem again
+const cellInfoInterval = 2 * time.Second
+
+// ApplyCellInfoDisplay turns the area name broadcast on or off to match the
+// saved setting.
+func (m *Menu) ApplyCellInfoDisplay() {
+	if m.Modem == nil {
+		return
+	}
+	if err := m.Modem.SetAreaInfo(m.GlobalContext, m.Get("CellInfoDisplay").(bool)); err != nil {
+		log.Println("⚠️ Failed to set cell info display:", err)
+	}
+}
+
+// ShowCellInfoDisplay lets the area name on the home screen be turned on or
+// off, marking the current setting, or opens the field test screen.
+func (instance *SettingsMenu) ShowCellInfoDisplay() int {
+	options := [][]string{{"On"}, {"Off"}, {"Field test"}}
+	if instance.parent.Get("CellInfoDisplay").(bool) {
+		options[0][0] = "On (current)"
+	} else {
+		options[1][0] = "Off (current)"
+	}
+
+	go instance.parent.PushWithArgs("selector", &SelectorArgs{
+		SelectionClass: "settings.cell_info",
+		Title:          "Cell Info Display",
+		Options:        options,
+		ButtonLabel:    "Select",
+		VisibleRows:    3,
+	})
+	return SettingsActionSubmenuPushed
+}
+
+// SetCellInfoDisplay saves and applies the area name setting, or opens the
+// field test screen.
+func (instance *SettingsMenu) SetCellInfoDisplay(label string) int {
+	if label == "Field test" {
+		if instance.parent.Modem == nil {
+			instance.parent.RenderAlert("alert", []string{"Modem", "not found"})
+			time.Sleep(2 * time.Second)
+			return SettingsActionShowSelector
+		}
+		// Coming back from it shows the settings, rather than opening it again
+		instance.selection_path = nil
+		go instance.parent.Push("cell_info")
+		return SettingsActionSubmenuPushed
+	}
+
+	enabled := strings.HasPrefix(label, "On")
+	instance.parent.Set("CellInfoDisplay", enabled)
+	go instance.parent.SyncPersistent()
+	instance.parent.ApplyCellInfoDisplay()
+
+	if enabled {
+		instance.parent.RenderAlert("ok", []string{"Cell info", "display on"})
+	} else {
+		instance.parent.RenderAlert("ok", []string{"Cell info", "display off"})
+	}
+	time.Sleep(2 * time.Second)
+	return SettingsActionShowSelector
+}
+
+// CellInfoMenu is the field test screen, which shows the serving cell, its
+// signal levels and the cells around it, asking the modem again every couple
+// of seconds. U and D scroll.
+type CellInfoMenu struct {
+	ctx        context.Context
+	configured bool
+	cancelFn   context.CancelFunc
+	parent     *Menu
+	wg         sync.WaitGroup
+	offset     int
+}
+
+func (m *Menu) NewCellInfoMenu() *CellInfoMenu {
+	return &CellInfoMenu{
+		parent: m,
+	}
+}
+
+// Formats a signal level, or a dash if the modem didn't report it
+func signalLevel(name string, value float64, unit string) string {
+	if value == 0 {
+		return name + " -"
+	}
+	return fmt.Sprintf("%s %.1f %s", name, value, unit)
+}
+
+// The lines shown for what the modem reported
+func cellInfoLines(info *phone.CellInfo) []string {
+	if info.MCC == "" {
+		return []string{info.System}
+	}
+
+	area := "LAC"
+	if info.System == "LTE" {
+		area = "TAC"
+	}
+	lines := []string{
+		fmt.Sprintf("%s %s-%s", info.System, info.MCC, info.MNC),
+		fmt.Sprintf("%s %d CI %d", area, info.AreaCode, info.CellID),
+	}
+
+	switch info.System {
+	case "LTE":
+		lines = append(lines,
+			fmt.Sprintf("PCI %d EARFCN %d", info.PhysicalID, info.Channel),
+			info.Band,
+			signalLevel("RSRP", info.RSRP, "dBm"),
+			signalLevel("RSRQ", info.RSRQ, "dB"),
+		)
+		if info.RSRP != 0 {
+			lines = append(lines, fmt.Sprintf("SINR %.1f dB", info.SINR))
+		}
+	case "WCDMA":
+		lines = append(lines,
+			fmt.Sprintf("PSC %d UARFCN %d", info.PhysicalID, info.Channel),
+			info.Band,
+			signalLevel("RSCP", info.RSCP, "dBm"),
+			signalLevel("Ec/Io", info.EcIo, "dB"),
+		)
+	default:
+		lines = append(lines,
+			fmt.Sprintf("ARFCN %d", info.Channel),
+			info.Band,
+			signalLevel("RxLev", info.RxLev, "dBm"),
+		)
+	}
+
+	lines = append(lines, "Neighbours:")
+	if len(info.Neighbours) == 0 {
+		lines = append(lines, "None")
+	}
+	for _, cell := range info.Neighbours {
+		lines = append(lines, signalLevel(fmt.Sprintf("ARFCN %d", cell.Channel), cell.RxLev, "dBm"))
+	}
+	return lines
+}
+
+func (instance *CellInfoMenu) render(lines []string) {
+	display := instance.parent.Display
+
+	display.Clear(sh1107.Black)
+
+	font := display.Use_Font8_Normal()
+	display.DrawTextAligned(0, 20, font, "Field test", false, sh1107.AlignRight, sh1107.AlignNone)
+
+	display.SetColor(sh1107.White)
+	display.SetLineWidth(1)
+	display.DrawLine(0, 33, 127, 33)
+	display.Stroke()
+
+	instance.offset = min(instance.offset, max(len(lines)-messageVisibleLines, 0))
+	end := min(instance.offset+messageVisibleLines, len(lines))
+	for i, line := range lines[instance.offset:end] {
+		display.DrawText(0, 38+i*11, font, line, false)
+	}
+
+	font = display.Use_Font8_Bold()
+	display.DrawTextAligned(64, 105, font, "Back", false, sh1107.AlignCenter, sh1107.AlignNone)
+
+	display.Render()
+}
+
+func (instance *CellInfoMenu) Configure() {
+	// Reset context
+	instance.configured = true
+	instance.ctx, instance.cancelFn = context.WithCancel(instance.parent.GlobalContext)
+}
+
+func (instance *CellInfoMenu) ConfigureWithArgs(args ...any) {
+	// Unused
+	instance.Configure()
+}
+
+func (instance *CellInfoMenu) Run() {
+	if !instance.configured {
+		panic("Attempted to call (*CellInfoMenu).Run() before (*CellInfoMenu).Configure()!")
+	}
+
+	instance.wg.Add(1)
+	defer instance.wg.Done()
+
+	// Stay on while it's being watched
+	instance.parent.Timers["oled"].Stop()
+	instance.parent.Timers["keypad"].Stop()
+	instance.parent.Backlight.On()
+	defer instance.parent.Timers["oled"].Restart()
+	defer instance.parent.Timers["keypad"].Restart()
+
+	lines := []string{"Searching..."}
+	refresh := func() {
+		info, err := instance.parent.Modem.CellInfo(instance.ctx)
+		if err != nil {
+			log.Println("⚠️ Failed to read cell info:", err)
+			lines = []string{"No cell info"}
+		} else {
+			lines = cellInfoLines(info)
+		}
+		if instance.ctx.Err() == nil {
+			instance.render(lines)
+		}
+	}
+
+	instance.render(lines)
+	refresh()
+
+	ticker := time.NewTicker(cellInfoInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-instance.ctx.Done():
+			return
+
+		case <-ticker.C:
+			refresh()
+
+		case evt := <-instance.parent.KeypadEvents:
+			if !evt.State {
+				continue
+			}
+
+			instance.parent.Display.On()
+			instance.parent.Backlight.On()
+			go instance.parent.PlayKey()
+
+			switch evt.Key {
+			case 'P':
+				go instance.parent.Push("power")
+				return
+			case 'S', 'C':
+				go instance.parent.Pop()
+				return
+			case 'U':
+				if instance.offset > 0 {
+					instance.offset--
+					instance.render(lines)
+				}
+			case 'D':
+				instance.offset++
+				instance.render(lines)
+			}
+		}
+	}
+}
+
+func (instance *CellInfoMenu) Pause() {
+	instance.cancelFn()
+	if ok := waitWithTimeout(&instance.wg, 1*time.Second); !ok {
+		log.Println("⚠️ Cell info menu pause timed out — goroutines may be stuck")
+		// Optional: escalate here
+	}
+}
+
+func (instance *CellInfoMenu) Stop() {
+	instance.cancelFn()
+	if ok := waitWithTimeout(&instance.wg, 1*time.Second); !ok {
+		log.Println("⚠️ Cell info menu stop timed out — goroutines may be stuck")
+		// Optional: escalate here
+	} else {
+		instance.offset = 0
+	}
+}
@@ -0,0 +1,295 @@
+package menu
+
+import (
+	"errors"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"phone"
+
+	"github.com/Wifx/gonetworkmanager/v3"
+)
+
+// Name of the NetworkManager connection cellular data runs over
+const cellularConnectionID = "Rakian cellular"
+
+// Authentication types by menu label
+var apnAuthTypes = map[string]int{
+	"None":        phone.APNAuthNone,
+	"PAP":         phone.APNAuthPAP,
+	"CHAP":        phone.APNAuthCHAP,
+	"PAP or CHAP": phone.APNAuthPAPOrCHAP,
+}
+
+// Which methods NetworkManager (and ModemManager behind it) may authenticate
+// with for each type. Everything else is refused.
+var apnAuthMethods = map[string][]string{
+	"None":        {},
+	"PAP":         {"pap"},
+	"CHAP":        {"chap"},
+	"PAP or CHAP": {"pap", "chap"},
+}
+
+// Settings for the cellular connection, built from the saved APN
+func (m *Menu) cellularSettings(autoconnect bool) gonetworkmanager.ConnectionSettings {
+	gsm := map[string]any{
+		"apn": m.Get("APN").(string),
+	}
+	auth := m.Get("APNAuth").(string)
+	if auth != "None" {
+		gsm["username"] = m.Get("APNUser").(string)
+		gsm["password"] = m.Get("APNPassword").(string)
+	}
+
+	ppp := map[string]any{}
+	for _, method := range []string{"pap", "chap", "mschap", "mschapv2", "eap"} {
+		if !slices.Contains(apnAuthMethods[auth], method) {
+			ppp["refuse-"+method] = true
+		}
+	}
+
+	return gonetworkmanager.ConnectionSettings{
+		"connection": {
+			"id":          cellularConnectionID,
+			"type":        "gsm",
+			"autoconnect": autoconnect,
+		},
+		"gsm": gsm,
+		"ppp": ppp,
+	}
+}
+
+// Finds the saved cellular connection, or nil if there isn't one yet
+func findCellularConnection() (gonetworkmanager.Connection, error) {
+	settings, err := gonetworkmanager.NewSettings()
+	if err != nil {
+		return nil, err
+	}
+	conns, err := settings.ListConnections()
+	if err != nil {
+		return nil, err
+	}
+	for _, conn := range conns {
+		s, err := conn.GetSettings()
+		if err != nil {
+			continue
+		}
+		if id, ok := s["connection"]["id"].(string); ok && id == cellularConnectionID {
+			return conn, nil
+		}
+	}
+	return nil, nil
+}
+
+// Replaces the saved cellular connection with one built from the current
+// settings, and returns it
+func (m *Menu) saveCellularConnection(autoconnect bool) (gonetworkmanager.Connection, error) {
+	old, err := findCellularConnection()
+	if err != nil {
+		return nil, err
+	}
+	if old != nil {
+		if err := old.Delete(); err != nil {
+			return nil, err
+		}
+	}
+
+	settings, err := gonetworkmanager.NewSettings()
+	if err != nil {
+		return nil, err
+	}
+	return settings.AddConnection(m.cellularSettings(autoconnect))
+}
+
+// SetCellularData brings the cellular data connection up or down through
+// NetworkManager, and remembers the choice for the next start.
+func (m *Menu) SetCellularData(enabled bool) error {
+	if m.NetworkManager == nil {
+		return errors.New("NetworkManager is not available")
+	}
+
+	conn, err := m.saveCellularConnection(enabled)
+	if err != nil {
+		return err
+	}
+
+	if enabled {
+		// NetworkManager picks the modem for a gsm connection itself
+		if _, err := m.NetworkManager.ActivateConnection(conn, nil, nil); err != nil {
+			return err
+		}
+	} else {
+		active, err := m.NetworkManager.GetPropertyActiveConnections()
+		if err != nil {
+			return err
+		}
+		for _, ac := range active {
+			if id, _ := ac.GetPropertyID(); id == cellularConnectionID {
+				if err := m.NetworkManager.DeactivateConnection(ac); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	m.Set("CellularData", enabled)
+	go m.SyncPersistent()
+	if m.Modem != nil {
+		m.Modem.SetDataEnabled(enabled)
+	}
+	return nil
+}
+
+// ApplyAPN gives the saved APN to the modem, and to the cellular connection
+// if NetworkManager is running it.
+func (m *Menu) ApplyAPN() error {
+	if m.Modem != nil {
+		err := m.Modem.SetAPN(
+			m.GlobalContext,
+			m.Get("APN").(string),
+			m.Get("APNUser").(string),
+			m.Get("APNPassword").(string),
+			apnAuthTypes[m.Get("APNAuth").(string)],
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	if m.NetworkManager == nil {
+		return nil
+	}
+
+	// Reconnect with the new settings if data is on
+	if m.Get("CellularData").(bool) {
+		return m.SetCellularData(true)
+	}
+	_, err := m.saveCellularConnection(false)
+	return err
+}
+
+// ToggleData turns cellular data on or off.
+func (instance *SettingsMenu) ToggleData() int {
+	if instance.parent.NetworkManager == nil {
+		instance.parent.RenderAlert("alert", []string{"Data", "device", "error"})
+		time.Sleep(2 * time.Second)
+		return SettingsActionShowSelector
+	}
+
+	enabled := !instance.parent.Get("CellularData").(bool)
+	if enabled && !instance.parent.SIMAvailable() {
+		return SettingsActionShowSelector
+	}
+
+	if err := instance.parent.SetCellularData(enabled); err != nil {
+		log.Println("⚠️ Failed to toggle cellular data:", err)
+		instance.parent.RenderAlert("alert", []string{"Data", "connection", "failed"})
+		go instance.parent.PlayAlert()
+		time.Sleep(2 * time.Second)
+		return SettingsActionShowSelector
+	}
+
+	if enabled {
+		instance.parent.RenderAlert("ok", []string{"Turning", "data", "on"})
+	} else {
+		instance.parent.RenderAlert("ok", []string{"Turning", "data", "off"})
+	}
+	go instance.parent.PlayAlert()
+	time.Sleep(2 * time.Second)
+	return SettingsActionShowSelector
+}
+
+// ShowAPN lists the APN settings that can be changed.
+func (instance *SettingsMenu) ShowAPN() int {
+	go instance.parent.PushWithArgs("selector", &SelectorArgs{
+		SelectionClass: "settings.apn",
+		Title:          "Configure APN",
+		Options:        [][]string{{"Access point"}, {"User name"}, {"Password"}, {"Authentication"}},
+		ButtonLabel:    "Select",
+		VisibleRows:    3,
+	})
+	return SettingsActionSubmenuPushed
+}
+
+// Keys the APN text settings are saved under, by menu label
+var apnFields = map[string]string{
+	"Access point": "APN",
+	"User name":    "APNUser",
+	"Password":     "APNPassword",
+}
+
+// EditAPN changes one of the APN settings and applies it. Authentication is
+// picked from a list, everything else is typed in.
+func (instance *SettingsMenu) EditAPN(field string) int {
+	if field == "Authentication" {
+		current := instance.parent.Get("APNAuth").(string)
+		var options [][]string
+		for _, auth := range []string{"None", "PAP", "CHAP", "PAP or CHAP"} {
+			if auth == current {
+				auth += " (current)"
+			}
+			options = append(options, []string{auth})
+		}
+
+		go instance.parent.PushWithArgs("selector", &SelectorArgs{
+			SelectionClass: "settings.apn_auth",
+			Title:          "Authentication",
+			Options:        options,
+			ButtonLabel:    "Select",
+			VisibleRows:    3,
+		})
+		return SettingsActionSubmenuPushed
+	}
+
+	key, ok := apnFields[field]
+	if !ok {
+		return instance.ShowAPN()
+	}
+
+	// Clearing the field and pressing OK leaves it empty
+	value := instance.parent.EnterTextWithDefault(field, instance.parent.Get(key).(string), instance.ctx)
+	if instance.ctx.Err() != nil {
+		return SettingsActionSubmenuPushed
+	}
+	instance.parent.Set(key, value)
+	return instance.saveAPN()
+}
+
+// SetAPNAuth changes how the APN user name and password are checked.
+func (instance *SettingsMenu) SetAPNAuth(label string) int {
+	auth := strings.TrimSuffix(label, " (current)")
+	if _, ok := apnAuthTypes[auth]; !ok {
+		return instance.ShowAPN()
+	}
+	instance.parent.Set("APNAuth", auth)
+	return instance.saveAPN()
+}
+
+// Saves and applies the APN settings, then goes back to the list of them
+func (instance *SettingsMenu) saveAPN() int {
+	go instance.parent.SyncPersistent()
+
+	instance.parent.RenderAlert("loading", []string{"Saving", "APN..."})
+	if err := instance.parent.ApplyAPN(); err != nil {
+		log.Println("⚠️ Failed to apply APN:", err)
+		var cme *phone.CMEError
+		var result *phone.ResultError
+		switch {
+		case errors.Is(err, phone.ErrNoModem):
+			instance.parent.RenderAlert("alert", []string{"Modem", "not found"})
+		case errors.Is(err, phone.ErrTimeout):
+			instance.parent.RenderAlert("alert", []string{"Modem", "not", "answering"})
+		case errors.As(err, &cme), errors.As(err, &result):
+			instance.parent.RenderAlert("alert", []string{"APN", "rejected", "by modem"})
+		default:
+			instance.parent.RenderAlert("alert", []string{"APN", "not applied"})
+		}
+		go instance.parent.PlayAlert()
+	} else {
+		instance.parent.RenderAlert("ok", []string{"APN", "saved"})
+	}
+	time.Sleep(2 * time.Second)
+	return instance.ShowAPN()
+}
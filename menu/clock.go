@@ -0,0 +1,244 @@
+package menu
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"golang.org/x/sys/unix"
+)
+
+// Where the time comes from, by menu label
+const (
+	ClockNetwork = "Network time"
+	ClockNTP     = "NTP"
+	ClockManual  = "Manual"
+)
+
+var clockModes = []string{ClockNetwork, ClockNTP, ClockManual}
+
+// Leave the clock alone unless the network's time is further off than this
+const clockTolerance = 2 * time.Second
+
+// The systemd service that sets the clock and time zone
+func timedated() (dbus.BusObject, error) {
+	conn, err := dbus.SystemBus()
+	if err != nil {
+		return nil, err
+	}
+	return conn.Object("org.freedesktop.timedate1", "/org/freedesktop/timedate1"), nil
+}
+
+// Turns NTP on or off through timedated
+func setNTP(enabled bool) error {
+	obj, err := timedated()
+	if err != nil {
+		return err
+	}
+	return obj.Call("org.freedesktop.timedate1.SetNTP", 0, enabled, false).Err
+}
+
+// Sets the system clock through timedated, or with settimeofday if it isn't
+// running
+func setSystemTime(t time.Time) error {
+	if obj, err := timedated(); err == nil {
+		// timedated won't set the time while NTP is on
+		err = obj.Call("org.freedesktop.timedate1.SetNTP", 0, false, false).Err
+		if err == nil {
+			err = obj.Call("org.freedesktop.timedate1.SetTime", 0, t.UnixMicro(), false, false).Err
+		}
+		if err == nil {
+			return nil
+		}
+		log.Println("⚠️ timedated couldn't set the time:", err)
+	}
+
+	tv := unix.NsecToTimeval(t.UnixNano())
+	return unix.Settimeofday(&tv)
+}
+
+// Name for a UTC offset, like UTC+5:45
+func zoneName(offset int) string {
+	sign := '+'
+	if offset < 0 {
+		sign = '-'
+		offset = -offset
+	}
+	hours, minutes := offset/3600, offset%3600/60
+	if minutes == 0 {
+		return fmt.Sprintf("UTC%c%d", sign, hours)
+	}
+	return fmt.Sprintf("UTC%c%d:%02d", sign, hours, minutes)
+}
+
+// Switches the clock to the network's time zone. The home screen picks it up
+// straight away, and timedated keeps it across restarts when it's a whole
+// number of hours, which is all the Etc zones cover.
+func (m *Menu) setTimeZone(offset int, dst int) {
+	name := zoneName(offset)
+	if dst > 0 {
+		name += " DST"
+	}
+	time.Local = time.FixedZone(name, offset)
+
+	if offset%3600 != 0 || m.Get("DebugMode").(bool) {
+		return
+	}
+
+	// Etc zones count the other way round, Etc/GMT-2 is UTC+2
+	zone := "UTC"
+	if offset != 0 {
+		zone = fmt.Sprintf("Etc/GMT%+d", -offset/3600)
+	}
+	obj, err := timedated()
+	if err == nil {
+		err = obj.Call("org.freedesktop.timedate1.SetTimezone", 0, zone, false).Err
+	}
+	if err != nil {
+		log.Println("⚠️ Failed to set time zone:", err)
+	}
+}
+
+// ApplyClockMode turns NTP on or off to match the saved clock setting.
+func (m *Menu) ApplyClockMode() {
+	if m.Get("DebugMode").(bool) {
+		return
+	}
+	if err := setNTP(m.Get("ClockMode").(string) == ClockNTP); err != nil {
+		log.Println("⚠️ Failed to set NTP:", err)
+	}
+}
+
+// SetNetworkTime takes the time and time zone the network sent, as the
+// clock setting allows. NTP still leaves the time zone to the network, since
+// it doesn't know about one.
+func (m *Menu) SetNetworkTime(t time.Time, dst int) {
+	mode := m.Get("ClockMode").(string)
+	if mode == ClockManual {
+		return
+	}
+
+	_, offset := t.Zone()
+	m.setTimeZone(offset, dst)
+	if mode != ClockNetwork {
+		return
+	}
+
+	drift := time.Since(t)
+	if drift < clockTolerance && drift > -clockTolerance {
+		return
+	}
+	if m.Get("DebugMode").(bool) {
+		log.Printf("🕒 Debug mode, not moving the clock by %s", drift)
+		return
+	}
+	if err := setSystemTime(t); err != nil {
+		log.Println("⚠️ Failed to set the clock:", err)
+		return
+	}
+	log.Println("🕒 Clock set from the network:", t.Format(time.RFC3339))
+}
+
+// ShowClock lets the time source be picked, marking the current one.
+func (instance *SettingsMenu) ShowClock() int {
+	current := instance.parent.Get("ClockMode").(string)
+	var options [][]string
+	for _, mode := range clockModes {
+		if mode == current {
+			mode += " (current)"
+		}
+		options = append(options, []string{mode})
+	}
+
+	go instance.parent.PushWithArgs("selector", &SelectorArgs{
+		SelectionClass: "settings.clock",
+		Title:          "Clock",
+		Options:        options,
+		ButtonLabel:    "Select",
+		VisibleRows:    3,
+	})
+	return SettingsActionSubmenuPushed
+}
+
+// SetClockMode saves and applies where the time comes from. Manual asks for
+// the time and date straight away.
+func (instance *SettingsMenu) SetClockMode(label string) int {
+	mode := strings.TrimSuffix(label, " (current)")
+	switch mode {
+	case ClockNetwork, ClockNTP, ClockManual:
+	default:
+		return SettingsActionShowSelector
+	}
+
+	if instance.parent.Get("DebugMode").(bool) {
+		instance.parent.RenderAlert("ok", []string{"Debug", "mode", "failsafe!"})
+		time.Sleep(2 * time.Second)
+		return SettingsActionShowSelector
+	}
+
+	if mode == ClockManual {
+		t, ok := instance.enterDateTime()
+		if !ok {
+			if instance.ctx.Err() != nil {
+				return SettingsActionSubmenuPushed
+			}
+			return SettingsActionShowSelector
+		}
+		if err := setSystemTime(t); err != nil {
+			log.Println("⚠️ Failed to set the clock:", err)
+			instance.parent.RenderAlert("alert", []string{"Clock", "not set"})
+			go instance.parent.PlayAlert()
+			time.Sleep(2 * time.Second)
+			return SettingsActionShowSelector
+		}
+	}
+
+	instance.parent.Set("ClockMode", mode)
+	go instance.parent.SyncPersistent()
+	instance.parent.ApplyClockMode()
+
+	// The network only sends its time now and then, so ask for it
+	if mode == ClockNetwork && instance.parent.Modem != nil {
+		go instance.parent.Modem.RequestNetworkTime()
+	}
+
+	instance.parent.RenderAlert("ok", []string{mode, "selected"})
+	time.Sleep(2 * time.Second)
+	return SettingsActionShowSelector
+}
+
+// Asks for the time and then the date, in the local time zone. Returns false
+// if either was cancelled or doesn't make sense.
+func (instance *SettingsMenu) enterDateTime() (time.Time, bool) {
+	now := time.Now()
+
+	clock := digitsOnly(instance.parent.EnterTextInMode("Time (hhmm)", now.Format("1504"), T9Numbers, instance.ctx))
+	if clock == "" {
+		return time.Time{}, false
+	}
+	date := digitsOnly(instance.parent.EnterTextInMode("Date (ddmmyyyy)", now.Format("02012006"), T9Numbers, instance.ctx))
+	if date == "" {
+		return time.Time{}, false
+	}
+
+	t, err := time.ParseInLocation("020120061504", date+clock, time.Local)
+	if err != nil {
+		instance.parent.RenderAlert("alert", []string{"Invalid", "time"})
+		go instance.parent.PlayAlert()
+		time.Sleep(2 * time.Second)
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// Drops anything that isn't a digit, like separators typed in
+func digitsOnly(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r < '0' || r > '9' {
+			return -1
+		}
+		return r
+	}, s)
+}
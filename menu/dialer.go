@@ -3,14 +3,19 @@ package menu
 import (
 	"context"
 	"log"
+	"strings"
 	"sync"
 	"time"
 
-	"misc"
+	"phone"
 	"sh1107"
 	"timers"
 )
 
+// How long * or # has to be held to make a pause or a wait, for tones sent
+// after the number
+const toneHold = time.Second
+
 type DialerMenu struct {
 	ctx              context.Context
 	configured       bool
@@ -20,6 +25,9 @@ type DialerMenu struct {
 	dial_number      string
 	lastAsteriskTime time.Time
 	pressStart       map[rune]time.Time
+	contacts         []contactEntry // Loaded when the dialer opens, for suggestions
+	suggestion       *contactEntry
+	speed_dial_key   rune // The key the dialer was opened with, until another is pressed
 }
 
 func (m *Menu) NewDialerMenu() *DialerMenu {
@@ -30,9 +38,33 @@ func (m *Menu) NewDialerMenu() *DialerMenu {
 	}
 }
 
+// Finds a contact for the digits typed so far: one whose number matches, or
+// failing that one whose number contains them
+func suggestContact(entries []contactEntry, typed string) *contactEntry {
+	digits := normalizeNumber(typed)
+	if len(digits) < 3 {
+		return nil
+	}
+	if entry := findContactEntry(entries, typed); entry != nil {
+		return entry
+	}
+	for i := range entries {
+		if strings.Contains(normalizeNumber(entries[i].Number), digits) {
+			return &entries[i]
+		}
+	}
+	return nil
+}
+
 func (instance *DialerMenu) render() {
+	number, _ := phone.SplitDialString(instance.dial_number)
+	instance.suggestion = suggestContact(instance.contacts, number)
+
 	instance.parent.Display.Clear(sh1107.Black)
 	instance.parent.Display.DrawText(0, 40, instance.parent.Display.Use_Font16(), instance.dial_number, false)
+	if instance.suggestion != nil {
+		instance.parent.Display.DrawText(0, 62, instance.parent.Display.Use_Font8_Normal(), instance.suggestion.Name, false)
+	}
 	instance.parent.Display.DrawTextAligned(64, 105, instance.parent.Display.Use_Font8_Bold(), "Call", false, sh1107.AlignCenter, sh1107.AlignNone)
 	instance.parent.Display.Render()
 }
@@ -53,9 +85,14 @@ func (instance *DialerMenu) Run() {
 		panic("Attempted to call (*DialerMenu).Run() before (*DialerMenu).Configure()!")
 	}
 
+	instance.contacts = instance.parent.contactEntries()
+
 	if instance.parent.Get("InitialKey") != ' ' {
 		instance.dial_number = ""
 		instance.dial_number += string(instance.parent.Get("InitialKey").(rune))
+		if strings.ContainsRune(SpeedDialKeys, instance.parent.Get("InitialKey").(rune)) {
+			instance.speed_dial_key = instance.parent.Get("InitialKey").(rune)
+		}
 		instance.parent.Set("InitialKey", ' ')
 	}
 	instance.render()
@@ -68,12 +105,34 @@ func (instance *DialerMenu) Run() {
 			return
 
 		case evt := <-instance.parent.KeypadEvents:
+			// Holding down the key that opened the dialer speed dials
+			if !evt.State && evt.Key == instance.speed_dial_key && evt.Duration >= speedDialHold.Seconds() {
+				instance.speedDial(evt.Key)
+				return
+			}
+
+			// Holding * turns it into a pause and # into a wait
+			if !evt.State && evt.Duration >= toneHold.Seconds() {
+				runes := []rune(instance.dial_number)
+				if n := len(runes); n > 0 && runes[n-1] == evt.Key {
+					switch evt.Key {
+					case '*':
+						runes[n-1] = phone.DTMFPause
+					case '#':
+						runes[n-1] = phone.DTMFWait
+					}
+					instance.dial_number = string(runes)
+					instance.render()
+				}
+			}
+
 			if evt.State {
+				instance.speed_dial_key = 0
 
 				instance.parent.Timers["keypad"].Reset()
 				instance.parent.Timers["oled"].Reset()
 				instance.parent.Display.On()
-				misc.KeyLightsOn()
+				instance.parent.Backlight.On()
 				switch evt.Key {
 				case '*':
 					go instance.parent.PlayKey()
@@ -119,32 +178,39 @@ func (instance *DialerMenu) Run() {
 					go instance.parent.PlayKey()
 				case 'D':
 					go instance.parent.PlayKey()
+
+					// Take the suggested contact's number
+					if instance.suggestion != nil {
+						instance.dial_number = instance.suggestion.Number
+						instance.render()
+					}
 				case 'S':
 					if len(instance.dial_number) == 0 {
 						continue
 					}
 
-					if instance.parent.Modem == nil {
-						instance.ExitWithAlert([]string{"No", "service!"})
-						return
-
-					} else if instance.parent.Modem.FlightMode {
-						instance.ExitWithAlert([]string{"Airplane", "mode", "enabled."})
+					// Codes like *611# go to the network as USSD, not as calls
+					if phone.IsUSSD(instance.dial_number) {
+						go instance.parent.PlayKey()
+						go instance.parent.PopToMenuWithArgs("ussd", instance.dial_number)
 						return
+					}
 
-					} else if !instance.parent.Modem.SimCardInserted {
-						instance.ExitWithAlert([]string{"Insert a", "SIM card", "to continue."})
+					// Emergency numbers go through no matter what
+					if reason := instance.parent.DialBlockedReason(instance.dial_number); reason != nil {
+						instance.ExitWithAlert(reason)
 						return
+					}
 
-					} else if !instance.parent.Modem.Connected {
-						instance.ExitWithAlert([]string{"No", "service!"})
-						return
+					go instance.parent.PlayKey()
+					inCall := len(instance.parent.Modem.Calls()) > 0
+					instance.parent.Dial(instance.dial_number)
 
-					} else {
-						go instance.parent.PlayKey()
-						instance.parent.Modem.Dial(instance.dial_number)
-						return
+					// A new call during another goes back to the call screen
+					if inCall {
+						go instance.parent.Pop()
 					}
+					return
 
 				default:
 					instance.dial_number += string(evt.Key)
@@ -176,6 +242,8 @@ func (instance *DialerMenu) Stop() {
 
 func (instance *DialerMenu) cleanup() {
 	instance.dial_number = ""
+	instance.suggestion = nil
+	instance.speed_dial_key = 0
 	instance.pressStart = make(map[rune]time.Time)
 }
 
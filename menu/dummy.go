@@ -6,7 +6,6 @@ import (
 	"sync"
 	"time"
 
-	"misc"
 	"sh1107"
 	"timers"
 )
@@ -78,7 +77,7 @@ func (instance *DummyMenu) Run() {
 					instance.parent.Timers["keypad"].Reset()
 					instance.parent.Timers["oled"].Reset()
 					instance.parent.Display.On()
-					misc.KeyLightsOn()
+					instance.parent.Backlight.On()
 					go instance.parent.PlayKey()
 
 					if evt.Key == 'P' {
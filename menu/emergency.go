@@ -0,0 +1,170 @@
+package menu
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"sh1107"
+	"timers"
+)
+
+// EmergencyMenu dials emergency numbers only, for when the phone can't make
+// other calls. It's opened with the SOS key on the no SIM and SIM lock
+// screens, and starts with 112 typed in. Given a number, it calls it straight
+// away.
+type EmergencyMenu struct {
+	ctx         context.Context
+	configured  bool
+	cancelFn    context.CancelFunc
+	parent      *Menu
+	wg          sync.WaitGroup
+	dial_number string
+	dial_now    bool
+}
+
+func (m *Menu) NewEmergencyMenu() *EmergencyMenu {
+	return &EmergencyMenu{
+		parent: m,
+	}
+}
+
+func (instance *EmergencyMenu) render() {
+	display := instance.parent.Display
+	display.Clear(sh1107.Black)
+
+	font := display.Use_Font8_Normal()
+	display.DrawTextAligned(0, 20, font, "Emergency call", false, sh1107.AlignRight, sh1107.AlignNone)
+	display.DrawImageAligned(instance.parent.Sprites["cell/sos"], 127, 20, sh1107.AlignLeft, sh1107.AlignNone)
+
+	display.SetColor(sh1107.White)
+	display.DrawLine(0, 33, 127, 33)
+	display.Stroke()
+
+	display.DrawText(0, 50, display.Use_Font16(), instance.dial_number, false)
+
+	if instance.parent.IsEmergencyNumber(instance.dial_number) {
+		font = display.Use_Font8_Bold()
+		display.DrawTextAligned(64, 105, font, "Call", false, sh1107.AlignCenter, sh1107.AlignNone)
+	} else {
+		display.DrawTextAligned(64, 80, font, "Emergency calls only", false, sh1107.AlignCenter, sh1107.AlignNone)
+	}
+	display.Render()
+}
+
+func (instance *EmergencyMenu) Configure() {
+	// Reset context
+	instance.configured = true
+	instance.ctx, instance.cancelFn = context.WithCancel(instance.parent.GlobalContext)
+	instance.dial_number = "112"
+	instance.dial_now = false
+}
+
+func (instance *EmergencyMenu) ConfigureWithArgs(args ...any) {
+	instance.Configure()
+
+	// Call the number typed in elsewhere
+	if len(args) > 0 {
+		number, ok := args[0].(string)
+		if !ok {
+			panic("(*EmergencyMenu).ConfigureWithArgs() Type error: argument must be a string")
+		}
+		instance.dial_number = number
+		instance.dial_now = true
+	}
+}
+
+// Places the emergency call, or goes back if it couldn't be
+func (instance *EmergencyMenu) call() {
+	log.Println("🆘 Emergency call to", instance.dial_number)
+	instance.parent.RenderAlert("loading", []string{"Emergency", "call..."})
+	if err := instance.parent.Dial(instance.dial_number); err != nil {
+		log.Println("⚠️ Emergency call failed:", err)
+		instance.parent.RenderAlert("alert", []string{"Call", "failed."})
+		go instance.parent.PlayAlert()
+		timers.SleepWithContext(3*time.Second, instance.ctx)
+		go instance.parent.Pop()
+	}
+}
+
+func (instance *EmergencyMenu) Run() {
+	if !instance.configured {
+		panic("Attempted to call (*EmergencyMenu).Run() before (*EmergencyMenu).Configure()!")
+	}
+
+	instance.parent.Backlight.On()
+	if instance.dial_now && instance.parent.IsEmergencyNumber(instance.dial_number) {
+		instance.call()
+		return
+	}
+	instance.render()
+
+	instance.wg.Add(1)
+	defer instance.wg.Done()
+	for {
+		select {
+		case <-instance.ctx.Done():
+			return
+
+		case evt := <-instance.parent.KeypadEvents:
+			if !evt.State {
+				continue
+			}
+
+			instance.parent.Timers["keypad"].Reset()
+			instance.parent.Timers["oled"].Reset()
+			instance.parent.Display.On()
+			instance.parent.Backlight.On()
+
+			switch evt.Key {
+			case 'C':
+				go instance.parent.PlayKey()
+				runes := []rune(instance.dial_number)
+				if len(runes) == 0 {
+					go instance.parent.Pop()
+					return
+				}
+				instance.dial_number = string(runes[:len(runes)-1])
+				instance.render()
+
+			case 'P':
+				go instance.parent.PlayKey()
+				go instance.parent.Push("power")
+				return
+
+			case 'U', 'D', '*', '#':
+				go instance.parent.PlayKey()
+
+			case 'S':
+				if !instance.parent.IsEmergencyNumber(instance.dial_number) {
+					continue
+				}
+				go instance.parent.PlayKey()
+				instance.call()
+				return
+
+			default:
+				go instance.parent.PlayKey()
+				instance.dial_number += string(evt.Key)
+				instance.render()
+			}
+		}
+	}
+}
+
+func (instance *EmergencyMenu) Pause() {
+	instance.cancelFn()
+	if ok := waitWithTimeout(&instance.wg, 1*time.Second); !ok {
+		log.Println("⚠️ Emergency menu pause timed out — goroutines may be stuck")
+		// Optional: escalate here
+	}
+}
+
+func (instance *EmergencyMenu) Stop() {
+	instance.cancelFn()
+	if ok := waitWithTimeout(&instance.wg, 1*time.Second); !ok {
+		log.Println("⚠️ Emergency menu stop timed out — goroutines may be stuck")
+		// Optional: escalate here
+	}
+}
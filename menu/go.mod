@@ -15,6 +15,8 @@ replace (
 require (
 	db v0.0.0-00010101000000-000000000000
 	github.com/Wifx/gonetworkmanager/v3 v3.2.0
+	github.com/godbus/dbus/v5 v5.1.0
+	golang.org/x/sys v0.41.0
 	gorm.io/gorm v1.31.1
 	keypad v0.0.0-00010101000000-000000000000
 	misc v0.0.0-00010101000000-000000000000
@@ -31,7 +33,6 @@ require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/fogleman/gg v1.3.0 // indirect
 	github.com/go-ole/go-ole v1.2.6 // indirect
-	github.com/godbus/dbus/v5 v5.1.0 // indirect
 	github.com/golang/freetype v0.0.0-20170609003504-e2365dfdc4a0 // indirect
 	github.com/jinzhu/inflection v1.0.0 // indirect
 	github.com/jinzhu/now v1.1.5 // indirect
@@ -46,7 +47,6 @@ require (
 	github.com/warthog618/sms v0.3.0 // indirect
 	golang.org/x/exp v0.0.0-20241204233417-43b7b7cde48d // indirect
 	golang.org/x/image v0.35.0 // indirect
-	golang.org/x/sys v0.41.0 // indirect
 	golang.org/x/text v0.33.0 // indirect
 	periph.io/x/conn/v3 v3.7.2 // indirect
 	periph.io/x/host/v3 v3.8.5 // indirect
@@ -7,7 +7,6 @@ import (
 	"sync"
 	"time"
 
-	"misc"
 	"sh1107"
 	"timers"
 )
@@ -65,24 +64,66 @@ func (instance *HomeMenu) render() {
 	if instance.parent.Modem == nil {
 		carrier_label = "No service"
 
-	} else if instance.parent.Modem.FlightMode {
+	} else if state := instance.parent.Modem.State(); state.Fault {
+		carrier_label = "No service"
+
+	} else if state.FlightMode {
 		carrier_label = "Airplane mode"
 
-	} else if !instance.parent.Modem.SimCardInserted {
+	} else if state.SIMLock != "" {
+		carrier_label = "SIM locked"
+
+	} else if !state.SimCardInserted {
 		carrier_label = "Insert SIM card"
 
 	} else {
-		carrier_label = instance.parent.Modem.Carrier
+		carrier_label = state.Carrier
 	}
 	display.DrawTextAligned(64, 75, font, carrier_label, false, sh1107.AlignCenter, sh1107.AlignNone)
 
+	// Draw missed call notice, or the area name in its place
+	if missed := instance.parent.Get("MissedCalls").(int); missed == 1 {
+		display.DrawTextAligned(64, 88, font, "1 missed call", false, sh1107.AlignCenter, sh1107.AlignNone)
+	} else if missed > 1 {
+		display.DrawTextAligned(64, 88, font, fmt.Sprintf("%d missed calls", missed), false, sh1107.AlignCenter, sh1107.AlignNone)
+	} else if area := instance.areaName(); area != "" {
+		display.DrawTextAligned(64, 88, font, area, false, sh1107.AlignCenter, sh1107.AlignNone)
+	}
+
 	// Draw menu hint
 	font = display.Use_Font8_Bold()
 	display.DrawTextAligned(64, 105, font, "Menu", false, sh1107.AlignCenter, sh1107.AlignNone)
 
+	// Without a SIM, C opens the emergency dialer
+	if instance.emergencyOnly() && instance.parent.Get("MissedCalls").(int) == 0 {
+		display.DrawTextAligned(127, 105, font, "SOS", false, sh1107.AlignLeft, sh1107.AlignNone)
+	}
+
 	display.Render()
 }
 
+// The area name from cell broadcast, if Cell Info Display is on and we're on
+// the network
+func (instance *HomeMenu) areaName() string {
+	if instance.parent.Modem == nil || !instance.parent.Get("CellInfoDisplay").(bool) {
+		return ""
+	}
+	state := instance.parent.Modem.State()
+	if !state.Connected || state.Fault || state.FlightMode {
+		return ""
+	}
+	return state.AreaName
+}
+
+// Whether there's no SIM, so only emergency calls can be made
+func (instance *HomeMenu) emergencyOnly() bool {
+	if instance.parent.Modem == nil {
+		return false
+	}
+	state := instance.parent.Modem.State()
+	return !state.Fault && !state.FlightMode && state.SIMLock == "" && !state.SimCardInserted
+}
+
 func (instance *HomeMenu) Configure() {
 	// Reset context
 	instance.configured = true
@@ -99,6 +140,12 @@ func (instance *HomeMenu) Run() {
 		panic("Attempted to call (*HomeMenu).Run() before (*HomeMenu).Configure()!")
 	}
 
+	// A locked SIM needs its code before anything else
+	if instance.parent.Modem != nil && instance.parent.Modem.State().SIMLock != "" {
+		go instance.parent.Push("sim_lock")
+		return
+	}
+
 	// Battery icon blinker
 	instance.wg.Go(func() {
 		for {
@@ -158,7 +205,7 @@ func (instance *HomeMenu) Run() {
 					instance.parent.Timers["keypad"].Reset()
 					instance.parent.Timers["oled"].Reset()
 					instance.parent.Display.On()
-					misc.KeyLightsOn()
+					instance.parent.Backlight.On()
 					go instance.parent.PlayKey()
 
 					switch evt.Key {
@@ -175,7 +222,15 @@ func (instance *HomeMenu) Run() {
 					case 'D':
 						// TODO: cycle between different home menus
 					case 'C':
+						// Dismiss the missed call notice
+						if instance.parent.Get("MissedCalls").(int) > 0 {
+							instance.parent.DismissMissedCalls()
+						} else if instance.emergencyOnly() {
+							go instance.parent.Push("emergency")
+							return
+						}
 					default:
+						// The dialer speed dials if the key is held
 						instance.parent.Set("InitialKey", evt.Key)
 						go instance.parent.Push("dialer")
 						return
@@ -7,7 +7,6 @@ import (
 	"sync"
 	"time"
 
-	"misc"
 	"sh1107"
 )
 
@@ -72,9 +71,18 @@ func (instance *HomeSelectionMenu) handle_selection() {
 	case 0: // Phone Book
 		log.Println("Phone Book selected")
 		go instance.parent.PopToMenu("phonebook")
+	case 1: // Messages
+		log.Println("Messages selected")
+		go instance.parent.PopToMenu("messages")
+	case 2: // Call Register
+		log.Println("Call Register selected")
+		go instance.parent.PopToMenu("call_register")
 	case 3: // Settings
 		log.Println("Settings selected")
 		go instance.parent.PopToMenu("settings")
+	case 4: // Call Divert
+		log.Println("Call Divert selected")
+		go instance.parent.PopToMenu("call_divert")
 	case 6: // Calculator
 		log.Println("Calculator selected")
 		go instance.parent.PopToMenu("calculator")
@@ -114,7 +122,7 @@ func (instance *HomeSelectionMenu) Run() {
 					instance.parent.Timers["keypad"].Reset()
 					instance.parent.Timers["oled"].Reset()
 					instance.parent.Display.On()
-					misc.KeyLightsOn()
+					instance.parent.Backlight.On()
 
 					switch evt.Key {
 					case 'U':
@@ -10,6 +10,7 @@ import (
 
 	"db"
 	"keypad"
+	"misc"
 	"phone"
 	"sh1107"
 	"timers"
@@ -40,7 +41,8 @@ type Menu struct {
 	Modem          *phone.Modem
 	KeypadEvents   <-chan *keypad.KeypadEvent
 	Timers         map[string]*timers.ResettableTimer
-	Player         *tones.Tones
+	Player         tones.TonePlayer
+	Backlight      misc.Backlight
 	GlobalStorage  *sync.Map
 	PersistStore   *gorm.DB
 	persistable    []string
@@ -446,7 +448,8 @@ func Init(
 	display *sh1107.SH1107,
 	sprites map[string]image.Image,
 	modem *phone.Modem,
-	player *tones.Tones,
+	player tones.TonePlayer,
+	backlight misc.Backlight,
 	globalquit func(uint8),
 	keypadevents <-chan *keypad.KeypadEvent,
 	persist *gorm.DB,
@@ -465,6 +468,7 @@ func Init(
 		KeypadEvents:   keypadevents,
 		Timers:         make(map[string]*timers.ResettableTimer),
 		Player:         player,
+		Backlight:      backlight,
 		GlobalQuit:     globalquit,
 		masked:         false,
 		GlobalStorage:  &sync.Map{},
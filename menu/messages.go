@@ -0,0 +1,463 @@
+package menu
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"db"
+	"phone"
+	"sh1107"
+)
+
+const (
+	MessagesActionExit = iota
+	MessagesActionShowSelector
+	MessagesActionSubmenuPushed
+)
+
+// How many lines of a message fit between the status bar and the softkey
+const messageVisibleLines = 6
+
+type MessagesMenu struct {
+	ctx               context.Context
+	configured        bool
+	cancelFn          context.CancelFunc
+	parent            *Menu
+	wg                sync.WaitGroup
+	process_selection bool
+	selection_class   string
+	selection_path    []string
+	options           [][]string
+	msg_cache         map[string]uint // Selector label -> message ID
+	outbox            bool            // Which folder msg_cache was built from
+	current_target    uint
+}
+
+func (*MessagesMenu) Label() string {
+	return "Messages Menu"
+}
+
+func (m *Menu) NewMessagesMenu() *MessagesMenu {
+	return &MessagesMenu{
+		parent:            m,
+		process_selection: false,
+		selection_path:    []string{},
+		msg_cache:         make(map[string]uint),
+		options: [][]string{
+			{"Inbox"},
+			{"Outbox"},
+			{"Write message"},
+		},
+	}
+}
+
+// SaveIncomingMessage stores a received text message in the inbox.
+func (m *Menu) SaveIncomingMessage(sms *phone.SMS) {
+	msg := &db.SMSMessage{
+		Number:    sms.Sender,
+		Body:      sms.Body,
+		Timestamp: sms.Timestamp,
+	}
+	if res := m.PersistStore.Create(msg); res.Error != nil {
+		log.Println("⚠️ Failed to save message:", res.Error)
+	}
+	m.UpdateUnreadMessages()
+}
+
+// UpdateUnreadMessages recounts the unread messages in the inbox, which
+// drives the envelope in the status bar.
+func (m *Menu) UpdateUnreadMessages() {
+	var count int64
+	res := m.PersistStore.Model(&db.SMSMessage{}).Where("outgoing = ? AND read = ?", false, false).Count(&count)
+	if res.Error != nil {
+		log.Println("⚠️ Failed to count unread messages:", res.Error)
+		return
+	}
+	m.Set("UnreadMessages", int(count))
+}
+
+// SendMessage sends a text message, shows the outcome, and stores the message
+// in the outbox if it went through.
+func (m *Menu) SendMessage(number string, body string) bool {
+	if m.Modem == nil {
+		m.RenderAlert("prohibited", []string{"No", "service"})
+		go m.PlayAlert()
+		time.Sleep(2 * time.Second)
+		return false
+	}
+
+	m.RenderAlert("loading", []string{"Sending", "message..."})
+
+	if err := m.Modem.SendSMS(m.GlobalContext, number, body); err != nil {
+		log.Println("⚠️ Failed to send message:", err)
+		m.RenderAlert("alert", []string{"Message", "sending", "failed."})
+		go m.PlayAlert()
+		time.Sleep(2 * time.Second)
+		return false
+	}
+
+	msg := &db.SMSMessage{
+		Number:    number,
+		Body:      body,
+		Timestamp: time.Now(),
+		Outgoing:  true,
+		Read:      true,
+	}
+	if res := m.PersistStore.Create(msg); res.Error != nil {
+		log.Println("⚠️ Failed to save sent message:", res.Error)
+	}
+
+	m.RenderAlert("ok", []string{"Message", "sent"})
+	time.Sleep(2 * time.Second)
+	return true
+}
+
+func (instance *MessagesMenu) Configure() {
+	// Reset context
+	instance.configured = true
+	instance.ctx, instance.cancelFn = context.WithCancel(instance.parent.GlobalContext)
+}
+
+func (instance *MessagesMenu) ConfigureWithArgs(args ...any) {
+
+	// Check if we have args
+	if len(args) > 0 {
+
+		// Most likely our arg is a SelectorReturn from the selector.
+		selection, ok := args[0].(*SelectorReturn)
+		if !ok {
+			panic("(*MessagesMenu).ConfigureWithArgs() Type error: argument must be a *SelectorReturn type")
+		}
+
+		instance.process_selection = true
+		instance.selection_path = selection.SelectionPath
+		instance.selection_class = selection.SelectionClass
+	}
+
+	instance.Configure()
+}
+
+// MessagesMain handles an entry picked from the main messages menu.
+func (instance *MessagesMenu) MessagesMain(selection_path []string) int {
+	switch selection_path[len(selection_path)-1] {
+	case "Inbox":
+		return instance.ShowFolder(false)
+	case "Outbox":
+		return instance.ShowFolder(true)
+	case "Write message":
+		instance.WriteMessage("")
+		if instance.ctx.Err() != nil {
+			return MessagesActionSubmenuPushed
+		}
+	}
+
+	return MessagesActionShowSelector
+}
+
+// ShowFolder lists the messages in the inbox or outbox, newest first.
+func (instance *MessagesMenu) ShowFolder(outbox bool) int {
+	var messages []db.SMSMessage
+	res := instance.parent.PersistStore.Where("outgoing = ?", outbox).Order("timestamp desc").Find(&messages)
+	if res.Error != nil {
+		log.Println("⚠️ Failed to load messages:", res.Error)
+	}
+
+	if len(messages) == 0 {
+		instance.parent.RenderAlert("info", []string{"No", "messages"})
+		time.Sleep(2 * time.Second)
+		return MessagesActionShowSelector
+	}
+
+	instance.outbox = outbox
+	instance.msg_cache = make(map[string]uint)
+
+	var options [][]string
+	for _, msg := range messages {
+		key := msg.Number
+		if !msg.Read {
+			key = "* " + key
+		}
+		if _, exists := instance.msg_cache[key]; exists {
+			key = fmt.Sprintf("%s (%s)", key, msg.Timestamp.Local().Format("01/02 15:04"))
+		}
+		instance.msg_cache[key] = msg.ID
+		options = append(options, []string{key})
+	}
+
+	title := "Inbox"
+	class := "messages.inbox"
+	if outbox {
+		title = "Outbox"
+		class = "messages.outbox"
+	}
+
+	go instance.parent.PushWithArgs("selector", &SelectorArgs{
+		SelectionClass:             class,
+		Title:                      title,
+		Options:                    options,
+		ButtonLabel:                "Read",
+		VisibleRows:                3,
+		ShowElemNumberInTitle:      true,
+		ShowElemNumbersInSelection: true,
+	})
+	return MessagesActionSubmenuPushed
+}
+
+func (instance *MessagesMenu) renderMessage(msg *db.SMSMessage, lines []string, offset int) {
+	display := instance.parent.Display
+
+	display.Clear(sh1107.Black)
+
+	font := display.Use_Font8_Normal()
+	display.DrawTextAligned(0, 20, font, msg.Number, false, sh1107.AlignRight, sh1107.AlignNone)
+
+	display.SetColor(sh1107.White)
+	display.SetLineWidth(1)
+	display.DrawLine(0, 33, 127, 33)
+	display.Stroke()
+
+	// The timestamp scrolls with the text, as the first line
+	rows := append([]string{msg.Timestamp.Local().Format("01/02/06 15:04")}, lines...)
+	end := min(offset+messageVisibleLines, len(rows))
+	for i, line := range rows[offset:end] {
+		display.DrawText(0, 38+i*11, font, line, false)
+	}
+
+	font = display.Use_Font8_Bold()
+	display.DrawTextAligned(64, 105, font, "Options", false, sh1107.AlignCenter, sh1107.AlignNone)
+
+	display.Render()
+}
+
+// ReadMessage shows a message and marks it as read. It returns true if
+// Options was pressed, or false if the message was closed.
+func (instance *MessagesMenu) ReadMessage(id uint) bool {
+	var msg db.SMSMessage
+	if res := instance.parent.PersistStore.First(&msg, id); res.Error != nil {
+		log.Println("⚠️ Failed to load message:", res.Error)
+		instance.parent.RenderAlert("alert", []string{"Message", "not found"})
+		time.Sleep(2 * time.Second)
+		return false
+	}
+
+	if !msg.Read {
+		instance.parent.PersistStore.Model(&msg).Update("read", true)
+		instance.parent.UpdateUnreadMessages()
+	}
+
+	display := instance.parent.Display
+	lines := wrapText(display, display.Use_Font8_Normal(), msg.Body, 127)
+	offset := 0
+	max_offset := max(len(lines)+1-messageVisibleLines, 0)
+
+	instance.renderMessage(&msg, lines, offset)
+	for {
+		select {
+		case <-instance.ctx.Done():
+			return false
+		case evt := <-instance.parent.KeypadEvents:
+			if !evt.State {
+				continue
+			}
+
+			instance.parent.Timers["keypad"].Reset()
+			instance.parent.Timers["oled"].Reset()
+			instance.parent.Display.On()
+			instance.parent.Backlight.On()
+			go instance.parent.PlayKey()
+
+			switch evt.Key {
+			case 'P':
+				go instance.parent.Push("power")
+				return false
+			case 'S':
+				return true
+			case 'C':
+				return false
+			case 'U':
+				if offset > 0 {
+					offset--
+					instance.renderMessage(&msg, lines, offset)
+				}
+			case 'D':
+				if offset < max_offset {
+					offset++
+					instance.renderMessage(&msg, lines, offset)
+				}
+			}
+		}
+	}
+}
+
+// WriteMessage asks for the text of a message and, unless number is given,
+// who to send it to, then sends it.
+func (instance *MessagesMenu) WriteMessage(number string) {
+	body := instance.parent.EnterText("Message", instance.ctx)
+	if body == "" {
+		return
+	}
+
+	if number == "" {
+		number = instance.parent.EnterPhoneNumber("Number", "", instance.ctx)
+		if number == "" {
+			return
+		}
+	}
+
+	instance.parent.SendMessage(number, body)
+}
+
+func (instance *MessagesMenu) Run() {
+	if !instance.configured {
+		panic("Attempted to call (*MessagesMenu).Run() before (*MessagesMenu).Configure()!")
+	}
+
+	log.Println("📩 Messages started")
+
+	if !instance.process_selection {
+		// Start the selector with the base messages menu
+		log.Println("📩 Messages switching to selector")
+		go instance.parent.PushWithArgs("selector", &SelectorArgs{
+			Title:                      "Messages",
+			SelectionClass:             "messages.main",
+			Options:                    instance.options,
+			ButtonLabel:                "Select",
+			VisibleRows:                3,
+			ShowElemNumberInTitle:      true,
+			ShowElemNumbersInSelection: true,
+			AllowNumberKeyShortcut:     true,
+			PersistLastState:           true,
+		})
+		return
+	}
+
+	log.Printf("📩 Messages %s: %s", instance.selection_class, instance.selection_path)
+
+	switch instance.selection_class {
+	case "messages.main":
+
+		// Exit to main menu
+		if len(instance.selection_path) == 0 {
+			log.Println("📩 Messages path selected is empty, exiting...")
+			go instance.parent.Pop()
+			return
+		}
+
+		action := instance.MessagesMain(instance.selection_path)
+
+		switch action {
+		case MessagesActionExit:
+			log.Println("📩 Messages exiting")
+			go instance.parent.Pop()
+			return
+		case MessagesActionSubmenuPushed:
+			// Do nothing, wait for submenu to return
+			return
+		}
+
+	case "messages.inbox", "messages.outbox":
+		if len(instance.selection_path) > 0 {
+			id, ok := instance.msg_cache[instance.selection_path[0]]
+			if !ok {
+				break
+			}
+			instance.current_target = id
+
+			if !instance.ReadMessage(id) {
+				if instance.ctx.Err() != nil {
+					return
+				}
+				instance.ShowFolder(instance.outbox)
+				return
+			}
+
+			options := [][]string{{"Reply"}, {"Delete"}}
+			if instance.outbox {
+				options = [][]string{{"Send again"}, {"Delete"}}
+			}
+
+			go instance.parent.PushWithArgs("selector", &SelectorArgs{
+				SelectionClass: "messages.message_action",
+				Title:          "Options",
+				Options:        options,
+				ButtonLabel:    "Select",
+				VisibleRows:    2,
+			})
+			return
+		}
+
+	case "messages.message_action":
+		if len(instance.selection_path) > 0 && instance.current_target != 0 {
+			var msg db.SMSMessage
+			if res := instance.parent.PersistStore.First(&msg, instance.current_target); res.Error == nil {
+				switch instance.selection_path[0] {
+				case "Reply":
+					instance.WriteMessage(msg.Number)
+				case "Send again":
+					instance.parent.SendMessage(msg.Number, msg.Body)
+				case "Delete":
+					if res := instance.parent.PersistStore.Delete(&msg); res.Error != nil {
+						log.Println("⚠️ Failed to delete message:", res.Error)
+						instance.parent.RenderAlert("alert", []string{"Error", "deleting"})
+					} else {
+						instance.parent.UpdateUnreadMessages()
+						instance.parent.RenderAlert("ok", []string{"Message", "deleted"})
+					}
+					time.Sleep(2 * time.Second)
+				}
+			}
+			instance.current_target = 0
+			if instance.ctx.Err() != nil {
+				return
+			}
+		}
+
+		// Go back to the folder the message was in
+		if instance.ShowFolder(instance.outbox) == MessagesActionSubmenuPushed {
+			return
+		}
+	}
+
+	instance.process_selection = false
+	log.Println("📩 Messages switching back to selector")
+	go instance.parent.PushWithArgs("selector", &SelectorArgs{
+		Title:                      "Messages",
+		SelectionClass:             "messages.main",
+		Options:                    instance.options,
+		ButtonLabel:                "Select",
+		VisibleRows:                3,
+		ShowElemNumberInTitle:      true,
+		ShowElemNumbersInSelection: true,
+		AllowNumberKeyShortcut:     true,
+		PersistLastState:           true,
+	})
+}
+
+func (instance *MessagesMenu) Pause() {
+	instance.process_selection = true
+	instance.cancelFn()
+	if ok := waitWithTimeout(&instance.wg, 1*time.Second); !ok {
+		log.Println("⚠️ Messages handler pause timed out — goroutines may be stuck")
+		// Optional: escalate here
+	}
+}
+
+func (instance *MessagesMenu) Stop() {
+	instance.process_selection = false
+	instance.cancelFn()
+	if ok := waitWithTimeout(&instance.wg, 1*time.Second); !ok {
+		log.Println("⚠️ Messages handler stop timed out — goroutines may be stuck")
+		// Optional: escalate here
+	} else {
+		go instance.cleanup()
+	}
+}
+
+func (instance *MessagesMenu) cleanup() {
+	instance.process_selection = false
+	instance.selection_path = []string{}
+	instance.current_target = 0
+}
@@ -0,0 +1,192 @@
+package menu
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	"phone"
+)
+
+// Preferred radio technology by menu label
+var networkModes = map[string]int{
+	"Automatic": phone.NetworkModeAuto,
+	"LTE only":  phone.NetworkModeLTE,
+	"3G only":   phone.NetworkModeUMTS,
+	"2G only":   phone.NetworkModeGSM,
+}
+
+// ApplyNetworkMode gives the saved preferred radio technology to the modem.
+func (m *Menu) ApplyNetworkMode() {
+	if m.Modem == nil {
+		return
+	}
+	mode, ok := networkModes[m.Get("NetworkMode").(string)]
+	if !ok {
+		mode = phone.NetworkModeAuto
+	}
+	if err := m.Modem.SetNetworkMode(m.GlobalContext, mode); err != nil {
+		log.Println("⚠️ Failed to set network mode:", err)
+	}
+}
+
+// Selector label for a network found in a scan, with its flags
+func operatorLabel(op phone.Operator) string {
+	name := op.ShortName
+	if name == "" {
+		name = op.Name
+	}
+	label := strings.TrimSpace(name + " " + op.Generation())
+
+	switch op.Status {
+	case phone.OperatorCurrent:
+		label += " (current)"
+	case phone.OperatorForbidden:
+		label += " (forbidden)"
+	}
+	return label
+}
+
+// Tells the user the network wouldn't take the SIM
+func (instance *SettingsMenu) registrationError(err error) {
+	log.Println("📡 Network selection failed:", err)
+	instance.parent.RenderAlert("alert", []string{"No access", "to network"})
+	go instance.parent.PlayAlert()
+	time.Sleep(2 * time.Second)
+}
+
+// ShowNetworkSelection offers automatic or manual network selection.
+func (instance *SettingsMenu) ShowNetworkSelection() int {
+	if !instance.parent.SIMAvailable() {
+		return SettingsActionShowSelector
+	}
+
+	go instance.parent.PushWithArgs("selector", &SelectorArgs{
+		SelectionClass: "settings.network_selection",
+		Title:          "Network selection",
+		Options:        [][]string{{"Automatic"}, {"Manual"}},
+		ButtonLabel:    "Select",
+		VisibleRows:    3,
+	})
+	return SettingsActionSubmenuPushed
+}
+
+// SelectNetwork lets the modem pick the network, or scans for networks to
+// pick one from.
+func (instance *SettingsMenu) SelectNetwork(choice string) int {
+	modem := instance.parent.Modem
+
+	if choice == "Automatic" {
+		instance.parent.RenderAlert("loading", []string{"Searching..."})
+		if err := modem.AutomaticOperator(instance.ctx); err != nil {
+			instance.registrationError(err)
+			return SettingsActionShowSelector
+		}
+		instance.parent.RenderAlert("ok", []string{"Automatic", "selection"})
+		time.Sleep(2 * time.Second)
+		return SettingsActionShowSelector
+	}
+
+	instance.parent.RenderAlert("loading", []string{"Searching for", "networks..."})
+	operators, err := modem.ScanOperators(instance.ctx)
+	if err != nil {
+		log.Println("⚠️ Network scan failed:", err)
+		instance.parent.RenderAlert("alert", []string{"Search", "failed"})
+		go instance.parent.PlayAlert()
+		time.Sleep(2 * time.Second)
+		return SettingsActionShowSelector
+	}
+	if instance.ctx.Err() != nil {
+		return SettingsActionSubmenuPushed
+	}
+	if len(operators) == 0 {
+		instance.parent.RenderAlert("info", []string{"No", "networks", "found"})
+		time.Sleep(2 * time.Second)
+		return SettingsActionShowSelector
+	}
+
+	instance.operator_cache = make(map[string]phone.Operator)
+	var options [][]string
+	for _, op := range operators {
+		label := operatorLabel(op)
+		if _, dup := instance.operator_cache[label]; dup {
+			continue
+		}
+		instance.operator_cache[label] = op
+		options = append(options, []string{label})
+	}
+
+	go instance.parent.PushWithArgs("selector", &SelectorArgs{
+		SelectionClass: "settings.operators",
+		Title:          "Manual",
+		Options:        options,
+		ButtonLabel:    "Select",
+		VisibleRows:    3,
+	})
+	return SettingsActionSubmenuPushed
+}
+
+// RegisterOperator registers on a network picked from the scan.
+func (instance *SettingsMenu) RegisterOperator(label string) int {
+	op, ok := instance.operator_cache[label]
+	if !ok {
+		return SettingsActionShowSelector
+	}
+
+	instance.parent.RenderAlert("loading", []string{"Registering..."})
+	if err := instance.parent.Modem.SelectOperator(instance.ctx, op); err != nil {
+		instance.registrationError(err)
+		return SettingsActionShowSelector
+	}
+
+	instance.parent.RenderAlert("ok", []string{op.Name, "selected"})
+	time.Sleep(2 * time.Second)
+	return SettingsActionShowSelector
+}
+
+// ShowNetworkMode lets the preferred radio technology be picked, marking the
+// current one.
+func (instance *SettingsMenu) ShowNetworkMode() int {
+	current := instance.parent.Get("NetworkMode").(string)
+	var options [][]string
+	for _, mode := range []string{"Automatic", "LTE only", "3G only", "2G only"} {
+		if mode == current {
+			mode += " (current)"
+		}
+		options = append(options, []string{mode})
+	}
+
+	go instance.parent.PushWithArgs("selector", &SelectorArgs{
+		SelectionClass: "settings.network_mode",
+		Title:          "Network mode",
+		Options:        options,
+		ButtonLabel:    "Select",
+		VisibleRows:    3,
+	})
+	return SettingsActionSubmenuPushed
+}
+
+// SetNetworkMode saves and applies the preferred radio technology.
+func (instance *SettingsMenu) SetNetworkMode(label string) int {
+	mode := strings.TrimSuffix(label, " (current)")
+	if _, ok := networkModes[mode]; !ok {
+		return SettingsActionShowSelector
+	}
+
+	instance.parent.Set("NetworkMode", mode)
+	go instance.parent.SyncPersistent()
+
+	if instance.parent.Modem != nil {
+		if err := instance.parent.Modem.SetNetworkMode(instance.ctx, networkModes[mode]); err != nil {
+			log.Println("⚠️ Failed to set network mode:", err)
+			instance.parent.RenderAlert("alert", []string{"Network mode", "not set"})
+			go instance.parent.PlayAlert()
+			time.Sleep(2 * time.Second)
+			return SettingsActionShowSelector
+		}
+	}
+
+	instance.parent.RenderAlert("ok", []string{mode, "selected"})
+	time.Sleep(2 * time.Second)
+	return SettingsActionShowSelector
+}
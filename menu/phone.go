@@ -2,12 +2,11 @@ package menu
 
 import (
 	"context"
-	"fmt"
 	"log"
 	"sync"
 	"time"
 
-	"misc"
+	"phone"
 	"sh1107"
 	"timers"
 )
@@ -21,30 +20,169 @@ type PhoneMenu struct {
 	batt_flash  bool
 	data_flash  bool
 	render_loop *timers.ResettableTimer
+	caller      callerID
+	names       map[string]string // Number -> contact name, for the call list
+	action      string            // Picked from the Options selector, run on resume
+	volume      int               // Call volume, from 0 to phone.MaxCallVolume
+	volume_set  time.Time         // When U or D last changed the volume, which shows it for a while
 }
 
+// How long the volume bar stays up after U or D
+const volumeBarTimeout = 2 * time.Second
+
 func (m *Menu) NewPhoneMenu() *PhoneMenu {
 	return &PhoneMenu{
 		parent: m,
 	}
 }
 
+// Looks up the contact name for a number, remembering it for the next redraw
+func (instance *PhoneMenu) name(number string) string {
+	name, ok := instance.names[number]
+	if !ok {
+		name = instance.parent.ContactName(number)
+		instance.names[number] = name
+	}
+	return name
+}
+
+// Lists what can be done with the calls that are up, then with the call's
+// audio
+func callOptions(calls []phone.CallState, state phone.State) [][]string {
+	var waiting, active, held, conference bool
+	for _, call := range calls {
+		switch call.Status {
+		case "waiting":
+			waiting = true
+		case "active":
+			active = true
+			conference = conference || call.IsConferenceCall
+		case "held":
+			held = true
+		}
+	}
+
+	var options [][]string
+	switch {
+	case waiting:
+		options = [][]string{{"Answer"}, {"Replace"}, {"Reject"}}
+	case active && held:
+		options = [][]string{{"Swap"}, {"Conference"}, {"End active call"}, {"End held call"}, {"End all calls"}}
+	case held:
+		options = [][]string{{"Retrieve"}, {"End call"}, {"New call"}}
+	case conference:
+		options = [][]string{{"End all calls"}, {"Hold"}}
+	case active:
+		options = [][]string{{"End call"}, {"Hold"}, {"New call"}}
+	default:
+		options = [][]string{{"End call"}}
+	}
+
+	if state.MicMuted {
+		options = append(options, []string{"Unmute"})
+	} else {
+		options = append(options, []string{"Mute"})
+	}
+	if active {
+		options = append(options, []string{"Send DTMF"})
+	}
+	if state.Loudspeaker {
+		options = append(options, []string{"Earpiece"})
+	} else {
+		options = append(options, []string{"Loudspeaker"})
+	}
+	return options
+}
+
+// Turns the call volume up or down a step and shows the volume bar
+func (instance *PhoneMenu) changeVolume(up bool) {
+	level := instance.volume - 1
+	if up {
+		level = instance.volume + 1
+	}
+	level = min(max(level, 0), phone.MaxCallVolume)
+
+	if err := instance.parent.Modem.SetCallVolume(instance.ctx, level); err != nil {
+		log.Println("⚠️ Failed to set call volume:", err)
+	} else {
+		instance.volume = level
+	}
+	instance.volume_set = time.Now()
+	instance.render()
+}
+
+// Shows the call volume in place of the call
+func (instance *PhoneMenu) renderVolume() {
+	display := instance.parent.Display
+
+	font := display.Use_Font8_Normal()
+	display.DrawTextAligned(64, 50, font, "Volume", false, sh1107.AlignCenter, sh1107.AlignNone)
+	display.DrawProgressBar(14, 62, 100, 12, float64(instance.volume)/phone.MaxCallVolume)
+}
+
+// Shows every call that's up, one per row, when there's more than one
+func (instance *PhoneMenu) renderCalls(calls []phone.CallState) {
+	display := instance.parent.Display
+
+	for i, call := range calls[:min(len(calls), 3)] {
+		y := 37 + i*22
+
+		font := display.Use_Font8_Bold()
+		display.DrawText(0, y, font, instance.name(call.PhoneNumber), false)
+
+		status := call.Status
+		if call.IsConferenceCall {
+			status = "conference"
+		}
+		if call.Status == "active" || call.Status == "held" {
+			status += " " + formatDuration(time.Since(call.StartTime))
+		}
+		font = display.Use_Font8_Normal()
+		display.DrawTextAligned(127, y+11, font, status, false, sh1107.AlignLeft, sh1107.AlignNone)
+	}
+}
+
 func (instance *PhoneMenu) render() {
 	display := instance.parent.Display
 	display.Clear(sh1107.Black)
 	instance.parent.RenderStatusBar(&instance.batt_flash, &instance.data_flash)
 
+	// Tones dialed after a wait go once the user says so
+	if tones := instance.parent.Modem.State().PostDialWait; tones != "" {
+		font := display.Use_Font8_Normal()
+		display.DrawText(0, 38, font, "Send tones?", false)
+		display.DrawText(0, 49, font, tones, false)
+		font = display.Use_Font8_Bold()
+		display.DrawTextAligned(64, 105, font, "Send", false, sh1107.AlignCenter, sh1107.AlignNone)
+		display.Render()
+		return
+	}
+
+	calls := instance.parent.Modem.Calls()
 	font := display.Use_Font8_Bold()
-	display.DrawTextAligned(64, 105, font, "End", false, sh1107.AlignCenter, sh1107.AlignNone)
-	display.DrawTextAligned(0, 65, font, instance.parent.Modem.CallState.Status, false, sh1107.AlignRight, sh1107.AlignNone)
+	display.DrawTextAligned(64, 105, font, "Options", false, sh1107.AlignCenter, sh1107.AlignNone)
+
+	if time.Since(instance.volume_set) < volumeBarTimeout {
+		instance.renderVolume()
+		display.Render()
+		return
+	}
 
-	font = display.Use_Font16()
-	display.DrawText(0, 45, font, instance.parent.Modem.CallState.PhoneNumber, false)
+	if len(calls) > 1 {
+		instance.renderCalls(calls)
+		display.Render()
+		return
+	}
 
-	if !instance.parent.Modem.CallState.StartTime.IsZero() {
-		d := time.Since(instance.parent.Modem.CallState.StartTime)
+	call := instance.parent.Modem.State().Call
+	display.DrawTextAligned(0, 65, font, call.Status, false, sh1107.AlignRight, sh1107.AlignNone)
+
+	instance.parent.renderCaller(&instance.caller, call.PhoneNumber)
+
+	if !call.StartTime.IsZero() {
+		d := time.Since(call.StartTime)
 		font = display.Use_Font_Time()
-		display.DrawTextAligned(0, 80, font, fmt.Sprintf("%02d:%02d:%02d", int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60), false, sh1107.AlignRight, sh1107.AlignNone)
+		display.DrawTextAligned(0, 80, font, formatDuration(d), false, sh1107.AlignRight, sh1107.AlignNone)
 	}
 
 	display.Render()
@@ -57,15 +195,81 @@ func (instance *PhoneMenu) Configure() {
 }
 
 func (instance *PhoneMenu) ConfigureWithArgs(args ...any) {
-	// Unused
+
+	// Coming back from the Options selector
+	if len(args) > 0 {
+		selection, ok := args[0].(*SelectorReturn)
+		if !ok {
+			panic("(*PhoneMenu).ConfigureWithArgs() Type error: argument must be a *SelectorReturn type")
+		}
+		if len(selection.SelectionPath) > 0 {
+			instance.action = selection.SelectionPath[0]
+		}
+	}
+
 	instance.Configure()
 }
 
+// Runs an entry picked from the Options selector, returning true if it
+// opened another screen
+func (instance *PhoneMenu) runAction(action string) bool {
+	modem := instance.parent.Modem
+
+	var err error
+	switch action {
+	case "Answer", "Swap", "Retrieve", "Hold":
+		err = modem.HoldAndAccept(instance.ctx)
+	case "Replace", "End active call":
+		err = modem.ReleaseAndAccept(instance.ctx)
+	case "Reject", "End held call":
+		err = modem.ReleaseHeld(instance.ctx)
+	case "Conference":
+		err = modem.JoinConference(instance.ctx)
+	case "End call", "End all calls":
+		err = modem.Hangup()
+	case "New call":
+		go instance.parent.Push("dialer")
+		return true
+	case "Send DTMF":
+		if tones := instance.parent.EnterTextInMode("Tones", "", T9Numbers, instance.ctx); tones != "" {
+			modem.SendDTMF(tones)
+		}
+	case "Mute", "Unmute":
+		err = modem.MuteMic(action == "Mute")
+	case "Loudspeaker", "Earpiece":
+		err = modem.SetLoudspeaker(instance.ctx, action == "Loudspeaker")
+	}
+
+	if err != nil {
+		log.Println("⚠️ Call action failed:", err)
+		instance.parent.RenderAlert("alert", []string{"Not", "possible"})
+		go instance.parent.PlayAlert()
+		time.Sleep(2 * time.Second)
+	}
+	return false
+}
+
 func (instance *PhoneMenu) Run() {
 	if !instance.configured {
 		panic("Attempted to call (*PhoneMenu).Run() before (*PhoneMenu).Configure()!")
 	}
 
+	if instance.action != "" {
+		action := instance.action
+		instance.action = ""
+		if instance.runAction(action) {
+			return
+		}
+	}
+
+	if volume, err := instance.parent.Modem.CallVolume(instance.ctx); err == nil {
+		instance.volume = volume
+	}
+
+	// Look the caller up again, the phonebook may have changed since
+	instance.caller = callerID{}
+	instance.names = make(map[string]string)
+
 	// Battery icon blinker
 	instance.wg.Go(func() {
 		for {
@@ -125,7 +329,7 @@ func (instance *PhoneMenu) Run() {
 					instance.parent.Timers["keypad"].Reset()
 					instance.parent.Timers["oled"].Reset()
 					instance.parent.Display.On()
-					misc.KeyLightsOn()
+					instance.parent.Backlight.On()
 					go instance.parent.PlayKey()
 
 					switch evt.Key {
@@ -135,12 +339,24 @@ func (instance *PhoneMenu) Run() {
 						return
 
 					case 'S':
-						instance.parent.Modem.Hangup()
+						if instance.parent.Modem.State().PostDialWait != "" {
+							instance.parent.Modem.SendPostDial()
+							continue
+						}
+
+						go instance.parent.PushWithArgs("selector", &SelectorArgs{
+							SelectionClass: "phone.options",
+							Title:          "Options",
+							Options:        callOptions(instance.parent.Modem.Calls(), instance.parent.Modem.State()),
+							ButtonLabel:    "Select",
+							VisibleRows:    3,
+						})
 						return
 
-					case 'U':
-					case 'D':
+					case 'U', 'D':
+						instance.changeVolume(evt.Key == 'U')
 					case 'C':
+						instance.parent.Modem.CancelPostDial()
 					default:
 						instance.parent.Modem.EnterNumber(evt.Key)
 					}
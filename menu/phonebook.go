@@ -2,9 +2,18 @@ package menu
 
 import (
 	"context"
+	"fmt"
 	"log"
+	"sort"
+	"strings"
 	"sync"
 	"time"
+
+	"db"
+	"misc"
+	"sh1107"
+
+	"gorm.io/gorm"
 )
 
 const (
@@ -13,6 +22,9 @@ const (
 	PhonebookActionSubmenuPushed
 )
 
+// How many names the search list shows at once
+const phonebookVisibleRows = 3
+
 type PhonebookMenu struct {
 	ctx               context.Context
 	configured        bool
@@ -23,6 +35,10 @@ type PhonebookMenu struct {
 	selection_class   string
 	selection_path    []string
 	options           [][]string
+	number_cache      map[string]uint   // Selector label -> contact number ID
+	service_cache     map[string]string // Selector label -> service number
+	current_contact   uint
+	current_number    uint
 }
 
 func (*PhonebookMenu) Label() string {
@@ -34,12 +50,16 @@ func (m *Menu) NewPhonebookMenu() *PhonebookMenu {
 		parent:            m,
 		process_selection: false,
 		selection_path:    []string{},
+		number_cache:      make(map[string]uint),
+		service_cache:     make(map[string]string),
 		options: [][]string{
 			{"Search"},
+			{"Add entry"},
 			{"Service Numbers"},
-			{"Erase"},
+			{"Erase", "One by one", "Erase all"},
 			{"Edit"},
 			{"Assign Tone"},
+			{"Copy", "SIM to phone", "Phone to SIM"},
 		},
 	}
 }
@@ -72,20 +92,516 @@ func (instance *PhonebookMenu) ConfigureWithArgs(args ...any) {
 func (instance *PhonebookMenu) PhonebookMain(selection_path []string) int {
 	switch selection_path[len(selection_path)-1] {
 	case "Search":
-		// TODO
+		if contact := instance.PickContact("Search", "Details"); contact != nil {
+			return instance.ShowContact(contact.ID)
+		}
+	case "Add entry":
+		instance.AddEntry()
 	case "Service Numbers":
-		// TODO
-	case "Erase":
-		// TODO
+		return instance.ShowServiceNumbers()
+	case "One by one":
+		if contact := instance.PickContact("Erase", "Erase"); contact != nil {
+			instance.EraseContact(contact)
+		}
+	case "Erase all":
+		instance.EraseAll()
 	case "Edit":
-		// TODO
+		if contact := instance.PickContact("Edit", "Edit"); contact != nil {
+			instance.EditName(contact)
+			if instance.ctx.Err() == nil {
+				return instance.ShowContact(contact.ID)
+			}
+		}
 	case "Assign Tone":
-		// TODO
+		if contact := instance.PickContact("Assign Tone", "Select"); contact != nil {
+			return instance.ShowTones(contact)
+		}
+	case "SIM to phone":
+		instance.CopyFromSIM()
+	case "Phone to SIM":
+		instance.CopyToSIM()
 	}
 
+	if instance.ctx.Err() != nil {
+		return PhonebookActionSubmenuPushed
+	}
 	return PhonebookActionShowSelector
 }
 
+// Loads every contact with its numbers, sorted by name
+func (instance *PhonebookMenu) loadContacts() []db.Contact {
+	var contacts []db.Contact
+	res := instance.parent.PersistStore.Preload("Numbers").Find(&contacts)
+	if res.Error != nil {
+		log.Println("⚠️ Failed to load contacts:", res.Error)
+	}
+
+	// Sorted here rather than in SQL so the order matches the search in
+	// PickContact
+	sort.SliceStable(contacts, func(i, j int) bool {
+		return strings.ToLower(contacts[i].Name) < strings.ToLower(contacts[j].Name)
+	})
+	return contacts
+}
+
+func (instance *PhonebookMenu) renderSearch(title string, button string, prefix string, contacts []db.Contact, selection int, offset int) {
+	display := instance.parent.Display
+
+	display.Clear(sh1107.Black)
+
+	// The name typed so far replaces the title
+	font := display.Use_Font8_Normal()
+	if prefix != "" {
+		display.DrawText(0, 20, font, prefix, false)
+	} else {
+		display.DrawText(0, 20, font, title, false)
+	}
+
+	display.SetColor(sh1107.White)
+	display.SetLineWidth(1)
+	display.DrawLine(0, 33, 127, 33)
+	display.Stroke()
+
+	font = display.Use_Font8_Bold()
+	end := min(offset+phonebookVisibleRows, len(contacts))
+	for i, contact := range contacts[offset:end] {
+		y := 40 + i*20
+		if offset+i == selection {
+			display.SetColor(sh1107.White)
+			display.DrawRectangle(0, float64(y-1), 127, 16)
+			display.Fill()
+			display.DrawText(2, y+4, font, contact.Name, true)
+		} else {
+			display.DrawText(2, y+4, font, contact.Name, false)
+		}
+	}
+
+	display.DrawTextAligned(64, 105, font, button, false, sh1107.AlignCenter, sh1107.AlignNone)
+	display.Render()
+}
+
+// PickContact shows the contacts in alphabetical order and returns the one
+// picked, or nil if it was cancelled. Typing letters with the keypad jumps to
+// the first name that matches what's been typed so far, like the Nokia 5110.
+func (instance *PhonebookMenu) PickContact(title string, button string) *db.Contact {
+	contacts := instance.loadContacts()
+	if len(contacts) == 0 {
+		instance.parent.RenderAlert("info", []string{"Phonebook", "empty"})
+		time.Sleep(2 * time.Second)
+		return nil
+	}
+
+	var prefix []rune
+	var lastKey rune
+	var lastPressTime time.Time
+	var cycleIndex int
+	selection := 0
+	offset := 0
+
+	// Moves the selection to the first name at or after the typed prefix
+	jump := func() {
+		want := strings.ToLower(string(prefix))
+		selection = sort.Search(len(contacts), func(i int) bool {
+			return strings.ToLower(contacts[i].Name) >= want
+		})
+		selection = min(selection, len(contacts)-1)
+	}
+
+	render := func() {
+		if selection < offset {
+			offset = selection
+		} else if selection >= offset+phonebookVisibleRows {
+			offset = selection - phonebookVisibleRows + 1
+		}
+		instance.renderSearch(title, button, string(prefix), contacts, selection, offset)
+	}
+
+	render()
+	for {
+		select {
+		case <-instance.ctx.Done():
+			return nil
+		case evt := <-instance.parent.KeypadEvents:
+			if !evt.State {
+				continue
+			}
+
+			instance.parent.Timers["keypad"].Reset()
+			instance.parent.Timers["oled"].Reset()
+			instance.parent.Display.On()
+			instance.parent.Backlight.On()
+			go instance.parent.PlayKey()
+
+			now := time.Now()
+
+			switch evt.Key {
+			case 'P':
+				go instance.parent.Push("power")
+				return nil
+			case 'S':
+				return &contacts[selection]
+			case 'C':
+				lastKey = 0
+				if len(prefix) == 0 {
+					return nil
+				}
+				prefix = prefix[:len(prefix)-1]
+				jump()
+			case 'U':
+				lastKey = 0
+				if selection > 0 {
+					selection--
+				} else {
+					selection = len(contacts) - 1
+				}
+			case 'D':
+				lastKey = 0
+				if selection < len(contacts)-1 {
+					selection++
+				} else {
+					selection = 0
+				}
+			default:
+				chars, ok := t9KeyMap[evt.Key]
+				if !ok {
+					continue
+				}
+
+				if evt.Key == lastKey && now.Sub(lastPressTime) < 1*time.Second && len(prefix) > 0 {
+					// Cycle the last letter
+					cycleIndex = (cycleIndex + 1) % len(chars)
+					prefix[len(prefix)-1] = rune(chars[cycleIndex])
+				} else {
+					cycleIndex = 0
+					prefix = append(prefix, rune(chars[0]))
+				}
+				lastKey = evt.Key
+				lastPressTime = now
+				jump()
+			}
+			render()
+		}
+	}
+}
+
+// ShowContact lists a contact's numbers, so one can be called or changed.
+func (instance *PhonebookMenu) ShowContact(id uint) int {
+	var contact db.Contact
+	if res := instance.parent.PersistStore.Preload("Numbers").First(&contact, id); res.Error != nil {
+		log.Println("⚠️ Failed to load contact:", res.Error)
+		instance.parent.RenderAlert("alert", []string{"Contact", "not found"})
+		time.Sleep(2 * time.Second)
+		return PhonebookActionShowSelector
+	}
+
+	instance.current_contact = contact.ID
+	instance.number_cache = make(map[string]uint)
+
+	var options [][]string
+	for _, number := range contact.Numbers {
+		instance.number_cache[number.Number] = number.ID
+		options = append(options, []string{number.Number})
+	}
+	options = append(options, []string{"Add number"})
+
+	go instance.parent.PushWithArgs("selector", &SelectorArgs{
+		SelectionClass: "phonebook.contact",
+		Title:          contact.Name,
+		Options:        options,
+		ButtonLabel:    "Select",
+		VisibleRows:    3,
+	})
+	return PhonebookActionSubmenuPushed
+}
+
+// AddEntry asks for a name and a number and saves them as a new contact.
+func (instance *PhonebookMenu) AddEntry() {
+	name := strings.TrimSpace(instance.parent.EnterText("Name", instance.ctx))
+	if name == "" {
+		return
+	}
+
+	number := instance.parent.EnterPhoneNumber("Number", "", instance.ctx)
+	if number == "" {
+		return
+	}
+
+	contact := &db.Contact{
+		Name:    name,
+		Numbers: []db.ContactNumber{{Number: number}},
+	}
+	if res := instance.parent.PersistStore.Create(contact); res.Error != nil {
+		log.Println("⚠️ Failed to save contact:", res.Error)
+		instance.parent.RenderAlert("alert", []string{"Error", "saving"})
+	} else {
+		instance.parent.RenderAlert("ok", []string{"Saved"})
+	}
+	time.Sleep(2 * time.Second)
+}
+
+// EditName lets the contact's name be changed.
+func (instance *PhonebookMenu) EditName(contact *db.Contact) {
+	name := strings.TrimSpace(instance.parent.EnterTextWithDefault("Name", contact.Name, instance.ctx))
+	if name == "" || name == contact.Name {
+		return
+	}
+
+	if res := instance.parent.PersistStore.Model(contact).Update("name", name); res.Error != nil {
+		log.Println("⚠️ Failed to rename contact:", res.Error)
+		instance.parent.RenderAlert("alert", []string{"Error", "saving"})
+		time.Sleep(2 * time.Second)
+	}
+}
+
+// EraseContact deletes a contact and its numbers once it's been confirmed.
+func (instance *PhonebookMenu) EraseContact(contact *db.Contact) {
+	if !instance.parent.Confirm([]string{"Erase", contact.Name + "?"}, instance.ctx) {
+		return
+	}
+
+	if res := instance.parent.PersistStore.Select("Numbers").Delete(contact); res.Error != nil {
+		log.Println("⚠️ Failed to delete contact:", res.Error)
+		instance.parent.RenderAlert("alert", []string{"Error", "deleting"})
+	} else {
+		instance.parent.RenderAlert("ok", []string{"Erased"})
+	}
+	time.Sleep(2 * time.Second)
+}
+
+// EraseAll deletes every contact once it's been confirmed.
+func (instance *PhonebookMenu) EraseAll() {
+	if !instance.parent.Confirm([]string{"Erase all", "contacts?"}, instance.ctx) {
+		return
+	}
+
+	err := instance.parent.PersistStore.Transaction(func(tx *gorm.DB) error {
+		tx = tx.Session(&gorm.Session{AllowGlobalUpdate: true})
+		if err := tx.Delete(&db.ContactNumber{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&db.Contact{}).Error
+	})
+	if err != nil {
+		log.Println("⚠️ Failed to erase phonebook:", err)
+		instance.parent.RenderAlert("alert", []string{"Error", "deleting"})
+	} else {
+		instance.parent.RenderAlert("ok", []string{"Phonebook", "erased"})
+	}
+	time.Sleep(2 * time.Second)
+}
+
+// ShowTones lists the ringtones that can be assigned to a contact.
+func (instance *PhonebookMenu) ShowTones(contact *db.Contact) int {
+	instance.current_contact = contact.ID
+
+	options := [][]string{{"Default"}}
+	for _, name := range misc.RingtoneNames {
+		options = append(options, []string{name})
+	}
+
+	go instance.parent.PushWithArgs("selector", &SelectorArgs{
+		SelectionClass: "phonebook.tone",
+		Title:          contact.Name,
+		Options:        options,
+		ButtonLabel:    "Select",
+		VisibleRows:    3,
+	})
+	return PhonebookActionSubmenuPushed
+}
+
+// AssignTone stores the ringtone for the current contact and plays a bit of it.
+func (instance *PhonebookMenu) AssignTone(tone string) {
+	if tone == "Default" {
+		tone = ""
+	}
+
+	res := instance.parent.PersistStore.Model(&db.Contact{}).Where("id = ?", instance.current_contact).Update("ringtone", tone)
+	if res.Error != nil {
+		log.Println("⚠️ Failed to assign tone:", res.Error)
+		instance.parent.RenderAlert("alert", []string{"Error", "saving"})
+		time.Sleep(2 * time.Second)
+		return
+	}
+
+	instance.parent.RenderAlert("ok", []string{"Tone", "assigned"})
+	if instance.parent.Get("CanRing").(bool) && !instance.parent.Get("BeepOnly").(bool) {
+		ctx, cancel := context.WithTimeout(instance.ctx, 3*time.Second)
+		misc.PlayRingtoneNamed(instance.parent.Player, ctx, tone)
+		cancel()
+		instance.parent.Player.Stop()
+	} else {
+		time.Sleep(2 * time.Second)
+	}
+}
+
+// ShowServiceNumbers lists the operator's service numbers from the SIM.
+func (instance *PhonebookMenu) ShowServiceNumbers() int {
+	if !instance.parent.SIMAvailable() {
+		return PhonebookActionShowSelector
+	}
+
+	instance.parent.RenderAlert("loading", []string{"Reading", "SIM..."})
+	entries, err := instance.parent.Modem.ReadSIMPhonebook(instance.ctx, "SD")
+	if err != nil {
+		log.Println("⚠️ Failed to read service numbers:", err)
+	}
+	if len(entries) == 0 {
+		instance.parent.RenderAlert("info", []string{"No service", "numbers"})
+		time.Sleep(2 * time.Second)
+		return PhonebookActionShowSelector
+	}
+
+	instance.service_cache = make(map[string]string)
+	var options [][]string
+	for _, entry := range entries {
+		label := entry.Name
+		if label == "" {
+			label = entry.Number
+		}
+		instance.service_cache[label] = entry.Number
+		options = append(options, []string{label})
+	}
+
+	go instance.parent.PushWithArgs("selector", &SelectorArgs{
+		SelectionClass:             "phonebook.service",
+		Title:                      "Service Numbers",
+		Options:                    options,
+		ButtonLabel:                "Call",
+		VisibleRows:                3,
+		ShowElemNumberInTitle:      true,
+		ShowElemNumbersInSelection: true,
+	})
+	return PhonebookActionSubmenuPushed
+}
+
+// Dials a number, or shows why it can't be dialed and returns false
+func (instance *PhonebookMenu) call(number string) bool {
+	if reason := instance.parent.DialBlockedReason(number); reason != nil {
+		instance.parent.RenderAlert("prohibited", reason)
+		go instance.parent.PlayAlert()
+		time.Sleep(2 * time.Second)
+		return false
+	}
+	instance.parent.Dial(number)
+	return true
+}
+
+// CopyFromSIM adds the SIM's contacts to the phonebook, skipping any that are
+// already there.
+func (instance *PhonebookMenu) CopyFromSIM() {
+	if !instance.parent.SIMAvailable() {
+		return
+	}
+
+	instance.parent.RenderAlert("loading", []string{"Copying..."})
+	entries, err := instance.parent.Modem.ReadSIMPhonebook(instance.ctx, "SM")
+	if err != nil {
+		log.Println("⚠️ Failed to read SIM phonebook:", err)
+		instance.parent.RenderAlert("alert", []string{"Could not", "read SIM"})
+		time.Sleep(2 * time.Second)
+		return
+	}
+
+	copied := 0
+	for _, entry := range entries {
+		var count int64
+		instance.parent.PersistStore.Model(&db.ContactNumber{}).
+			Joins("JOIN contacts ON contacts.id = contact_numbers.contact_id").
+			Where("contacts.name = ? AND contact_numbers.number = ?", entry.Name, entry.Number).
+			Count(&count)
+		if count > 0 {
+			continue
+		}
+
+		contact := &db.Contact{
+			Name:    entry.Name,
+			Numbers: []db.ContactNumber{{Number: entry.Number}},
+		}
+		if res := instance.parent.PersistStore.Create(contact); res.Error != nil {
+			log.Println("⚠️ Failed to save contact:", res.Error)
+			continue
+		}
+		copied++
+	}
+
+	instance.parent.RenderAlert("ok", []string{fmt.Sprintf("%d copied", copied)})
+	time.Sleep(2 * time.Second)
+}
+
+// CopyToSIM writes each contact's first number to the SIM, skipping any that
+// are already there.
+func (instance *PhonebookMenu) CopyToSIM() {
+	if !instance.parent.SIMAvailable() {
+		return
+	}
+
+	instance.parent.RenderAlert("loading", []string{"Copying..."})
+	entries, err := instance.parent.Modem.ReadSIMPhonebook(instance.ctx, "SM")
+	if err != nil {
+		log.Println("⚠️ Failed to read SIM phonebook:", err)
+	}
+	on_sim := make(map[string]bool)
+	for _, entry := range entries {
+		on_sim[entry.Number] = true
+	}
+
+	copied := 0
+	for _, contact := range instance.loadContacts() {
+		if len(contact.Numbers) == 0 || on_sim[contact.Numbers[0].Number] {
+			continue
+		}
+		if err := instance.parent.Modem.WriteSIMContact(instance.ctx, contact.Numbers[0].Number, contact.Name); err != nil {
+			log.Println("⚠️ Failed to copy contact to SIM:", err)
+			break
+		}
+		copied++
+	}
+
+	instance.parent.RenderAlert("ok", []string{fmt.Sprintf("%d copied", copied)})
+	time.Sleep(2 * time.Second)
+}
+
+// NumberAction runs an option picked for one of the current contact's
+// numbers. It returns true if a call was placed.
+func (instance *PhonebookMenu) NumberAction(action string) bool {
+	var number db.ContactNumber
+	if res := instance.parent.PersistStore.First(&number, instance.current_number); res.Error != nil {
+		log.Println("⚠️ Failed to load number:", res.Error)
+		return false
+	}
+
+	switch action {
+	case "Call":
+		return instance.call(number.Number)
+
+	case "Send message":
+		body := instance.parent.EnterText("Message", instance.ctx)
+		if body != "" {
+			instance.parent.SendMessage(number.Number, body)
+		}
+
+	case "Edit number":
+		edited := instance.parent.EnterPhoneNumber("Number", number.Number, instance.ctx)
+		if edited != "" && edited != number.Number {
+			if res := instance.parent.PersistStore.Model(&number).Update("number", edited); res.Error != nil {
+				log.Println("⚠️ Failed to save number:", res.Error)
+				instance.parent.RenderAlert("alert", []string{"Error", "saving"})
+				time.Sleep(2 * time.Second)
+			}
+		}
+
+	case "Delete number":
+		if res := instance.parent.PersistStore.Delete(&number); res.Error != nil {
+			log.Println("⚠️ Failed to delete number:", res.Error)
+			instance.parent.RenderAlert("alert", []string{"Error", "deleting"})
+		} else {
+			instance.parent.RenderAlert("ok", []string{"Number", "deleted"})
+		}
+		time.Sleep(2 * time.Second)
+	}
+	return false
+}
+
 func (instance *PhonebookMenu) Run() {
 	if !instance.configured {
 		panic("Attempted to call (*PhonebookMenu).Run() before (*PhonebookMenu).Configure()!")
@@ -136,6 +652,66 @@ func (instance *PhonebookMenu) Run() {
 			// Do nothing, wait for submenu to return
 			return
 		}
+
+	case "phonebook.contact":
+		if len(instance.selection_path) > 0 {
+			if instance.selection_path[0] == "Add number" {
+				number := instance.parent.EnterPhoneNumber("Number", "", instance.ctx)
+				if instance.ctx.Err() != nil {
+					return
+				}
+				if number != "" {
+					entry := &db.ContactNumber{ContactID: instance.current_contact, Number: number}
+					if res := instance.parent.PersistStore.Create(entry); res.Error != nil {
+						log.Println("⚠️ Failed to save number:", res.Error)
+					}
+				}
+				instance.ShowContact(instance.current_contact)
+				return
+			}
+
+			if id, ok := instance.number_cache[instance.selection_path[0]]; ok {
+				instance.current_number = id
+				go instance.parent.PushWithArgs("selector", &SelectorArgs{
+					SelectionClass: "phonebook.number_action",
+					Title:          "Options",
+					Options:        [][]string{{"Call"}, {"Send message"}, {"Edit number"}, {"Delete number"}},
+					ButtonLabel:    "Select",
+					VisibleRows:    3,
+				})
+				return
+			}
+		}
+
+	case "phonebook.number_action":
+		if len(instance.selection_path) > 0 && instance.current_number != 0 {
+			called := instance.NumberAction(instance.selection_path[0])
+			instance.current_number = 0
+			if instance.ctx.Err() != nil || called {
+				return
+			}
+
+			// Go back to the contact the number belongs to
+			if instance.ShowContact(instance.current_contact) == PhonebookActionSubmenuPushed {
+				return
+			}
+		}
+
+	case "phonebook.service":
+		if len(instance.selection_path) > 0 {
+			if number, ok := instance.service_cache[instance.selection_path[0]]; ok && instance.call(number) {
+				return
+			}
+		}
+
+	case "phonebook.tone":
+		if len(instance.selection_path) > 0 && instance.current_contact != 0 {
+			instance.AssignTone(instance.selection_path[0])
+			instance.current_contact = 0
+			if instance.ctx.Err() != nil {
+				return
+			}
+		}
 	}
 
 	instance.process_selection = false
@@ -177,4 +753,6 @@ func (instance *PhonebookMenu) Stop() {
 func (instance *PhonebookMenu) cleanup() {
 	instance.process_selection = false
 	instance.selection_path = []string{}
+	instance.current_contact = 0
+	instance.current_number = 0
 }
@@ -7,7 +7,6 @@ import (
 	"sync"
 	"time"
 
-	"misc"
 	"sh1107"
 )
 
@@ -163,7 +162,7 @@ func (instance *PowerMenu) handle_selection() {
 
 		var msg = []string{"airplane", "mode"}
 
-		if instance.parent.Modem.FlightMode {
+		if instance.parent.Modem.State().FlightMode {
 			msg = append([]string{"Leaving"}, msg...)
 		} else {
 			msg = append([]string{"Entering"}, msg...)
@@ -173,8 +172,8 @@ func (instance *PowerMenu) handle_selection() {
 		go instance.parent.PlayAlert()
 
 		// Don't lockout ourselves if we're in debug mode
-		if !instance.parent.Get("DebugMode").(bool) {
-			if instance.parent.Modem.FlightMode {
+		if !instance.parent.Get("DebugMode").(bool) && instance.parent.NetworkManager != nil {
+			if instance.parent.Modem.State().FlightMode {
 				// Leaving airplane mode
 				go instance.parent.NetworkManager.SetPropertyWirelessEnabled(true)
 			} else {
@@ -223,7 +222,7 @@ func (instance *PowerMenu) Run() {
 					instance.parent.Timers["keypad"].Reset()
 					instance.parent.Timers["oled"].Reset()
 					instance.parent.Display.On()
-					misc.KeyLightsOn()
+					instance.parent.Backlight.On()
 
 					switch evt.Key {
 					case 'U':
@@ -121,6 +121,11 @@ func (instance *GenericAlert) Run() {
 
 	instance.parent.Timers["oled"].Restart()
 	instance.parent.Timers["keypad"].Restart()
+
+	// Go back to whatever was on screen, unless something else took over
+	if instance.ctx.Err() == nil {
+		go instance.parent.Pop()
+	}
 }
 
 func (instance *GenericAlert) Pause() {
@@ -0,0 +1,288 @@
+package menu
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"sh1107"
+)
+
+// How many times automatic redial tries again, by menu label
+var redialAttempts = map[string]int{
+	"Off":      0,
+	"3 times":  3,
+	"5 times":  5,
+	"10 times": 10,
+}
+
+var redialOptions = []string{"Off", "3 times", "5 times", "10 times"}
+
+// How long the countdown before each redial is
+const redialDelay = 5 * time.Second
+
+// Redial goes back to the home screen after a call that never got through,
+// then counts down to calling the last dialed number again if automatic
+// redial is on and has tries left.
+func (m *Menu) Redial() {
+	m.ToStart()
+
+	number, _ := m.Get("LastDialed").(string)
+	attempt, _ := m.Get("RedialAttempt").(int)
+	if number == "" || m.IsEmergencyNumber(number) || attempt >= redialAttempts[m.Get("AutoRedial").(string)] {
+		return
+	}
+	m.ToMenu("redial")
+}
+
+// ShowAutoRedial lets automatic redial be turned off or given a number of
+// tries, marking the current setting.
+func (instance *SettingsMenu) ShowAutoRedial() int {
+	current := instance.parent.Get("AutoRedial").(string)
+	var options [][]string
+	for _, option := range redialOptions {
+		if option == current {
+			option += " (current)"
+		}
+		options = append(options, []string{option})
+	}
+
+	go instance.parent.PushWithArgs("selector", &SelectorArgs{
+		SelectionClass: "settings.auto_redial",
+		Title:          "Automatic Redial",
+		Options:        options,
+		ButtonLabel:    "Select",
+		VisibleRows:    3,
+	})
+	return SettingsActionSubmenuPushed
+}
+
+// SetAutoRedial saves how many times automatic redial tries again.
+func (instance *SettingsMenu) SetAutoRedial(label string) int {
+	option := strings.TrimSuffix(label, " (current)")
+	if _, ok := redialAttempts[option]; !ok {
+		return SettingsActionShowSelector
+	}
+
+	instance.parent.Set("AutoRedial", option)
+	go instance.parent.SyncPersistent()
+
+	if option == "Off" {
+		instance.parent.RenderAlert("ok", []string{"Automatic", "redial off"})
+	} else {
+		instance.parent.RenderAlert("ok", []string{"Automatic", "redial on"})
+	}
+	time.Sleep(2 * time.Second)
+	return SettingsActionShowSelector
+}
+
+// How many rings automatic answer waits for, by menu label
+var answerRings = map[string]int{
+	"Off":           0,
+	"After 1 ring":  1,
+	"After 3 rings": 3,
+	"After 5 rings": 5,
+}
+
+var answerOptions = []string{"Off", "After 1 ring", "After 3 rings", "After 5 rings"}
+
+// ShowAutoAnswer lets automatic answer be turned off or set to wait for a
+// number of rings, and be kept to callers in the phonebook, marking the
+// current settings.
+func (instance *SettingsMenu) ShowAutoAnswer() int {
+	current := instance.parent.Get("AutoAnswer").(string)
+	var options [][]string
+	for _, option := range answerOptions {
+		if option == current {
+			option += " (current)"
+		}
+		options = append(options, []string{option})
+	}
+
+	callers := [][]string{{"Any caller"}, {"Contacts only"}}
+	if instance.parent.Get("AutoAnswerContactsOnly").(bool) {
+		callers[1][0] += " (current)"
+	} else {
+		callers[0][0] += " (current)"
+	}
+	options = append(options, callers...)
+
+	go instance.parent.PushWithArgs("selector", &SelectorArgs{
+		SelectionClass: "settings.auto_answer",
+		Title:          "Automatic Answer",
+		Options:        options,
+		ButtonLabel:    "Select",
+		VisibleRows:    3,
+	})
+	return SettingsActionSubmenuPushed
+}
+
+// SetAutoAnswer saves how many rings automatic answer waits for, or which
+// callers it answers.
+func (instance *SettingsMenu) SetAutoAnswer(label string) int {
+	option := strings.TrimSuffix(label, " (current)")
+	switch option {
+	case "Any caller", "Contacts only":
+		instance.parent.Set("AutoAnswerContactsOnly", option == "Contacts only")
+		go instance.parent.SyncPersistent()
+		instance.parent.RenderAlert("ok", []string{option, "selected"})
+		time.Sleep(2 * time.Second)
+		return SettingsActionShowSelector
+	}
+	if _, ok := answerRings[option]; !ok {
+		return SettingsActionShowSelector
+	}
+
+	instance.parent.Set("AutoAnswer", option)
+	go instance.parent.SyncPersistent()
+
+	if option == "Off" {
+		instance.parent.RenderAlert("ok", []string{"Automatic", "answer off"})
+	} else {
+		instance.parent.RenderAlert("ok", []string{"Automatic", "answer on"})
+	}
+	time.Sleep(2 * time.Second)
+	return SettingsActionShowSelector
+}
+
+// RedialMenu counts down to calling the last dialed number again, which any
+// key cancels.
+type RedialMenu struct {
+	ctx        context.Context
+	configured bool
+	cancelFn   context.CancelFunc
+	parent     *Menu
+	wg         sync.WaitGroup
+}
+
+func (m *Menu) NewRedialMenu() *RedialMenu {
+	return &RedialMenu{
+		parent: m,
+	}
+}
+
+func (instance *RedialMenu) render(number string, attempt int, remaining time.Duration) {
+	display := instance.parent.Display
+
+	display.Clear(sh1107.Black)
+
+	font := display.Use_Font8_Normal()
+	display.DrawTextAligned(0, 20, font, "Redial", false, sh1107.AlignRight, sh1107.AlignNone)
+
+	display.SetColor(sh1107.White)
+	display.SetLineWidth(1)
+	display.DrawLine(0, 33, 127, 33)
+	display.Stroke()
+
+	tries := redialAttempts[instance.parent.Get("AutoRedial").(string)]
+	display.DrawText(0, 38, font, instance.parent.ContactName(number), false)
+	display.DrawText(0, 49, font, fmt.Sprintf("Attempt %d of %d", attempt, tries), false)
+	display.DrawText(0, 60, font, fmt.Sprintf("Calling in %d s", int(remaining.Seconds())), false)
+
+	font = display.Use_Font8_Bold()
+	display.DrawTextAligned(64, 105, font, "Cancel", false, sh1107.AlignCenter, sh1107.AlignNone)
+
+	display.Render()
+}
+
+func (instance *RedialMenu) Configure() {
+	// Reset context
+	instance.configured = true
+	instance.ctx, instance.cancelFn = context.WithCancel(instance.parent.GlobalContext)
+}
+
+func (instance *RedialMenu) ConfigureWithArgs(args ...any) {
+	// Unused
+	instance.Configure()
+}
+
+// Shows the countdown, returning true once it runs out and false if it was
+// cancelled
+func (instance *RedialMenu) countdown(number string, attempt int) bool {
+	instance.wg.Add(1)
+	defer instance.wg.Done()
+
+	// Stay on while it counts down
+	instance.parent.Timers["oled"].Stop()
+	instance.parent.Timers["keypad"].Stop()
+	instance.parent.Display.On()
+	instance.parent.Backlight.On()
+	defer instance.parent.Timers["oled"].Restart()
+	defer instance.parent.Timers["keypad"].Restart()
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for remaining := redialDelay; remaining > 0; {
+		instance.render(number, attempt, remaining)
+
+		select {
+		case <-instance.ctx.Done():
+			return false
+
+		case <-ticker.C:
+			remaining -= time.Second
+
+		case evt := <-instance.parent.KeypadEvents:
+			if !evt.State {
+				continue
+			}
+			go instance.parent.PlayKey()
+
+			log.Println("📞 Redial cancelled")
+			if evt.Key == 'P' {
+				go instance.parent.Push("power")
+			} else {
+				go instance.parent.Pop()
+			}
+			return false
+		}
+	}
+	return true
+}
+
+func (instance *RedialMenu) Run() {
+	if !instance.configured {
+		panic("Attempted to call (*RedialMenu).Run() before (*RedialMenu).Configure()!")
+	}
+
+	number, _ := instance.parent.Get("LastDialed").(string)
+	attempt, _ := instance.parent.Get("RedialAttempt").(int)
+	attempt++
+
+	if !instance.countdown(number, attempt) {
+		return
+	}
+
+	// It may have lost service while counting down
+	if reason := instance.parent.DialBlockedReason(number); reason != nil {
+		instance.parent.RenderAlert("prohibited", reason)
+		go instance.parent.PlayAlert()
+		time.Sleep(2 * time.Second)
+		go instance.parent.Pop()
+		return
+	}
+
+	log.Printf("📞 Redialing %s, attempt %d", number, attempt)
+	instance.parent.Set("RedialAttempt", attempt)
+	instance.parent.dial(number)
+}
+
+func (instance *RedialMenu) Pause() {
+	instance.cancelFn()
+	if ok := waitWithTimeout(&instance.wg, 1*time.Second); !ok {
+		log.Println("⚠️ Redial menu pause timed out — goroutines may be stuck")
+		// Optional: escalate here
+	}
+}
+
+func (instance *RedialMenu) Stop() {
+	instance.cancelFn()
+	if ok := waitWithTimeout(&instance.wg, 1*time.Second); !ok {
+		log.Println("⚠️ Redial menu stop timed out — goroutines may be stuck")
+		// Optional: escalate here
+	}
+}
@@ -20,6 +20,7 @@ type RingMenu struct {
 	batt_flash  bool
 	data_flash  bool
 	render_loop *timers.ResettableTimer
+	caller      callerID
 }
 
 func (m *Menu) NewRingMenu() *RingMenu {
@@ -28,6 +29,19 @@ func (m *Menu) NewRingMenu() *RingMenu {
 	}
 }
 
+// How many rings to answer the call after, or 0 if it's left to the user
+func (instance *RingMenu) autoAnswerRings() int {
+	rings := answerRings[instance.parent.Get("AutoAnswer").(string)]
+	if rings == 0 {
+		return 0
+	}
+	if instance.parent.Get("AutoAnswerContactsOnly").(bool) &&
+		instance.parent.LookupContact(instance.parent.Modem.State().Call.PhoneNumber) == nil {
+		return 0
+	}
+	return rings
+}
+
 func (instance *RingMenu) render() {
 	display := instance.parent.Display
 	display.Clear(sh1107.Black)
@@ -35,10 +49,10 @@ func (instance *RingMenu) render() {
 
 	font := display.Use_Font8_Bold()
 	display.DrawTextAligned(64, 105, font, "Answer", false, sh1107.AlignCenter, sh1107.AlignNone)
-	display.DrawTextAligned(0, 65, font, instance.parent.Modem.CallState.Status, false, sh1107.AlignRight, sh1107.AlignNone)
+	call := instance.parent.Modem.State().Call
+	display.DrawTextAligned(0, 65, font, call.Status, false, sh1107.AlignRight, sh1107.AlignNone)
 
-	font = display.Use_Font16()
-	display.DrawText(0, 45, font, instance.parent.Modem.CallState.PhoneNumber, false)
+	instance.parent.renderCaller(&instance.caller, call.PhoneNumber)
 
 	display.Render()
 }
@@ -59,6 +73,9 @@ func (instance *RingMenu) Run() {
 		panic("Attempted to call (*RingMenu).Run() before (*RingMenu).Configure()!")
 	}
 
+	// Look the caller up again, the phonebook may have changed since
+	instance.caller = callerID{}
+
 	if instance.parent.Get("CanVibrate").(bool) {
 		instance.wg.Go(func() {
 			for {
@@ -85,13 +102,39 @@ func (instance *RingMenu) Run() {
 		})
 
 	} else if instance.parent.Get("CanRing").(bool) {
+
+		// Ring with the caller's own tone if they have one
+		ringtone := ""
+		if contact := instance.parent.LookupContact(instance.parent.Modem.State().Call.PhoneNumber); contact != nil {
+			ringtone = contact.Ringtone
+		}
+
 		instance.wg.Go(func() {
 			for {
 				select {
 				case <-instance.ctx.Done():
 					return
 				default:
-					misc.PlayRingtone(instance.parent.Player, instance.ctx)
+					misc.PlayRingtoneNamed(instance.parent.Player, instance.ctx, ringtone)
+				}
+			}
+		})
+	}
+
+	// Answer by itself once the caller has rung long enough
+	if rings := instance.autoAnswerRings(); rings > 0 {
+		instance.wg.Go(func() {
+			for {
+				select {
+				case <-instance.ctx.Done():
+					return
+
+				case <-time.After(100 * time.Millisecond):
+					if instance.parent.Modem.State().Call.Rings >= rings {
+						log.Printf("📞 Answering automatically after %d rings", rings)
+						instance.parent.Modem.Answer()
+						return
+					}
 				}
 			}
 		})
@@ -153,7 +196,7 @@ func (instance *RingMenu) Run() {
 					instance.parent.Timers["keypad"].Reset()
 					instance.parent.Timers["oled"].Reset()
 					instance.parent.Display.On()
-					misc.KeyLightsOn()
+					instance.parent.Backlight.On()
 					switch evt.Key {
 					case 'S':
 						go instance.parent.PlayKey()
@@ -7,7 +7,6 @@ import (
 	"sync"
 	"time"
 
-	"misc"
 	"sh1107"
 )
 
@@ -131,7 +130,7 @@ func (instance *Screensaver) Run() {
 					instance.parent.Timers["keypad"].Restart()
 					instance.parent.Timers["oled"].Restart()
 					instance.parent.Display.On()
-					misc.KeyLightsOn()
+					instance.parent.Backlight.On()
 					go instance.parent.PlayKey()
 					go instance.parent.Pop()
 					return
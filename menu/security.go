@@ -0,0 +1,136 @@
+package menu
+
+import (
+	"log"
+	"time"
+
+	"phone"
+)
+
+// Codes that can be changed from Change access codes, by menu label
+var accessCodes = map[string]string{
+	"PIN code":         phone.FacilitySIM,
+	"PIN2 code":        phone.FacilityPIN2,
+	"Barring password": phone.BarAll,
+}
+
+// Hint with the tries left for a code, or nothing if the SIM won't say. The
+// network doesn't say for the barring password.
+func (instance *SettingsMenu) codeHint(facility string) string {
+	if facility == phone.BarAll {
+		return ""
+	}
+	attempts, err := instance.parent.Modem.CodeAttempts(instance.ctx)
+	if err != nil {
+		return ""
+	}
+	if facility == phone.FacilityPIN2 {
+		return triesLeft(attempts.PIN2)
+	}
+	return triesLeft(attempts.PIN)
+}
+
+// Tells the user their code was wrong. If that blocked the SIM, it goes back
+// to the home screen, which asks for the PUK, and returns
+// SettingsActionSubmenuPushed.
+func (instance *SettingsMenu) codeError(err error) int {
+	log.Println("🔒 Code not accepted:", err)
+	instance.parent.RenderAlert("alert", []string{"Code", "error"})
+	go instance.parent.PlayAlert()
+	time.Sleep(2 * time.Second)
+
+	if instance.parent.Modem.State().SIMLock == phone.SIMNeedsPUK {
+		instance.parent.RenderAlert("prohibited", []string{"PIN code", "blocked"})
+		time.Sleep(2 * time.Second)
+		go instance.parent.ToStart()
+		return SettingsActionSubmenuPushed
+	}
+	return SettingsActionShowSelector
+}
+
+// ShowPINRequest lets the PIN request be turned on or off, marking the
+// current setting.
+func (instance *SettingsMenu) ShowPINRequest() int {
+	if !instance.parent.SIMAvailable() {
+		return SettingsActionShowSelector
+	}
+
+	enabled, err := instance.parent.Modem.PINRequest(instance.ctx)
+	if err != nil {
+		log.Println("⚠️ Failed to check PIN request:", err)
+	}
+
+	options := [][]string{{"On"}, {"Off"}}
+	if enabled {
+		options[0][0] = "On (current)"
+	} else {
+		options[1][0] = "Off (current)"
+	}
+
+	go instance.parent.PushWithArgs("selector", &SelectorArgs{
+		SelectionClass: "settings.pin_request",
+		Title:          "PIN code request",
+		Options:        options,
+		ButtonLabel:    "Select",
+		VisibleRows:    3,
+	})
+	return SettingsActionSubmenuPushed
+}
+
+// SetPINRequest asks for the PIN and turns the PIN request on or off.
+func (instance *SettingsMenu) SetPINRequest(enabled bool) int {
+	if !instance.parent.SIMAvailable() {
+		return SettingsActionShowSelector
+	}
+
+	pin := instance.parent.EnterCode("PIN code", instance.codeHint(phone.FacilitySIM), instance.ctx)
+	if pin == "" {
+		return SettingsActionShowSelector
+	}
+
+	if err := instance.parent.Modem.SetPINRequest(instance.ctx, enabled, pin); err != nil {
+		return instance.codeError(err)
+	}
+
+	if enabled {
+		instance.parent.RenderAlert("ok", []string{"PIN code", "request on"})
+	} else {
+		instance.parent.RenderAlert("ok", []string{"PIN code", "request off"})
+	}
+	time.Sleep(2 * time.Second)
+	return SettingsActionShowSelector
+}
+
+// ChangeAccessCode asks for the current code and a new one twice, then
+// changes the PIN, PIN2 or barring password.
+func (instance *SettingsMenu) ChangeAccessCode(name string) int {
+	facility, ok := accessCodes[name]
+	if !ok || !instance.parent.SIMAvailable() {
+		return SettingsActionShowSelector
+	}
+
+	old_code := instance.parent.EnterCode(name, instance.codeHint(facility), instance.ctx)
+	if old_code == "" {
+		return SettingsActionShowSelector
+	}
+	new_code := instance.parent.EnterCode("New "+name, "", instance.ctx)
+	if new_code == "" {
+		return SettingsActionShowSelector
+	}
+	if instance.parent.EnterCode("Verify new code", "", instance.ctx) != new_code {
+		if instance.ctx.Err() == nil {
+			instance.parent.RenderAlert("alert", []string{"Codes do", "not match"})
+			go instance.parent.PlayAlert()
+			time.Sleep(2 * time.Second)
+		}
+		return SettingsActionShowSelector
+	}
+
+	if err := instance.parent.Modem.ChangeCode(instance.ctx, facility, old_code, new_code); err != nil {
+		return instance.codeError(err)
+	}
+
+	instance.parent.RenderAlert("ok", []string{name, "changed"})
+	time.Sleep(2 * time.Second)
+	return SettingsActionShowSelector
+}
@@ -8,7 +8,6 @@ import (
 	"sync"
 	"time"
 
-	"misc"
 	"sh1107"
 )
 
@@ -238,7 +237,7 @@ func (instance *Selector) Run() {
 					instance.parent.Timers["keypad"].Reset()
 					instance.parent.Timers["oled"].Reset()
 					instance.parent.Display.On()
-					misc.KeyLightsOn()
+					instance.parent.Backlight.On()
 
 					state := instance.selectors[instance.selectionclass]
 					current_options := instance.get_current_options()
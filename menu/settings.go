@@ -6,6 +6,7 @@ import (
 	"log"
 	"misc"
 	"os/exec"
+	"phone"
 	"sh1107"
 	"sort"
 	"strings"
@@ -36,6 +37,9 @@ type SettingsMenu struct {
 	ap_cache          map[string]gonetworkmanager.AccessPoint
 	conn_cache        map[string]gonetworkmanager.Connection
 	bt_cache          map[string]string
+	operator_cache    map[string]phone.Operator   // Selector label -> network from the last scan
+	fdn_cache         map[string]phone.SIMContact // Selector label -> fixed dialing entry from the last read
+	speed_dial_cache  map[string]string           // Selector label -> phonebook number to put on a speed dial key
 	current_target    string
 }
 
@@ -131,6 +135,7 @@ func (m *Menu) NewSettingsMenu() *SettingsMenu {
 			{"Cellular Settings",
 				"Toggle data",
 				"Network selection",
+				"Network mode",
 				"Configure APN",
 			},
 			{"Bluetooth Settings",
@@ -145,6 +150,7 @@ func (m *Menu) NewSettingsMenu() *SettingsMenu {
 			},
 			{"Phone Settings",
 				"Language",
+				"Clock",
 				"Cell Info Display",
 				"Welcome Note",
 				"Lights",
@@ -202,6 +208,16 @@ func (instance *SettingsMenu) ConfigureWithArgs(args ...any) {
 // For example, if the selection path is ["Phone Settings", "Language"],
 // it will call the Language method.
 func (instance *SettingsMenu) SettingsMain(selection_path []string) int {
+	// WiFi settings need NetworkManager, which isn't available when running headless
+	switch selection_path[len(selection_path)-1] {
+	case "Toggle WiFi", "Internet status", "Join network":
+		if instance.parent.NetworkManager == nil {
+			instance.parent.RenderAlert("alert", []string{"WiFi", "device", "error"})
+			time.Sleep(2 * time.Second)
+			return SettingsActionShowSelector
+		}
+	}
+
 	switch selection_path[len(selection_path)-1] {
 
 	case "About":
@@ -220,7 +236,7 @@ func (instance *SettingsMenu) SettingsMain(selection_path []string) int {
 				instance.parent.Timers["keypad"].Reset()
 				instance.parent.Timers["oled"].Reset()
 				instance.parent.Display.On()
-				misc.KeyLightsOn()
+				instance.parent.Backlight.On()
 				go instance.parent.PlayKey()
 
 				switch evt.Key {
@@ -277,7 +293,7 @@ func (instance *SettingsMenu) SettingsMain(selection_path []string) int {
 				instance.parent.Timers["keypad"].Reset()
 				instance.parent.Timers["oled"].Reset()
 				instance.parent.Display.On()
-				misc.KeyLightsOn()
+				instance.parent.Backlight.On()
 				go instance.parent.PlayKey()
 
 				switch evt.Key {
@@ -387,7 +403,7 @@ func (instance *SettingsMenu) SettingsMain(selection_path []string) int {
 		// Temporarily stop timeouts for oled (prevent sleep mode from happening)
 		instance.parent.Timers["oled"].Stop()
 		instance.parent.Timers["keypad"].Stop()
-		misc.KeyLightsOn()
+		instance.parent.Backlight.On()
 
 		var found_devices [][]string
 
@@ -473,6 +489,55 @@ func (instance *SettingsMenu) SettingsMain(selection_path []string) int {
 
 	case "Factory Reset":
 		// TODO
+
+	case "Toggle data":
+		return instance.ToggleData()
+
+	case "Network selection":
+		return instance.ShowNetworkSelection()
+
+	case "Network mode":
+		return instance.ShowNetworkMode()
+
+	case "Configure APN":
+		return instance.ShowAPN()
+
+	case "Clock":
+		return instance.ShowClock()
+
+	case "Cell Info Display":
+		return instance.ShowCellInfoDisplay()
+
+	case "Automatic Redial":
+		return instance.ShowAutoRedial()
+
+	case "Automatic Answer":
+		return instance.ShowAutoAnswer()
+
+	case "Speed Dialing":
+		return instance.ShowSpeedDials()
+
+	case "PIN code request":
+		return instance.ShowPINRequest()
+
+	case "Call barring service":
+		return instance.ShowCallBarring()
+
+	case "Fixed dialing":
+		return instance.ShowFixedDialing()
+
+	case "Closed user group":
+		return instance.ShowCUG()
+
+	case "Change access codes":
+		go instance.parent.PushWithArgs("selector", &SelectorArgs{
+			SelectionClass: "settings.access_codes",
+			Title:          "Change access codes",
+			Options:        [][]string{{"PIN code"}, {"PIN2 code"}, {"Barring password"}},
+			ButtonLabel:    "Select",
+			VisibleRows:    3,
+		})
+		return SettingsActionSubmenuPushed
 	}
 
 	return SettingsActionShowSelector
@@ -654,6 +719,127 @@ func (instance *SettingsMenu) Run() {
 			return
 		}
 
+	case "settings.pin_request":
+		if len(instance.selection_path) > 0 {
+			enabled := strings.HasPrefix(instance.selection_path[0], "On")
+			if instance.SetPINRequest(enabled) == SettingsActionSubmenuPushed || instance.ctx.Err() != nil {
+				return
+			}
+		}
+
+	case "settings.auto_redial":
+		if len(instance.selection_path) > 0 {
+			if instance.SetAutoRedial(instance.selection_path[0]) == SettingsActionSubmenuPushed || instance.ctx.Err() != nil {
+				return
+			}
+		}
+
+	case "settings.auto_answer":
+		if len(instance.selection_path) > 0 {
+			if instance.SetAutoAnswer(instance.selection_path[0]) == SettingsActionSubmenuPushed || instance.ctx.Err() != nil {
+				return
+			}
+		}
+
+	case "settings.speed_dial":
+		if len(instance.selection_path) > 0 {
+			if instance.SpeedDial(instance.selection_path) == SettingsActionSubmenuPushed || instance.ctx.Err() != nil {
+				return
+			}
+		}
+
+	case "settings.speed_dial_contact":
+		if len(instance.selection_path) > 0 {
+			if instance.SetSpeedDialContact(instance.selection_path[0]) == SettingsActionSubmenuPushed || instance.ctx.Err() != nil {
+				return
+			}
+		}
+		instance.current_target = ""
+
+	case "settings.barring":
+		if len(instance.selection_path) > 0 {
+			if instance.CallBarring(instance.selection_path) == SettingsActionSubmenuPushed || instance.ctx.Err() != nil {
+				return
+			}
+		}
+
+	case "settings.fixed_dialing":
+		if len(instance.selection_path) > 0 {
+			if instance.SetFixedDialing(instance.selection_path[0]) == SettingsActionSubmenuPushed || instance.ctx.Err() != nil {
+				return
+			}
+		}
+
+	case "settings.fixed_numbers":
+		if len(instance.selection_path) > 0 {
+			if instance.FixedNumber(instance.selection_path) == SettingsActionSubmenuPushed || instance.ctx.Err() != nil {
+				return
+			}
+		}
+
+	case "settings.cug":
+		if len(instance.selection_path) > 0 {
+			if instance.SetCUG(instance.selection_path[0]) == SettingsActionSubmenuPushed || instance.ctx.Err() != nil {
+				return
+			}
+		}
+
+	case "settings.access_codes":
+		if len(instance.selection_path) > 0 {
+			if instance.ChangeAccessCode(instance.selection_path[0]) == SettingsActionSubmenuPushed || instance.ctx.Err() != nil {
+				return
+			}
+		}
+
+	case "settings.network_selection":
+		if len(instance.selection_path) > 0 {
+			if instance.SelectNetwork(instance.selection_path[0]) == SettingsActionSubmenuPushed || instance.ctx.Err() != nil {
+				return
+			}
+		}
+
+	case "settings.operators":
+		if len(instance.selection_path) > 0 {
+			if instance.RegisterOperator(instance.selection_path[0]) == SettingsActionSubmenuPushed || instance.ctx.Err() != nil {
+				return
+			}
+		}
+
+	case "settings.network_mode":
+		if len(instance.selection_path) > 0 {
+			if instance.SetNetworkMode(instance.selection_path[0]) == SettingsActionSubmenuPushed || instance.ctx.Err() != nil {
+				return
+			}
+		}
+
+	case "settings.cell_info":
+		if len(instance.selection_path) > 0 {
+			if instance.SetCellInfoDisplay(instance.selection_path[0]) == SettingsActionSubmenuPushed || instance.ctx.Err() != nil {
+				return
+			}
+		}
+
+	case "settings.clock":
+		if len(instance.selection_path) > 0 {
+			if instance.SetClockMode(instance.selection_path[0]) == SettingsActionSubmenuPushed || instance.ctx.Err() != nil {
+				return
+			}
+		}
+
+	case "settings.apn":
+		if len(instance.selection_path) > 0 {
+			if instance.EditAPN(instance.selection_path[0]) == SettingsActionSubmenuPushed {
+				return
+			}
+		}
+
+	case "settings.apn_auth":
+		if len(instance.selection_path) > 0 {
+			if instance.SetAPNAuth(instance.selection_path[0]) == SettingsActionSubmenuPushed {
+				return
+			}
+		}
+
 	case "settings.btpair":
 
 		// Launch bluetooth pairing handler
@@ -892,6 +1078,9 @@ func (instance *SettingsMenu) cleanup() {
 	instance.ap_cache = nil
 	instance.conn_cache = nil
 	instance.bt_cache = nil
+	instance.operator_cache = nil
+	instance.fdn_cache = nil
+	instance.speed_dial_cache = nil
 }
 
 func (instance *SettingsMenu) GetNetworkState() string {
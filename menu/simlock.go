@@ -0,0 +1,192 @@
+package menu
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"phone"
+)
+
+// SIMLockMenu asks for the code a locked SIM is waiting for when the phone
+// starts, and for the PUK once the PIN has been entered wrong too many times.
+// It can't be left until the SIM is unlocked.
+type SIMLockMenu struct {
+	ctx        context.Context
+	configured bool
+	cancelFn   context.CancelFunc
+	parent     *Menu
+	wg         sync.WaitGroup
+}
+
+func (m *Menu) NewSIMLockMenu() *SIMLockMenu {
+	return &SIMLockMenu{
+		parent: m,
+	}
+}
+
+// Titles for the code each lock is waiting for
+var simLockTitles = map[string]string{
+	phone.SIMNeedsPIN:      "PIN code",
+	phone.SIMNeedsPIN2:     "PIN2 code",
+	phone.SIMNeedsPhoneSIM: "Security code",
+	phone.SIMNeedsNetwork:  "Network code",
+}
+
+// Describes how many tries a code has left
+func triesLeft(tries int) string {
+	if tries == 1 {
+		return "1 try left"
+	}
+	return fmt.Sprintf("%d tries left", tries)
+}
+
+func (instance *SIMLockMenu) Configure() {
+	// Reset context
+	instance.configured = true
+	instance.ctx, instance.cancelFn = context.WithCancel(instance.parent.GlobalContext)
+}
+
+func (instance *SIMLockMenu) ConfigureWithArgs(args ...any) {
+	// Unused
+	instance.Configure()
+}
+
+// Asks for the PIN, or whichever code the SIM wants. Returns false if it was
+// cancelled.
+func (instance *SIMLockMenu) enterPIN(lock string) bool {
+	modem := instance.parent.Modem
+
+	hint := ""
+	if lock == phone.SIMNeedsPIN {
+		if attempts, err := modem.CodeAttempts(instance.ctx); err == nil {
+			hint = triesLeft(attempts.PIN)
+		}
+	}
+
+	title, ok := simLockTitles[lock]
+	if !ok {
+		title = lock
+	}
+
+	code := instance.parent.EnterCode(title, hint, instance.ctx)
+	if code == "" {
+		return false
+	}
+
+	instance.parent.RenderAlert("loading", []string{"Checking", "code..."})
+	if err := modem.EnterPIN(instance.ctx, code); err != nil {
+		log.Println("🔒 Code not accepted:", err)
+		instance.parent.RenderAlert("alert", []string{"Code", "error"})
+		go instance.parent.PlayAlert()
+		time.Sleep(2 * time.Second)
+
+		if modem.State().SIMLock == phone.SIMNeedsPUK {
+			instance.parent.RenderAlert("prohibited", []string{"PIN code", "blocked"})
+			time.Sleep(2 * time.Second)
+		}
+		return true
+	}
+
+	instance.parent.RenderAlert("ok", []string{"Code", "accepted"})
+	time.Sleep(2 * time.Second)
+	return true
+}
+
+// Asks for the PUK and a new PIN to unblock the SIM. Returns false if it was
+// cancelled.
+func (instance *SIMLockMenu) enterPUK() bool {
+	modem := instance.parent.Modem
+
+	hint := ""
+	if attempts, err := modem.CodeAttempts(instance.ctx); err == nil {
+		hint = triesLeft(attempts.PUK)
+	}
+
+	puk := instance.parent.EnterCode("PUK code", hint, instance.ctx)
+	if puk == "" {
+		return false
+	}
+	pin := instance.parent.EnterCode("New PIN code", "", instance.ctx)
+	if pin == "" {
+		return false
+	}
+	if instance.parent.EnterCode("Verify new PIN", "", instance.ctx) != pin {
+		if instance.ctx.Err() != nil {
+			return false
+		}
+		instance.parent.RenderAlert("alert", []string{"Codes do", "not match"})
+		go instance.parent.PlayAlert()
+		time.Sleep(2 * time.Second)
+		return true
+	}
+
+	instance.parent.RenderAlert("loading", []string{"Checking", "code..."})
+	if err := modem.EnterPUK(instance.ctx, puk, pin); err != nil {
+		log.Println("🔒 Code not accepted:", err)
+		instance.parent.RenderAlert("alert", []string{"Code", "error"})
+		go instance.parent.PlayAlert()
+		time.Sleep(2 * time.Second)
+		return true
+	}
+
+	instance.parent.RenderAlert("ok", []string{"PIN code", "changed"})
+	time.Sleep(2 * time.Second)
+	return true
+}
+
+func (instance *SIMLockMenu) Run() {
+	if !instance.configured {
+		panic("Attempted to call (*SIMLockMenu).Run() before (*SIMLockMenu).Configure()!")
+	}
+
+	instance.wg.Add(1)
+	defer instance.wg.Done()
+
+	for {
+		lock := ""
+		if instance.parent.Modem != nil {
+			lock = instance.parent.Modem.State().SIMLock
+		}
+		log.Printf("🔒 SIM lock: %q", lock)
+
+		var entered bool
+		switch lock {
+		case "":
+			go instance.parent.Pop()
+			return
+		case phone.SIMNeedsPUK:
+			entered = instance.enterPUK()
+		default:
+			entered = instance.enterPIN(lock)
+		}
+
+		// Paused for the power menu, ask again when it comes back
+		if instance.ctx.Err() != nil {
+			return
+		}
+
+		// The SIM has to be unlocked before anything else
+		if !entered {
+			go instance.parent.PlayAlert()
+		}
+	}
+}
+
+func (instance *SIMLockMenu) Pause() {
+	instance.cancelFn()
+	if ok := waitWithTimeout(&instance.wg, 1*time.Second); !ok {
+		log.Println("⚠️ SIM lock menu pause timed out — goroutines may be stuck")
+		// Optional: escalate here
+	}
+}
+
+func (instance *SIMLockMenu) Stop() {
+	instance.cancelFn()
+	if ok := waitWithTimeout(&instance.wg, 1*time.Second); !ok {
+		log.Println("⚠️ SIM lock menu stop timed out — goroutines may be stuck")
+		// Optional: escalate here
+	}
+}
@@ -0,0 +1,164 @@
+package menu
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// SpeedDialKeys are the keys a number can be put on. Key 1 is the voicemail
+// number.
+const SpeedDialKeys = "123456789"
+
+// How long a key has to be held on the home screen to speed dial
+const speedDialHold = time.Second
+
+// SpeedDialSetting returns the persistent key the number on a speed dial key
+// is saved under.
+func SpeedDialSetting(key rune) string {
+	if key == '1' {
+		return "VoicemailNumber"
+	}
+	return fmt.Sprintf("SpeedDial%c", key)
+}
+
+// SpeedDialNumber returns the number on a speed dial key, or "" if there's
+// none.
+func (m *Menu) SpeedDialNumber(key rune) string {
+	number, _ := m.Get(SpeedDialSetting(key)).(string)
+	return number
+}
+
+// Saves the number on a speed dial key, or clears it when number is ""
+func (m *Menu) setSpeedDial(key rune, number string) {
+	m.Set(SpeedDialSetting(key), number)
+	go m.SyncPersistent()
+}
+
+// Dials the number on a held key, or tells the user why it can't be. The
+// dialer closes either way.
+func (instance *DialerMenu) speedDial(key rune) {
+	number := instance.parent.SpeedDialNumber(key)
+	if number == "" {
+		if key == '1' {
+			instance.parent.RenderAlert("info", []string{"Voicemail", "number", "not set"})
+		} else {
+			instance.parent.RenderAlert("info", []string{fmt.Sprintf("Key %c", key), "not", "assigned"})
+		}
+		go instance.parent.PlayAlert()
+		time.Sleep(2 * time.Second)
+		go instance.parent.Pop()
+		return
+	}
+
+	if reason := instance.parent.DialBlockedReason(number); reason != nil {
+		instance.ExitWithAlert(reason)
+		return
+	}
+
+	log.Printf("📞 Speed dialing key %c", key)
+	go instance.parent.PlayKey()
+	instance.parent.Dial(number)
+}
+
+// ShowSpeedDials lists the speed dial keys with what's on each, so a number
+// or a phonebook entry can be put on one or taken off it.
+func (instance *SettingsMenu) ShowSpeedDials() int {
+	var options [][]string
+	for _, key := range SpeedDialKeys {
+		number := instance.parent.SpeedDialNumber(key)
+		switch {
+		case key == '1':
+			options = append(options, []string{"1 Voicemail", "Assign number", "Delete"})
+		case number == "":
+			options = append(options, []string{fmt.Sprintf("%c (empty)", key), "Assign number", "Assign contact"})
+		default:
+			label := fmt.Sprintf("%c %s", key, instance.parent.ContactName(number))
+			options = append(options, []string{label, "Assign number", "Assign contact", "Delete"})
+		}
+	}
+
+	go instance.parent.PushWithArgs("selector", &SelectorArgs{
+		SelectionClass: "settings.speed_dial",
+		Title:          "Speed Dialing",
+		Options:        options,
+		ButtonLabel:    "Select",
+		VisibleRows:    3,
+	})
+	return SettingsActionSubmenuPushed
+}
+
+// SpeedDial puts a typed number on the key picked, takes it off, or lists the
+// phonebook to pick a contact's number from.
+func (instance *SettingsMenu) SpeedDial(selection_path []string) int {
+	if len(selection_path) < 2 || selection_path[0] == "" {
+		return SettingsActionShowSelector
+	}
+	key := rune(selection_path[0][0])
+
+	switch selection_path[1] {
+	case "Assign number":
+		number := instance.parent.EnterPhoneNumber("Number", instance.parent.SpeedDialNumber(key), instance.ctx)
+		if number == "" {
+			return SettingsActionShowSelector
+		}
+		instance.parent.setSpeedDial(key, number)
+		instance.parent.RenderAlert("ok", []string{"Number", "saved"})
+
+	case "Assign contact":
+		entries := instance.parent.contactEntries()
+		if len(entries) == 0 {
+			instance.parent.RenderAlert("info", []string{"Phonebook", "empty"})
+			time.Sleep(2 * time.Second)
+			return SettingsActionShowSelector
+		}
+
+		instance.speed_dial_cache = make(map[string]string)
+		var options [][]string
+		for _, entry := range entries {
+			label := entry.Name
+			if _, taken := instance.speed_dial_cache[label]; taken {
+				label = fmt.Sprintf("%s (%s)", label, entry.Number)
+			}
+			instance.speed_dial_cache[label] = entry.Number
+			options = append(options, []string{label})
+		}
+
+		instance.current_target = string(key)
+		go instance.parent.PushWithArgs("selector", &SelectorArgs{
+			SelectionClass: "settings.speed_dial_contact",
+			Title:          "Assign contact",
+			Options:        options,
+			ButtonLabel:    "Assign",
+			VisibleRows:    3,
+		})
+		return SettingsActionSubmenuPushed
+
+	case "Delete":
+		if !instance.parent.Confirm([]string{"Delete", "speed dial?"}, instance.ctx) {
+			return SettingsActionShowSelector
+		}
+		instance.parent.setSpeedDial(key, "")
+		instance.parent.RenderAlert("ok", []string{"Speed dial", "deleted"})
+
+	default:
+		return SettingsActionShowSelector
+	}
+
+	time.Sleep(2 * time.Second)
+	return SettingsActionShowSelector
+}
+
+// SetSpeedDialContact puts the number of the contact picked on the key
+// SpeedDial listed the phonebook for.
+func (instance *SettingsMenu) SetSpeedDialContact(label string) int {
+	number, ok := instance.speed_dial_cache[label]
+	if !ok || instance.current_target == "" {
+		return SettingsActionShowSelector
+	}
+
+	instance.parent.setSpeedDial(rune(instance.current_target[0]), number)
+	instance.parent.RenderAlert("ok", []string{"Contact", "assigned"})
+	time.Sleep(2 * time.Second)
+	return SettingsActionShowSelector
+}
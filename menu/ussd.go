@@ -0,0 +1,303 @@
+package menu
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"phone"
+	"sh1107"
+)
+
+// How long to wait for the network to answer a USSD request
+const ussdTimeout = 30 * time.Second
+
+// USSDMenu runs a USSD session for a code typed into the dialer, like *611#
+// or *225#. Messages from the network scroll like a text message, and menus
+// from the network can be answered until it ends the session. *#06# shows the
+// IMEI without asking the network.
+type USSDMenu struct {
+	ctx        context.Context
+	configured bool
+	cancelFn   context.CancelFunc
+	parent     *Menu
+	wg         sync.WaitGroup
+	code       string      // What was dialed
+	request    string      // The code or reply to send next
+	sent       bool        // Whether request went out, so it isn't sent again after a pause
+	message    *phone.USSD // The last message from the network
+}
+
+func (m *Menu) NewUSSDMenu() *USSDMenu {
+	return &USSDMenu{
+		parent: m,
+	}
+}
+
+func (instance *USSDMenu) render(lines []string, offset int) {
+	display := instance.parent.Display
+
+	display.Clear(sh1107.Black)
+
+	font := display.Use_Font8_Normal()
+	display.DrawTextAligned(0, 20, font, instance.code, false, sh1107.AlignRight, sh1107.AlignNone)
+
+	display.SetColor(sh1107.White)
+	display.SetLineWidth(1)
+	display.DrawLine(0, 33, 127, 33)
+	display.Stroke()
+
+	end := min(offset+messageVisibleLines, len(lines))
+	for i, line := range lines[offset:end] {
+		display.DrawText(0, 38+i*11, font, line, false)
+	}
+
+	label := "OK"
+	if instance.message.Status == phone.USSDReply {
+		label = "Reply"
+	}
+	font = display.Use_Font8_Bold()
+	display.DrawTextAligned(64, 105, font, label, false, sh1107.AlignCenter, sh1107.AlignNone)
+
+	display.Render()
+}
+
+func (instance *USSDMenu) Configure() {
+	// Reset context
+	instance.configured = true
+	instance.ctx, instance.cancelFn = context.WithCancel(instance.parent.GlobalContext)
+}
+
+func (instance *USSDMenu) ConfigureWithArgs(args ...any) {
+	instance.Configure()
+	instance.cleanup()
+	if len(args) > 0 {
+		if code, ok := args[0].(string); ok {
+			instance.code = code
+			instance.request = code
+		}
+	}
+}
+
+// Whether the network is still waiting on the session
+func (instance *USSDMenu) open() bool {
+	return instance.sent && (instance.message == nil || instance.message.Status == phone.USSDReply)
+}
+
+// Tells the user why the session is over and leaves
+func (instance *USSDMenu) exitWithAlert(icon string, msg []string) {
+	instance.parent.RenderAlert(icon, msg)
+	if icon != "info" {
+		go instance.parent.PlayAlert()
+	}
+	time.Sleep(2 * time.Second)
+	go instance.parent.Pop()
+}
+
+// Sends the request and waits for the network to answer it. Returns false if
+// the menu was left, or paused for the power menu.
+func (instance *USSDMenu) exchange() bool {
+	modem := instance.parent.Modem
+
+	instance.parent.RenderAlert("loading", []string{"Requesting..."})
+	if !instance.sent {
+		if err := modem.SendUSSD(instance.ctx, instance.request); err != nil {
+			log.Println("⚠️ USSD request failed:", err)
+			instance.exitWithAlert("alert", []string{"Request", "failed"})
+			return false
+		}
+		instance.sent = true
+	}
+
+	timeout := time.After(ussdTimeout)
+	for {
+		select {
+		case <-instance.ctx.Done():
+			return false
+
+		case <-timeout:
+			instance.exitWithAlert("alert", []string{"No", "response"})
+			return false
+
+		case instance.message = <-modem.USSDChan:
+			return true
+
+		case evt := <-instance.parent.KeypadEvents:
+			if !evt.State {
+				continue
+			}
+
+			instance.parent.Timers["keypad"].Reset()
+			instance.parent.Timers["oled"].Reset()
+			instance.parent.Display.On()
+			instance.parent.Backlight.On()
+
+			switch evt.Key {
+			case 'C':
+				go instance.parent.PlayKey()
+				go instance.parent.Pop()
+				return false
+			case 'P':
+				go instance.parent.PlayKey()
+				go instance.parent.Push("power")
+				return false
+			}
+		}
+	}
+}
+
+// Shows the network's message. Returns the reply typed for it, or an empty
+// string if the message was closed.
+func (instance *USSDMenu) read() string {
+	display := instance.parent.Display
+	lines := wrapText(display, display.Use_Font8_Normal(), instance.message.Message, 127)
+	offset := 0
+	max_offset := max(len(lines)-messageVisibleLines, 0)
+
+	instance.render(lines, offset)
+	for {
+		select {
+		case <-instance.ctx.Done():
+			return ""
+		case evt := <-instance.parent.KeypadEvents:
+			if !evt.State {
+				continue
+			}
+
+			instance.parent.Timers["keypad"].Reset()
+			instance.parent.Timers["oled"].Reset()
+			instance.parent.Display.On()
+			instance.parent.Backlight.On()
+			go instance.parent.PlayKey()
+
+			switch evt.Key {
+			case 'P':
+				go instance.parent.Push("power")
+				return ""
+			case 'C':
+				return ""
+			case 'S':
+				if instance.message.Status != phone.USSDReply {
+					return ""
+				}
+				if reply := instance.parent.EnterTextInMode("Reply", "", T9Numbers, instance.ctx); reply != "" {
+					return reply
+				}
+				if instance.ctx.Err() != nil {
+					return ""
+				}
+				instance.render(lines, offset)
+			case 'U':
+				if offset > 0 {
+					offset--
+					instance.render(lines, offset)
+				}
+			case 'D':
+				if offset < max_offset {
+					offset++
+					instance.render(lines, offset)
+				}
+			}
+		}
+	}
+}
+
+func (instance *USSDMenu) Run() {
+	if !instance.configured {
+		panic("Attempted to call (*USSDMenu).Run() before (*USSDMenu).Configure()!")
+	}
+
+	instance.wg.Add(1)
+	defer instance.wg.Done()
+
+	// The IMEI is shown by the phone itself
+	if instance.code == phone.IMEICode && instance.message == nil {
+		if instance.parent.Modem == nil {
+			instance.exitWithAlert("alert", []string{"Modem", "not found"})
+			return
+		}
+		imei, err := instance.parent.Modem.IMEI(instance.ctx)
+		if err != nil {
+			log.Println("⚠️ Failed to read IMEI:", err)
+			instance.exitWithAlert("alert", []string{"Request", "failed"})
+			return
+		}
+		instance.message = &phone.USSD{Status: phone.USSDDone, Message: "IMEI:\n" + imei}
+	}
+
+	for {
+		if instance.message == nil {
+			if !instance.sent {
+				if reason := instance.parent.CallBlockedReason(); reason != nil {
+					instance.exitWithAlert("prohibited", reason)
+					return
+				}
+			}
+			if !instance.exchange() {
+				return
+			}
+		}
+
+		switch instance.message.Status {
+		case phone.USSDDone, phone.USSDReply:
+		case phone.USSDUnsupported:
+			instance.exitWithAlert("alert", []string{"Request", "not", "supported"})
+			return
+		case phone.USSDTimeout:
+			instance.exitWithAlert("info", []string{"Session", "timed out"})
+			return
+		default:
+			instance.exitWithAlert("info", []string{"Session", "ended"})
+			return
+		}
+
+		if instance.message.Message == "" {
+			instance.exitWithAlert("ok", []string{"Request", "done"})
+			return
+		}
+
+		reply := instance.read()
+		if instance.ctx.Err() != nil {
+			return
+		}
+		if reply == "" {
+			go instance.parent.Pop()
+			return
+		}
+
+		instance.request = reply
+		instance.sent = false
+		instance.message = nil
+	}
+}
+
+func (instance *USSDMenu) Pause() {
+	instance.cancelFn()
+	if ok := waitWithTimeout(&instance.wg, 1*time.Second); !ok {
+		log.Println("⚠️ USSD menu pause timed out — goroutines may be stuck")
+		// Optional: escalate here
+	}
+}
+
+func (instance *USSDMenu) Stop() {
+	instance.cancelFn()
+	if ok := waitWithTimeout(&instance.wg, 1*time.Second); !ok {
+		log.Println("⚠️ USSD menu stop timed out — goroutines may be stuck")
+		// Optional: escalate here
+	} else {
+		instance.cleanup()
+	}
+}
+
+func (instance *USSDMenu) cleanup() {
+	// Leaving in the middle of a session ends it
+	if instance.open() && instance.parent.Modem != nil {
+		go instance.parent.Modem.CancelUSSD(instance.parent.GlobalContext)
+	}
+
+	instance.code = ""
+	instance.request = ""
+	instance.sent = false
+	instance.message = nil
+}
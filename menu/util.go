@@ -3,8 +3,8 @@ package menu
 import (
 	"context"
 	"fmt"
+	"image"
 	"log"
-	"misc"
 	"sh1107"
 	"strings"
 	"time"
@@ -95,12 +95,13 @@ func (m *Menu) RenderStatusBar(batt_flash *bool, data_flash *bool) {
 
 	// === STAGE 1: MODEM ===
 	if m.Modem != nil {
+		state := m.Modem.State()
 
 		// == 1.1: NETWORK GENERATION ===
 
 		netgen_font := m.Display.Use_Font8_Bold()
-		netgen_width, _ := m.Display.GetTextBounds(netgen_font, m.Modem.NetworkGeneration)
-		m.Display.DrawTextAligned(multi_render_width, 21, netgen_font, m.Modem.NetworkGeneration, false, sh1107.AlignRight, sh1107.AlignNone)
+		netgen_width, _ := m.Display.GetTextBounds(netgen_font, state.NetworkGeneration)
+		m.Display.DrawTextAligned(multi_render_width, 21, netgen_font, state.NetworkGeneration, false, sh1107.AlignRight, sh1107.AlignNone)
 
 		// Update the counter
 		multi_render_width += netgen_width + multi_render_padding
@@ -109,12 +110,18 @@ func (m *Menu) RenderStatusBar(batt_flash *bool, data_flash *bool) {
 
 		// Get modem state
 		cell_image := "cell/no_sim"
-		if m.Modem.FlightMode {
+		if state.Fault {
+			// The modem is gone or not answering
+			cell_image = "cell/fault"
+		} else if state.EmergencyCall {
+			// Only emergency calls are getting through
+			cell_image = "cell/sos"
+		} else if state.FlightMode {
 			// Set the icon to airplane mode
 			cell_image = "cell/airplane"
-		} else if m.Modem.SimCardInserted {
+		} else if state.SimCardInserted {
 			// Set the icon to the signal strength
-			cell_image = fmt.Sprintf("cell/%d", m.Modem.SignalStrength)
+			cell_image = fmt.Sprintf("cell/%d", state.SignalStrength)
 		}
 
 		// Get the width of the modem icon state
@@ -126,10 +133,10 @@ func (m *Menu) RenderStatusBar(batt_flash *bool, data_flash *bool) {
 		// 1.3: DATA STATUS
 		data_image := ""
 		data_show := false
-		if !m.Modem.FlightMode {
-			if m.Modem.Connected {
-				if m.Modem.DataEnabled {
-					if m.Modem.DataConnected {
+		if !state.FlightMode {
+			if state.Connected {
+				if state.DataEnabled {
+					if state.DataConnected {
 						data_show = *data_flash
 						data_image = "cell/data_active"
 					} else {
@@ -163,33 +170,38 @@ func (m *Menu) RenderStatusBar(batt_flash *bool, data_flash *bool) {
 
 	// === STAGE 2: WIFI STATUS ===
 
-	network_enabled, err := m.NetworkManager.GetPropertyNetworkingEnabled()
-	if err != nil {
-		log.Println("⚠️ Failed to get network status:", err)
-	}
+	// NetworkManager is unavailable when running headless
+	network_enabled, wifi_enabled := false, false
+	if m.NetworkManager != nil {
+		var err error
+		network_enabled, err = m.NetworkManager.GetPropertyNetworkingEnabled()
+		if err != nil {
+			log.Println("⚠️ Failed to get network status:", err)
+		}
 
-	wifi_enabled, err := m.NetworkManager.GetPropertyWirelessEnabled()
-	if err != nil {
-		log.Println("⚠️ Failed to get WiFi status:", err)
-	}
+		wifi_enabled, err = m.NetworkManager.GetPropertyWirelessEnabled()
+		if err != nil {
+			log.Println("⚠️ Failed to get WiFi status:", err)
+		}
 
-	network_status, err := m.NetworkManager.GetPropertyState()
-	if err != nil {
-		log.Println("⚠️ Failed to get network status:", err)
-	} else {
-		// Check if the network is alive
-		netstate_width := 0
-
-		if network_enabled && wifi_enabled {
-			if network_status == gonetworkmanager.NmStateConnectedLocal ||
-				network_status == gonetworkmanager.NmStateConnectedSite {
-				netstate_width, _ = m.Display.GetImageBounds(m.Sprites["wifi/no_internet"])
-				m.Display.DrawImage(m.Sprites["wifi/no_internet"], multi_render_width, 20)
+		network_status, err := m.NetworkManager.GetPropertyState()
+		if err != nil {
+			log.Println("⚠️ Failed to get network status:", err)
+		} else {
+			// Check if the network is alive
+			netstate_width := 0
+
+			if network_enabled && wifi_enabled {
+				if network_status == gonetworkmanager.NmStateConnectedLocal ||
+					network_status == gonetworkmanager.NmStateConnectedSite {
+					netstate_width, _ = m.Display.GetImageBounds(m.Sprites["wifi/no_internet"])
+					m.Display.DrawImage(m.Sprites["wifi/no_internet"], multi_render_width, 20)
+				}
 			}
-		}
 
-		// Update the counter
-		multi_render_width += netstate_width + multi_render_padding
+			// Update the counter
+			multi_render_width += netstate_width + multi_render_padding
+		}
 	}
 
 	// Show the WiFi status icon
@@ -232,6 +244,34 @@ func (m *Menu) RenderStatusBar(batt_flash *bool, data_flash *bool) {
 		multi_render_width += bluetooth_icon_width + multi_render_padding
 	}
 
+	// === STAGE 4: MESSAGES ===
+
+	if unread, ok := m.Get("UnreadMessages").(int); ok && unread > 0 {
+
+		// Get the width of the envelope icon
+		message_icon_width, _ := m.Display.GetImageBounds(m.Sprites["message/unread"])
+
+		// Draw the icon
+		m.Display.DrawImage(m.Sprites["message/unread"], multi_render_width, 20)
+
+		// Update the counter
+		multi_render_width += message_icon_width + multi_render_padding
+	}
+
+	// === STAGE 5: CALL DIVERT ===
+
+	if active, ok := m.Get("CallDivertActive").(bool); ok && active {
+
+		// Get the width of the divert icon
+		divert_icon_width, _ := m.Display.GetImageBounds(m.Sprites["call/divert"])
+
+		// Draw the icon
+		m.Display.DrawImage(m.Sprites["call/divert"], multi_render_width, 20)
+
+		// Update the counter
+		multi_render_width += divert_icon_width + multi_render_padding
+	}
+
 	// Update to add further stages as necessary
 
 	// At the end, draw the borderline below the status bar
@@ -241,21 +281,33 @@ func (m *Menu) RenderStatusBar(batt_flash *bool, data_flash *bool) {
 	m.Display.Stroke()
 }
 
+// Multi-tap letters for each key, shared by text entry and phonebook search
+var t9KeyMap = map[rune]string{
+	'1': ".,?!-&`:1", '2': "abc2", '3': "def3",
+	'4': "ghi4", '5': "jkl5", '6': "mno6",
+	'7': "pqrs7", '8': "tuv8", '9': "wxyz9",
+	'0': " 0",
+}
+
 func (instance *Menu) EnterText(title string, ctx context.Context) string {
+	return instance.EnterTextWithDefault(title, "", ctx)
+}
+
+// EnterTextWithDefault is EnterText with the field already holding initial,
+// for editing something that was typed before.
+func (instance *Menu) EnterTextWithDefault(title string, initial string, ctx context.Context) string {
+	return instance.EnterTextInMode(title, initial, T9Lowercase, ctx)
+}
+
+// EnterTextInMode is EnterTextWithDefault starting in the given T9 mode, such
+// as T9Numbers for replies that are usually digits.
+func (instance *Menu) EnterTextInMode(title string, initial string, mode int, ctx context.Context) string {
 
 	// Text entry handler
-	var input []rune
-	cursorPos := 0
+	input := []rune(initial)
+	cursorPos := len(input)
 	display := instance.Display
 
-	// Key mapping
-	keyMap := map[rune]string{
-		'1': ".,?!-&`:1", '2': "abc2", '3': "def3",
-		'4': "ghi4", '5': "jkl5", '6': "mno6",
-		'7': "pqrs7", '8': "tuv8", '9': "wxyz9",
-		'0': " 0",
-	}
-
 	// T9 mode mapping
 	t9Map := map[int]string{
 		T9Lowercase: "lowercase",
@@ -263,7 +315,7 @@ func (instance *Menu) EnterText(title string, ctx context.Context) string {
 		T9Numbers:   "numbers",
 	}
 
-	t9Mode := T9Lowercase
+	t9Mode := mode
 	var lastKey rune
 	var lastPressTime time.Time
 	var cycleIndex int
@@ -271,7 +323,7 @@ func (instance *Menu) EnterText(title string, ctx context.Context) string {
 	// Temporarily stop timeouts
 	instance.Timers["oled"].Stop()
 	instance.Timers["keypad"].Stop()
-	misc.KeyLightsOn()
+	instance.Backlight.On()
 	defer instance.Timers["oled"].Restart()
 	defer instance.Timers["keypad"].Restart()
 
@@ -315,7 +367,7 @@ func (instance *Menu) EnterText(title string, ctx context.Context) string {
 			if !evt.State {
 				continue
 			}
-			misc.KeyLightsOn()
+			instance.Backlight.On()
 			go instance.PlayKey()
 
 			now := time.Now()
@@ -413,7 +465,7 @@ func (instance *Menu) EnterText(title string, ctx context.Context) string {
 				render()
 
 			default:
-				chars, ok := keyMap[evt.Key]
+				chars, ok := t9KeyMap[evt.Key]
 				if ok {
 					// Adjust chars based on mode
 					switch t9Mode {
@@ -448,3 +500,355 @@ func (instance *Menu) EnterText(title string, ctx context.Context) string {
 		}
 	}
 }
+
+// Splits text into lines that fit within width pixels, breaking between words
+// where possible. Newlines in the text are kept.
+func wrapText(display *sh1107.SH1107, font map[rune]image.Image, text string, width int) []string {
+	var lines []string
+	for paragraph := range strings.SplitSeq(text, "\n") {
+		line := ""
+		for _, word := range strings.Fields(paragraph) {
+			candidate := word
+			if line != "" {
+				candidate = line + " " + word
+			}
+			if w, _ := display.GetTextBounds(font, candidate); w <= width {
+				line = candidate
+				continue
+			}
+			if line != "" {
+				lines = append(lines, line)
+			}
+
+			// Words too long for a line of their own get split up
+			line = ""
+			for _, r := range word {
+				if w, _ := display.GetTextBounds(font, line+string(r)); w > width && line != "" {
+					lines = append(lines, line)
+					line = ""
+				}
+				line += string(r)
+			}
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// EnterPhoneNumber shows a number entry screen and returns what was typed,
+// or an empty string if it was cancelled. Pressing * at the start of the
+// number enters a +.
+func (instance *Menu) EnterPhoneNumber(title string, initial string, ctx context.Context) string {
+	input := []rune(initial)
+	display := instance.Display
+
+	// Temporarily stop timeouts
+	instance.Timers["oled"].Stop()
+	instance.Timers["keypad"].Stop()
+	instance.Backlight.On()
+	defer instance.Timers["oled"].Restart()
+	defer instance.Timers["keypad"].Restart()
+
+	render := func() {
+		display.Clear(sh1107.Black)
+
+		font := display.Use_Font8_Normal()
+		display.DrawTextAligned(0, 20, font, title, false, sh1107.AlignRight, sh1107.AlignNone)
+
+		display.SetColor(sh1107.White)
+		display.DrawLine(0, 33, 127, 33)
+		display.Stroke()
+
+		// Keep the end of long numbers in view
+		font = display.Use_Font16()
+		shown := input
+		for len(shown) > 0 {
+			if w, _ := display.GetTextBounds(font, string(shown)); w <= 124 {
+				break
+			}
+			shown = shown[1:]
+		}
+		display.DrawTextAligned(126, 50, font, string(shown), false, sh1107.AlignLeft, sh1107.AlignNone)
+
+		font = display.Use_Font8_Bold()
+		display.DrawTextAligned(64, 105, font, "OK", false, sh1107.AlignCenter, sh1107.AlignNone)
+		display.Render()
+	}
+
+	render()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ""
+		case evt := <-instance.KeypadEvents:
+			if !evt.State {
+				continue
+			}
+			instance.Backlight.On()
+			go instance.PlayKey()
+
+			switch evt.Key {
+			case 'S':
+				if len(input) > 0 {
+					return string(input)
+				}
+			case 'C':
+				if len(input) == 0 {
+					return ""
+				}
+				input = input[:len(input)-1]
+				render()
+			case 'P':
+				go instance.Push("power")
+				return ""
+			case 'U', 'D':
+			case '*':
+				if len(input) == 0 {
+					input = append(input, '+')
+				} else {
+					input = append(input, '*')
+				}
+				render()
+			default:
+				input = append(input, evt.Key)
+				render()
+			}
+		}
+	}
+}
+
+// EnterCode shows a masked entry screen for a SIM code and returns it, or an
+// empty string if it was cancelled. Codes are 4 to 8 digits, and the hint
+// (such as the tries left) is shown below the code. While the SIM is locked,
+// emergency numbers can be typed in and called too, and C offers SOS.
+func (instance *Menu) EnterCode(title string, hint string, ctx context.Context) string {
+	var input []rune
+	display := instance.Display
+	sos := instance.Modem != nil && instance.Modem.State().SIMLock != ""
+
+	// Opens the emergency dialer, which pauses whatever ctx belongs to
+	emergency := func(args ...any) string {
+		go instance.PushWithArgs("emergency", args...)
+		<-ctx.Done()
+		return ""
+	}
+
+	// Temporarily stop timeouts
+	instance.Timers["oled"].Stop()
+	instance.Timers["keypad"].Stop()
+	instance.Backlight.On()
+	defer instance.Timers["oled"].Restart()
+	defer instance.Timers["keypad"].Restart()
+
+	render := func() {
+		display.Clear(sh1107.Black)
+
+		font := display.Use_Font8_Normal()
+		display.DrawTextAligned(0, 20, font, title, false, sh1107.AlignRight, sh1107.AlignNone)
+
+		display.SetColor(sh1107.White)
+		display.DrawLine(0, 33, 127, 33)
+		display.Stroke()
+
+		display.DrawTextAligned(64, 75, font, hint, false, sh1107.AlignCenter, sh1107.AlignNone)
+
+		// Emergency numbers aren't secret
+		shown := strings.Repeat("*", len(input))
+		if sos && instance.IsEmergencyNumber(string(input)) {
+			shown = string(input)
+		}
+		font = display.Use_Font16()
+		display.DrawTextAligned(64, 50, font, shown, false, sh1107.AlignCenter, sh1107.AlignNone)
+
+		font = display.Use_Font8_Bold()
+		if sos && instance.IsEmergencyNumber(string(input)) {
+			display.DrawTextAligned(64, 105, font, "Call", false, sh1107.AlignCenter, sh1107.AlignNone)
+		} else if len(input) >= 4 {
+			display.DrawTextAligned(64, 105, font, "OK", false, sh1107.AlignCenter, sh1107.AlignNone)
+		}
+		if sos && len(input) == 0 {
+			display.DrawTextAligned(127, 105, font, "SOS", false, sh1107.AlignLeft, sh1107.AlignNone)
+		}
+		display.Render()
+	}
+
+	render()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ""
+		case evt := <-instance.KeypadEvents:
+			if !evt.State {
+				continue
+			}
+			instance.Backlight.On()
+			go instance.PlayKey()
+
+			switch evt.Key {
+			case 'S':
+				if sos && instance.IsEmergencyNumber(string(input)) {
+					return emergency(string(input))
+				}
+				if len(input) >= 4 {
+					return string(input)
+				}
+			case 'C':
+				if len(input) == 0 {
+					if sos {
+						return emergency()
+					}
+					return ""
+				}
+				input = input[:len(input)-1]
+				render()
+			case 'P':
+				go instance.Push("power")
+				return ""
+			case 'U', 'D', '*', '#':
+			default:
+				if len(input) < 8 {
+					input = append(input, evt.Key)
+					render()
+				}
+			}
+		}
+	}
+}
+
+// SIMAvailable checks there's an unlocked SIM to talk to, and shows why not
+// if there isn't.
+func (m *Menu) SIMAvailable() bool {
+	switch {
+	case m.Modem == nil:
+		m.RenderAlert("prohibited", []string{"No", "service"})
+	case m.Modem.State().Fault:
+		m.RenderAlert("prohibited", []string{"No", "service"})
+	case m.Modem.State().SIMLock != "":
+		m.RenderAlert("prohibited", []string{"SIM card", "locked"})
+	case !m.Modem.State().SimCardInserted:
+		m.RenderAlert("prohibited", []string{"Insert a", "SIM card"})
+	default:
+		return true
+	}
+	go m.PlayAlert()
+	time.Sleep(2 * time.Second)
+	return false
+}
+
+// NetworkAvailable checks there's a network to send a supplementary service
+// request to, like a divert or a barring, and shows why not if there isn't.
+func (m *Menu) NetworkAvailable() bool {
+	if !m.SIMAvailable() {
+		return false
+	}
+	if !m.Modem.State().FlightMode {
+		return true
+	}
+	m.RenderAlert("prohibited", []string{"Airplane", "mode"})
+	go m.PlayAlert()
+	time.Sleep(2 * time.Second)
+	return false
+}
+
+// formatDuration formats a call length as hh:mm:ss.
+func formatDuration(d time.Duration) string {
+	return fmt.Sprintf("%02d:%02d:%02d", int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60)
+}
+
+// IsEmergencyNumber checks whether a number reaches the emergency services.
+// These can be dialed whatever DialBlockedReason says.
+func (m *Menu) IsEmergencyNumber(number string) bool {
+	return m.Modem != nil && m.Modem.IsEmergencyNumber(number)
+}
+
+// Dial calls a number, going through DialEmergency for emergency numbers so
+// they get through without a SIM, service or radio. It's the number Redial
+// tries again.
+func (m *Menu) Dial(number string) error {
+	m.Set("LastDialed", number)
+	m.Set("RedialAttempt", 0)
+	return m.dial(number)
+}
+
+// Places a call without starting the redial count over. The call outlives
+// the screen it was dialed from, so it's only cancelled on shutdown.
+func (m *Menu) dial(number string) error {
+	if m.IsEmergencyNumber(number) {
+		return m.Modem.DialEmergency(m.GlobalContext, number)
+	}
+	return m.Modem.Dial(m.GlobalContext, number)
+}
+
+// CallBlockedReason returns the alert to show if a call can't be placed right
+// now, or nil if the modem is ready to dial.
+func (m *Menu) CallBlockedReason() []string {
+	if m.Modem == nil {
+		return []string{"No", "service!"}
+	}
+
+	switch state := m.Modem.State(); {
+	case state.Fault:
+		return []string{"No", "service!"}
+	case state.FlightMode:
+		return []string{"Airplane", "mode", "enabled."}
+	case !state.SimCardInserted:
+		return []string{"Insert a", "SIM card", "to continue."}
+	case !state.Connected:
+		return []string{"No", "service!"}
+	}
+	return nil
+}
+
+// DialBlockedReason returns the alert to show if a number can't be called
+// right now, or nil if it can. On top of CallBlockedReason, fixed dialing
+// only lets the numbers on the SIM's list through. Emergency numbers always
+// go through.
+func (m *Menu) DialBlockedReason(number string) []string {
+	if m.IsEmergencyNumber(number) {
+		return nil
+	}
+	if reason := m.CallBlockedReason(); reason != nil {
+		return reason
+	}
+	if !m.Modem.FixedDialingAllows(number) {
+		return []string{"Number not", "on fixed", "dialing list."}
+	}
+	return nil
+}
+
+// Confirm asks a yes/no question and returns true if it was accepted with OK.
+func (m *Menu) Confirm(question []string, ctx context.Context) bool {
+	m.RenderAlert("info", question)
+	font := m.Display.Use_Font8_Bold()
+	m.Display.DrawTextAligned(64, 105, font, "OK", false, sh1107.AlignCenter, sh1107.AlignNone)
+	m.Display.Render()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+		case evt := <-m.KeypadEvents:
+			if !evt.State {
+				continue
+			}
+
+			m.Timers["keypad"].Reset()
+			m.Timers["oled"].Reset()
+			m.Display.On()
+			m.Backlight.On()
+			go m.PlayKey()
+
+			switch evt.Key {
+			case 'S':
+				return true
+			case 'C':
+				return false
+			case 'P':
+				go m.Push("power")
+				return false
+			}
+		}
+	}
+}
@@ -2,16 +2,19 @@ package misc
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"image"
 	"log"
 	"math"
+	"net"
 	"net/http"
 	"os"
 	"os/exec"
 	"regexp"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"sh1107"
@@ -52,34 +55,52 @@ func SoftReboot() {
 	}
 }
 
-func KeyLightsOn() {
+// Backlight switches the keypad lights. GPIOBacklight drives the real LEDs;
+// MemoryBacklight only remembers the last state.
+type Backlight interface {
+	On()
+	Off()
+}
+
+type GPIOBacklight struct {
+	pin gpio.PinIO
+}
+
+func NewGPIOBacklight() (*GPIOBacklight, error) {
 	if _, err := host.Init(); err != nil {
-		panic(err)
+		return nil, err
 	}
 	p := gpioreg.ByName("GPIO23")
 	if p == nil {
-		log.Fatal("Failed to find GPIO23 (Keypad light control)")
+		return nil, errors.New("failed to find GPIO23 (Keypad light control)")
 	}
+	return &GPIOBacklight{pin: p}, nil
+}
 
-	if err := p.Out(gpio.High); err != nil {
-		log.Fatal(err)
+func (b *GPIOBacklight) On() {
+	if err := b.pin.Out(gpio.High); err != nil {
+		log.Println("⚠️ Failed to turn on key lights:", err)
 	}
 }
 
-func KeyLightsOff() {
-	if _, err := host.Init(); err != nil {
-		panic(err)
-	}
-	p := gpioreg.ByName("GPIO23")
-	if p == nil {
-		log.Fatal("Failed to find GPIO23 (Keypad light control)")
+func (b *GPIOBacklight) Off() {
+	if err := b.pin.Out(gpio.Low); err != nil {
+		log.Println("⚠️ Failed to turn off key lights:", err)
 	}
+}
 
-	if err := p.Out(gpio.Low); err != nil {
-		log.Fatal(err)
-	}
+type MemoryBacklight struct {
+	lit atomic.Bool
 }
 
+func NewMemoryBacklight() *MemoryBacklight {
+	return &MemoryBacklight{}
+}
+
+func (b *MemoryBacklight) On()        { b.lit.Store(true) }
+func (b *MemoryBacklight) Off()       { b.lit.Store(false) }
+func (b *MemoryBacklight) IsOn() bool { return b.lit.Load() }
+
 func SleepWithContext(duration time.Duration, ctx context.Context) {
 	timer := time.NewTimer(duration)
 	select {
@@ -90,7 +111,7 @@ func SleepWithContext(duration time.Duration, ctx context.Context) {
 	}
 }
 
-func PlayLowBattery(player *tones.Tones, ctx context.Context) {
+func PlayLowBattery(player tones.TonePlayer, ctx context.Context) {
 	notes := []tones.Note{
 		{Key: 103, Duration: 100 * time.Millisecond, Divider: 5}, // G7
 		{Key: 91, Duration: 100 * time.Millisecond, Divider: 5},  // G6
@@ -100,7 +121,7 @@ func PlayLowBattery(player *tones.Tones, ctx context.Context) {
 	player.Play(ctx, notes)
 }
 
-func PlayDeadBattery(player *tones.Tones, ctx context.Context) {
+func PlayDeadBattery(player tones.TonePlayer, ctx context.Context) {
 	notes := []tones.Note{
 		{Key: 103, Duration: 100 * time.Millisecond, Divider: 5}, // G7
 		{Key: 91, Duration: 100 * time.Millisecond, Divider: 5},  // G6
@@ -116,7 +137,7 @@ func PlayDeadBattery(player *tones.Tones, ctx context.Context) {
 	player.Play(ctx, notes)
 }
 
-func PlayRingtone(player *tones.Tones, ctx context.Context) {
+func PlayRingtone(player tones.TonePlayer, ctx context.Context) {
 	notes := []tones.Note{
 		{Key: 88, Duration: 150 * time.Millisecond, Divider: 10}, // E7
 		{Key: 86, Duration: 150 * time.Millisecond, Divider: 10}, // D#7 / Eb7
@@ -151,7 +172,51 @@ func PlayRingtone(player *tones.Tones, ctx context.Context) {
 	player.Play(ctx, notes)
 }
 
-func VibrateAlert(player *tones.Tones, ctx context.Context) {
+// Ringtones that can be assigned to a contact, in the order they're offered
+var RingtoneNames = []string{"Rakian", "Classic", "Ascending", "Beeps"}
+
+// PlayRingtoneNamed plays one of RingtoneNames, or the default ringtone if
+// the name isn't known.
+func PlayRingtoneNamed(player tones.TonePlayer, ctx context.Context, name string) {
+	switch name {
+	case "Classic":
+		notes := []tones.Note{}
+		for range 2 {
+			for range 10 {
+				notes = append(notes,
+					tones.Note{Key: 86, Duration: 25 * time.Millisecond, Divider: 5}, // D7
+					tones.Note{Key: 82, Duration: 25 * time.Millisecond, Divider: 5}, // A#6 / Bb6
+				)
+			}
+			notes = append(notes, tones.Note{Key: 0, Duration: 200 * time.Millisecond, Divider: 1}) // NONE
+		}
+		notes = append(notes, tones.Note{Key: 0, Duration: 2 * time.Second, Divider: 1}) // NONE
+		player.Play(ctx, notes)
+
+	case "Ascending":
+		notes := []tones.Note{
+			{Key: 76, Duration: 150 * time.Millisecond, Divider: 5}, // E6
+			{Key: 80, Duration: 150 * time.Millisecond, Divider: 5}, // G#6 / Ab6
+			{Key: 83, Duration: 150 * time.Millisecond, Divider: 5}, // B6
+			{Key: 88, Duration: 300 * time.Millisecond, Divider: 5}, // E7
+			{Key: 0, Duration: 100 * time.Millisecond, Divider: 1},  // NONE
+			{Key: 76, Duration: 150 * time.Millisecond, Divider: 5}, // E6
+			{Key: 80, Duration: 150 * time.Millisecond, Divider: 5}, // G#6 / Ab6
+			{Key: 83, Duration: 150 * time.Millisecond, Divider: 5}, // B6
+			{Key: 88, Duration: 300 * time.Millisecond, Divider: 5}, // E7
+			{Key: 0, Duration: 2 * time.Second, Divider: 1},         // NONE
+		}
+		player.Play(ctx, notes)
+
+	case "Beeps":
+		PlayBeep(player, ctx)
+
+	default:
+		PlayRingtone(player, ctx)
+	}
+}
+
+func VibrateAlert(player tones.TonePlayer, ctx context.Context) {
 	states := []tones.Vibrate{
 		{State: true, Duration: 300 * time.Millisecond},
 		{State: false, Duration: 100 * time.Millisecond},
@@ -161,7 +226,7 @@ func VibrateAlert(player *tones.Tones, ctx context.Context) {
 	player.Vibrate(ctx, states)
 }
 
-func StartVibrate(player *tones.Tones, ctx context.Context) {
+func StartVibrate(player tones.TonePlayer, ctx context.Context) {
 	var states []tones.Vibrate
 	for range 3 {
 		for _, elem := range []tones.Vibrate{
@@ -178,7 +243,7 @@ func StartVibrate(player *tones.Tones, ctx context.Context) {
 	player.Vibrate(ctx, states)
 }
 
-func PlayBeep(player *tones.Tones, ctx context.Context) {
+func PlayBeep(player tones.TonePlayer, ctx context.Context) {
 	notes := []tones.Note{
 		{Key: 88, Duration: 150 * time.Millisecond, Divider: 2}, // E7
 		{Key: 0, Duration: 20 * time.Millisecond, Divider: 1},   // NONE
@@ -188,7 +253,18 @@ func PlayBeep(player *tones.Tones, ctx context.Context) {
 	player.Play(ctx, notes)
 }
 
-func PlayBoot(player *tones.Tones, ctx context.Context) {
+func PlayMessage(player tones.TonePlayer, ctx context.Context) {
+	notes := []tones.Note{
+		{Key: 88, Duration: 100 * time.Millisecond, Divider: 5}, // E7
+		{Key: 0, Duration: 50 * time.Millisecond, Divider: 1},   // NONE
+		{Key: 88, Duration: 100 * time.Millisecond, Divider: 5}, // E7
+		{Key: 0, Duration: 50 * time.Millisecond, Divider: 1},   // NONE
+		{Key: 93, Duration: 300 * time.Millisecond, Divider: 5}, // A7
+	}
+	player.Play(ctx, notes)
+}
+
+func PlayBoot(player tones.TonePlayer, ctx context.Context) {
 	offset := 9
 	notes := []tones.Note{
 		{Key: 83 + offset, Duration: 300 * time.Millisecond, Divider: 10},  // C#7 / Db7
@@ -306,6 +382,26 @@ func GetWiFiStatus() (connected bool, ssid string, signalScaled int, ipaddress s
 	return connected, ssid, signalScaled, ipaddress
 }
 
+// GetCellularStatus reports whether the modem's data interface is up with an
+// IPv4 address, and what that address is.
+func GetCellularStatus(iface string) (connected bool, ipaddress string) {
+	link, err := net.InterfaceByName(iface)
+	if err != nil || link.Flags&net.FlagUp == 0 {
+		return false, ""
+	}
+
+	addrs, err := link.Addrs()
+	if err != nil {
+		return false, ""
+	}
+	for _, addr := range addrs {
+		if ipnet, ok := addr.(*net.IPNet); ok && ipnet.IP.To4() != nil {
+			return true, ipnet.IP.String()
+		}
+	}
+	return false, ""
+}
+
 func GetModemStatusMMCLI() (state string, operator string, signal string) {
 	// Use mmcli to get modem status in key-value format
 	out, err := exec.Command("mmcli", "-m", "any", "-K").Output()
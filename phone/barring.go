@@ -0,0 +1,226 @@
+package phone
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Calls the network can bar, as used by AT+CLCK with the barring password
+const (
+	BarAllOutgoing           = "AO"
+	BarOutgoingInternational = "OI"
+	BarAllIncoming           = "AI"
+	BarIncomingRoaming       = "IR"
+	BarAll                   = "AB" // Every barring, only for cancelling and changing the password
+)
+
+// FacilityFixedDialing turns fixed dialing on and off, which needs the PIN2
+const FacilityFixedDialing = "FD"
+
+// Only voice calls are barred, like diverts
+const barringVoiceClass = 1
+
+// CUGPreferred uses the closed user group the subscription names, rather than
+// one of the indexes 0 to 9
+const CUGPreferred = 10
+
+// ErrCallBarred is returned by Dial when the network or the SIM won't let
+// the call through
+var ErrCallBarred = errors.New("call barred")
+
+// CUGSetting is the closed user group calls are made in, see SetCUG
+type CUGSetting struct {
+	Enabled bool
+	Index   int // 0 to 9, or CUGPreferred
+}
+
+var (
+	clckClassRegex = regexp.MustCompile(`\+CLCK:\s*(\d)(?:,(\d+))?`)
+	ccugRegex      = regexp.MustCompile(`\+CCUG:\s*(\d)(?:,(\d+))?`)
+	ceerRegex      = regexp.MustCompile(`\+CEER:\s*(.*)`)
+)
+
+// QueryBarring asks the network whether voice calls are barred for a
+// facility, like BarAllOutgoing.
+func (m *Modem) QueryBarring(ctx context.Context, facility string) (bool, error) {
+	resp, err := m.Exec(ctx, Command{Text: fmt.Sprintf(`AT+CLCK="%s",2`, facility)})
+	if err != nil {
+		return false, err
+	}
+
+	// There's a line per class, or a single inactive one for all of them
+	for _, matches := range clckClassRegex.FindAllStringSubmatch(resp.String(), -1) {
+		if matches[1] != "1" {
+			continue
+		}
+		class, err := strconv.Atoi(matches[2])
+		if err != nil || class&barringVoiceClass != 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// SetBarring bars voice calls for a facility, or stops barring them, which
+// needs the barring password. BarAll can only be cancelled. A wrong password
+// comes back as a *CMEError with CMEIncorrectPassword.
+func (m *Modem) SetBarring(ctx context.Context, facility string, enabled bool, password string) error {
+	mode := 0
+	if enabled {
+		mode = 1
+	}
+	return m.sendCode(ctx, fmt.Sprintf(`AT+CLCK="%s",%d,"%s",%d`, facility, mode, password, barringVoiceClass))
+}
+
+// FixedDialing returns whether the SIM only lets the numbers on its fixed
+// dialing list be called.
+func (m *Modem) FixedDialing(ctx context.Context) (bool, error) {
+	resp, err := m.Exec(ctx, Command{Text: fmt.Sprintf(`AT+CLCK="%s",2`, FacilityFixedDialing)})
+	if err != nil {
+		return false, err
+	}
+	matches := clckRegex.FindStringSubmatch(resp.String())
+	if matches == nil {
+		return false, fmt.Errorf("unexpected fixed dialing status: %s", resp)
+	}
+	return matches[1] == "1", nil
+}
+
+// SetFixedDialing turns fixed dialing on or off, which needs the PIN2.
+func (m *Modem) SetFixedDialing(ctx context.Context, enabled bool, pin2 string) error {
+	mode := 0
+	if enabled {
+		mode = 1
+	}
+	err := m.sendCode(ctx, fmt.Sprintf(`AT+CLCK="%s",%d,"%s"`, FacilityFixedDialing, mode, pin2))
+	m.CheckSIM()
+	m.loadFixedDialing()
+	return err
+}
+
+// WriteFixedNumber stores a number on the fixed dialing list, in the slot at
+// index or the first free one if it's 0. The list can only be changed with
+// the PIN2.
+func (m *Modem) WriteFixedNumber(ctx context.Context, index int, number, name, pin2 string) error {
+	err := m.writePhonebook(ctx, FacilityFixedDialing, pin2, index, number, name)
+	m.CheckSIM()
+	m.loadFixedDialing()
+	return err
+}
+
+// DeleteFixedNumber takes the entry at index off the fixed dialing list,
+// which needs the PIN2.
+func (m *Modem) DeleteFixedNumber(ctx context.Context, index int, pin2 string) error {
+	if _, _, _, err := m.selectPhonebook(ctx, FacilityFixedDialing, pin2); err != nil {
+		m.CheckSIM()
+		return err
+	}
+	err := m.sendCode(ctx, fmt.Sprintf("AT+CPBW=%d", index))
+	m.loadFixedDialing()
+	return err
+}
+
+// Reads whether fixed dialing is on and the numbers on the list, so
+// FixedDialingAllows can check numbers without asking the SIM
+func (m *Modem) loadFixedDialing() {
+	enabled, err := m.FixedDialing(context.Background())
+	if err != nil {
+		log.Println("⚠️ Failed to check fixed dialing:", err)
+	}
+
+	var numbers []string
+	if enabled {
+		contacts, err := m.ReadSIMPhonebook(context.Background(), FacilityFixedDialing)
+		if err != nil {
+			log.Println("⚠️ Failed to read fixed dialing list:", err)
+		}
+		for _, c := range contacts {
+			numbers = append(numbers, c.Number)
+		}
+	}
+
+	m.mu.Lock()
+	m.fixedNumbers = numbers
+	m.mu.Unlock()
+	m.updateState(func(s *State) { s.FixedDialing = enabled })
+}
+
+// FixedDialingAllows checks a number against the fixed dialing list, which
+// allows anything when fixed dialing is off. An entry also allows every
+// number it's the start of, so an area code can be let through.
+func (m *Modem) FixedDialingAllows(number string) bool {
+	if !m.State().FixedDialing {
+		return true
+	}
+
+	number = strings.ReplaceAll(number, " ", "")
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, entry := range m.fixedNumbers {
+		if entry != "" && strings.HasPrefix(number, entry) {
+			return true
+		}
+	}
+	return false
+}
+
+// Asks the modem why the last call ended or never got going
+func (m *Modem) callFailureCause(ctx context.Context) string {
+	resp, err := m.Exec(ctx, Command{Text: "AT+CEER"})
+	if err != nil {
+		return ""
+	}
+	for _, line := range resp.Lines {
+		if matches := ceerRegex.FindStringSubmatch(line); matches != nil {
+			return strings.TrimSpace(matches[1])
+		}
+	}
+	return ""
+}
+
+// Whether a failed dial was refused by barring or fixed dialing, rather than
+// for any other reason
+func (m *Modem) dialBarred(ctx context.Context, err error) bool {
+	if errors.Is(err, ErrNoModem) || errors.Is(err, ErrTimeout) {
+		return false
+	}
+	var cme *CMEError
+	if errors.As(err, &cme) && strings.Contains(strings.ToLower(cme.Message), "barr") {
+		return true
+	}
+	return strings.Contains(strings.ToLower(m.callFailureCause(ctx)), "barr")
+}
+
+// CUG asks the modem which closed user group calls are made in.
+func (m *Modem) CUG(ctx context.Context) (*CUGSetting, error) {
+	resp, err := m.Exec(ctx, Command{Text: "AT+CCUG?"})
+	if err != nil {
+		return nil, err
+	}
+	for _, line := range resp.Lines {
+		if matches := ccugRegex.FindStringSubmatch(line); matches != nil {
+			setting := &CUGSetting{Enabled: matches[1] == "1", Index: CUGPreferred}
+			if matches[2] != "" {
+				setting.Index, _ = strconv.Atoi(matches[2])
+			}
+			return setting, nil
+		}
+	}
+	return nil, fmt.Errorf("unexpected closed user group: %s", resp)
+}
+
+// SetCUG makes calls in a closed user group, or stops using one. Index is 0
+// to 9, or CUGPreferred for the one the subscription names.
+func (m *Modem) SetCUG(ctx context.Context, enabled bool, index int) error {
+	cmd := "AT+CCUG=0"
+	if enabled {
+		cmd = fmt.Sprintf("AT+CCUG=1,%d,0", index)
+	}
+	_, err := m.Exec(ctx, Command{Text: cmd})
+	return err
+}
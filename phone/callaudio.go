@@ -0,0 +1,72 @@
+package phone
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+)
+
+// MaxCallVolume is the loudest AT+CLVL level, 0 being the quietest
+const MaxCallVolume = 5
+
+// Where AT+CSDVC sends call audio
+const (
+	audioHandset      = 1
+	audioSpeakerphone = 3
+)
+
+var clvlRegex = regexp.MustCompile(`\+CLVL:\s*(\d+)`)
+
+// CallVolume asks the modem how loud calls are, from 0 to MaxCallVolume.
+func (m *Modem) CallVolume(ctx context.Context) (int, error) {
+	resp, err := m.Exec(ctx, Command{Text: "AT+CLVL?"})
+	if err != nil {
+		return 0, err
+	}
+	for _, line := range resp.Lines {
+		if matches := clvlRegex.FindStringSubmatch(line); matches != nil {
+			return strconv.Atoi(matches[1])
+		}
+	}
+	return 0, fmt.Errorf("unexpected call volume: %s", resp)
+}
+
+// SetCallVolume sets how loud calls are, from 0 to MaxCallVolume.
+func (m *Modem) SetCallVolume(ctx context.Context, level int) error {
+	level = min(max(level, 0), MaxCallVolume)
+	_, err := m.Exec(ctx, Command{Text: fmt.Sprintf("AT+CLVL=%d", level)})
+	return err
+}
+
+// SetLoudspeaker plays calls through the loudspeaker, or back through the
+// earpiece.
+func (m *Modem) SetLoudspeaker(ctx context.Context, on bool) error {
+	device := audioHandset
+	if on {
+		device = audioSpeakerphone
+	}
+	if _, err := m.Exec(ctx, Command{Text: fmt.Sprintf("AT+CSDVC=%d", device)}); err != nil {
+		return err
+	}
+	m.updateState(func(s *State) { s.Loudspeaker = on })
+	return nil
+}
+
+// ResetCallAudio unmutes the microphone and goes back to the earpiece, so
+// the next call doesn't start muted or on the loudspeaker. Called once the
+// calls are over.
+func (m *Modem) ResetCallAudio() {
+	state := m.State()
+	if state.MicMuted {
+		if err := m.MuteMic(false); err != nil {
+			log.Println("⚠️ Failed to unmute microphone:", err)
+		}
+	}
+	if state.Loudspeaker {
+		if err := m.SetLoudspeaker(context.Background(), false); err != nil {
+			log.Println("⚠️ Failed to switch to earpiece:", err)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package phone
+
+import (
+	"log"
+	"time"
+)
+
+// CallRecord is a finished call, as kept in the call register
+type CallRecord struct {
+	Number    string
+	Inbound   bool
+	Answered  bool
+	HungUp    bool          // Ended from this phone, rather than by the other end
+	StartTime time.Time     // When the call started ringing or dialing
+	Duration  time.Duration // Time spent connected, zero if never answered
+}
+
+// Missed reports whether this was an incoming call nobody picked up, rather
+// than one rejected from this phone
+func (r *CallRecord) Missed() bool {
+	return r.Inbound && !r.Answered && !r.HungUp
+}
+
+// Failed reports whether this was an outgoing call that never got through,
+// like one that was busy, rather than one hung up before it was answered
+func (r *CallRecord) Failed() bool {
+	return !r.Inbound && !r.Answered && !r.HungUp
+}
+
+type trackedCall struct {
+	record     CallRecord
+	answeredAt time.Time
+}
+
+// Follows a call through the +CLCC statuses and publishes it as CallLogged once
+// it's disconnected
+func (m *Modem) trackCall(index int, inbound bool, status int, number string) {
+	m.callLogMu.Lock()
+	defer m.callLogMu.Unlock()
+
+	call, ok := m.calls[index]
+	if !ok || call.record.Number != number || call.record.Inbound != inbound {
+		if ok {
+			// The index was reused before we saw the old call end
+			m.finishCall(index)
+		}
+		if status == 6 {
+			return
+		}
+		call = &trackedCall{record: CallRecord{
+			Number:    number,
+			Inbound:   inbound,
+			StartTime: time.Now(),
+		}}
+		m.calls[index] = call
+	}
+
+	switch status {
+	case 0: // active
+		if !call.record.Answered {
+			call.record.Answered = true
+			call.answeredAt = time.Now()
+		}
+	case 6: // disconnected
+		m.finishCall(index)
+	}
+}
+
+// Marks a call as hung up from here, or every call for index -1
+func (m *Modem) hungUp(index int) {
+	m.callLogMu.Lock()
+	defer m.callLogMu.Unlock()
+
+	for i, call := range m.calls {
+		if index < 0 || i == index {
+			call.record.HungUp = true
+		}
+	}
+}
+
+// Ends every call still being tracked, for when the modem reports NO CARRIER
+// without a final +CLCC
+func (m *Modem) finishAllCalls() {
+	m.callLogMu.Lock()
+	defer m.callLogMu.Unlock()
+
+	for index := range m.calls {
+		m.finishCall(index)
+	}
+}
+
+// Must be called with callLogMu held
+func (m *Modem) finishCall(index int) {
+	call, ok := m.calls[index]
+	if !ok {
+		return
+	}
+	delete(m.calls, index)
+
+	record := call.record
+	if record.Answered {
+		record.Duration = time.Since(call.answeredAt).Round(time.Second)
+	}
+
+	if m.DebugMode {
+		log.Printf("☎️ Call with %s ended (inbound: %t, answered: %t, %s)", record.Number, record.Inbound, record.Answered, record.Duration)
+	}
+
+	m.publish(CallEvent{Kind: CallLogged, Record: &record})
+	if record.Missed() {
+		m.publish(CallEvent{Kind: CallMissed, Record: &record})
+	}
+}
@@ -0,0 +1,298 @@
+package phone
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/warthog618/sms/encoding/gsm7"
+	"github.com/warthog618/sms/encoding/ucs2"
+)
+
+// Cell broadcast channel networks send the area name on
+const areaInfoChannel = 50
+
+// CellInfo is the cell the modem is camped on and the ones it can hear around
+// it, see Modem.CellInfo. Signal levels are 0 when the modem didn't report them.
+type CellInfo struct {
+	System     string // LTE, WCDMA, GSM or NO SERVICE
+	MCC        string
+	MNC        string
+	AreaCode   int    // TAC on LTE, LAC otherwise
+	CellID     int64  // Cell identity, with the eNodeB on LTE
+	PhysicalID int    // PCI on LTE, PSC on WCDMA
+	Band       string // Like EUTRAN-BAND4 or EGSM 900
+	Channel    int    // EARFCN on LTE, UARFCN on WCDMA and ARFCN on GSM
+	RSRP       float64
+	RSRQ       float64
+	SINR       float64 // Only meaningful when RSRP is set
+	RSCP       float64
+	EcIo       float64
+	RxLev      float64
+	Neighbours []NeighbourCell
+}
+
+// NeighbourCell is a cell the modem can hear besides the serving one
+type NeighbourCell struct {
+	Channel  int
+	AreaCode int
+	CellID   int64
+	RxLev    float64
+}
+
+var (
+	cesqRegex   = regexp.MustCompile(`\+CESQ:\s*(\d+),(\d+),(\d+),(\d+),(\d+),(\d+)`)
+	ccinfoRegex = regexp.MustCompile(`^\+CCINFO:\s*\[NCELL\d+\],(.*)$`)
+	cbmRegex    = regexp.MustCompile(`^\+CBM:\s*\d+$`)
+)
+
+// Reads a number the modem gave in hex with 0x, or in decimal without
+func parseCellNumber(s string) int64 {
+	s = strings.Trim(strings.TrimSpace(s), `"`)
+	var n int64
+	var err error
+	if hexPart, ok := strings.CutPrefix(strings.ToLower(s), "0x"); ok {
+		n, err = strconv.ParseInt(hexPart, 16, 64)
+	} else {
+		n, err = strconv.ParseInt(s, 10, 64)
+	}
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+// Fills in the serving cell from AT+CPSI?, whose fields depend on the system:
+//
+//	LTE,Online,MCC-MNC,TAC,SCellID,PCellID,Band,EARFCN,DLBW,ULBW,RSRQ,RSRP,RSSI,RSSNR
+//	WCDMA,Online,MCC-MNC,LAC,CellID,Band,PSC,UARFCN,SSC,EcIo,RSCP,Qual,RxLev,TXPWR
+//	GSM,Online,MCC-MNC,LAC,CellID,ARFCN Band,RxLev,TrackLOAdjust,C1-C2
+func parseCPSI(line string, info *CellInfo) {
+	fields := strings.Split(strings.TrimSpace(strings.TrimPrefix(line, "+CPSI:")), ",")
+	info.System = fields[0]
+	if len(fields) < 5 {
+		return
+	}
+
+	info.MCC, info.MNC, _ = strings.Cut(fields[2], "-")
+	info.AreaCode = int(parseCellNumber(fields[3]))
+	info.CellID = parseCellNumber(fields[4])
+
+	field := func(i int) float64 {
+		if i >= len(fields) {
+			return 0
+		}
+		f, _ := strconv.ParseFloat(strings.TrimSpace(fields[i]), 64)
+		return f
+	}
+
+	switch info.System {
+	case "LTE":
+		if len(fields) < 14 {
+			return
+		}
+		info.PhysicalID = int(field(5))
+		info.Band = fields[6]
+		info.Channel = int(field(7))
+
+		// Tenths of a dB, and the SNR is scaled to 0-25
+		info.RSRQ = field(10) / 10
+		info.RSRP = field(11) / 10
+		info.SINR = 2*field(13) - 20
+	case "WCDMA":
+		if len(fields) < 8 {
+			return
+		}
+		info.Band = fields[5]
+		info.PhysicalID = int(field(6))
+		info.Channel = int(field(7))
+	case "GSM":
+		if len(fields) < 7 {
+			return
+		}
+		channel, band, _ := strings.Cut(fields[5], " ")
+		info.Channel, _ = strconv.Atoi(channel)
+		info.Band = band
+		info.RxLev = field(6)
+	}
+}
+
+// Fills in the signal levels AT+CPSI? didn't give from AT+CESQ, which reports
+// them in the steps 27.007 defines. 99 and 255 mean not known.
+func parseCESQ(line string, info *CellInfo) {
+	matches := cesqRegex.FindStringSubmatch(line)
+	if matches == nil {
+		return
+	}
+	values := make([]int, 6)
+	for i := range values {
+		values[i], _ = strconv.Atoi(matches[i+1])
+	}
+	rxlev, rscp, ecno, rsrq, rsrp := values[0], values[2], values[3], values[4], values[5]
+
+	if info.RxLev == 0 && rxlev != 99 {
+		info.RxLev = float64(-111 + rxlev)
+	}
+	if info.RSCP == 0 && rscp != 255 {
+		info.RSCP = float64(-121 + rscp)
+	}
+	if info.EcIo == 0 && ecno != 255 {
+		info.EcIo = -24.5 + float64(ecno)/2
+	}
+	if info.RSRQ == 0 && rsrq != 255 {
+		info.RSRQ = -20 + float64(rsrq)/2
+	}
+	if info.RSRP == 0 && rsrp != 255 {
+		info.RSRP = float64(-141 + rsrp)
+	}
+}
+
+// Reads a neighbour from an AT+CCINFO line like
+// +CCINFO:[NCELL1],ARFCN:27,MCC:460,MNC:00,LAC:6189,ID:12401,BSIC:52,RXLev:-85dbm
+func parseNeighbour(line string) (NeighbourCell, bool) {
+	matches := ccinfoRegex.FindStringSubmatch(line)
+	if matches == nil {
+		return NeighbourCell{}, false
+	}
+
+	var cell NeighbourCell
+	for pair := range strings.SplitSeq(matches[1], ",") {
+		key, value, _ := strings.Cut(pair, ":")
+		switch strings.ToUpper(key) {
+		case "ARFCN":
+			cell.Channel, _ = strconv.Atoi(value)
+		case "LAC":
+			cell.AreaCode = int(parseCellNumber(value))
+		case "ID":
+			cell.CellID = parseCellNumber(value)
+		case "RXLEV":
+			cell.RxLev, _ = strconv.ParseFloat(strings.TrimSuffix(strings.ToLower(value), "dbm"), 64)
+		}
+	}
+	return cell, true
+}
+
+// CellInfo asks the modem about the serving cell and its neighbours, for the
+// field test screen. Neighbours are only known on GSM, and modems that can't
+// list them just leave them out.
+func (m *Modem) CellInfo(ctx context.Context) (*CellInfo, error) {
+	resp, err := m.Exec(ctx, Command{Text: "AT+CPSI?"})
+	if err != nil {
+		return nil, err
+	}
+
+	info := &CellInfo{}
+	for _, line := range resp.Lines {
+		if strings.HasPrefix(line, "+CPSI:") {
+			parseCPSI(line, info)
+		}
+	}
+
+	if resp, err := m.Exec(ctx, Command{Text: "AT+CESQ"}); err == nil {
+		for _, line := range resp.Lines {
+			parseCESQ(line, info)
+		}
+	}
+
+	if resp, err := m.Exec(ctx, Command{Text: "AT+CCINFO"}); err == nil {
+		for _, line := range resp.Lines {
+			if cell, ok := parseNeighbour(line); ok {
+				info.Neighbours = append(info.Neighbours, cell)
+			}
+		}
+	}
+
+	return info, nil
+}
+
+// SetAreaInfo turns the area name broadcast on or off. The name shows up in
+// State once the network sends it.
+func (m *Modem) SetAreaInfo(ctx context.Context, enabled bool) error {
+	channels := ""
+	if enabled {
+		channels = strconv.Itoa(areaInfoChannel)
+	}
+	if _, err := m.Exec(ctx, Command{Text: fmt.Sprintf(`AT+CSCB=0,"%s"`, channels)}); err != nil {
+		return err
+	}
+	if !enabled {
+		m.updateState(func(s *State) { s.AreaName = "" })
+	}
+	return nil
+}
+
+// Decodes a cell broadcast page, returning its channel and text
+func decodeCellBroadcast(pdu string) (int, string, error) {
+	b, err := hex.DecodeString(strings.TrimSpace(pdu))
+	if err != nil {
+		return 0, "", err
+	}
+	if len(b) < 7 {
+		return 0, "", fmt.Errorf("cell broadcast too short: %d bytes", len(b))
+	}
+
+	// Serial number, message identifier, coding scheme and page, then the text
+	channel := int(b[2])<<8 | int(b[3])
+	dcs := b[4]
+	content := b[6:]
+
+	var text string
+	switch {
+	case dcs == 0x11:
+		// UCS-2, after a two letter language in GSM 7-bit
+		if len(content) < 2 {
+			return channel, "", nil
+		}
+		runes, err := ucs2.Decode(content[2:])
+		if err != nil {
+			return 0, "", err
+		}
+		text = string(runes)
+	case dcs&0xcc == 0x48:
+		runes, err := ucs2.Decode(content)
+		if err != nil {
+			return 0, "", err
+		}
+		text = string(runes)
+	default:
+		decoded, err := gsm7.Decode(gsm7.Unpack7Bit(content, 0))
+		if err != nil {
+			return 0, "", err
+		}
+		text = string(decoded)
+
+		// A three letter language comes first
+		if dcs == 0x10 && len(text) >= 3 {
+			text = text[3:]
+		}
+	}
+
+	// Pages are padded out with carriage returns
+	return channel, strings.TrimRight(text, "\r\n\x00 "), nil
+}
+
+// A cell broadcast page arrived, the header and the PDU separated by \r
+func (m *Modem) handleCellBroadcast(line string) {
+	header, pdu, ok := strings.Cut(line, "\r")
+	if !ok || !cbmRegex.MatchString(header) {
+		return
+	}
+
+	channel, text, err := decodeCellBroadcast(pdu)
+	if err != nil {
+		log.Println("⚠️ Bad cell broadcast:", err)
+		return
+	}
+	if channel != areaInfoChannel {
+		return
+	}
+
+	if m.DebugMode {
+		log.Println("📡 Area name:", text)
+	}
+	m.updateState(func(s *State) { s.AreaName = text })
+}
@@ -0,0 +1,76 @@
+package phone
+
+import (
+	"context"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The modem's clock starts from 1980 until the network sets it, anything
+// before this is ignored
+const minNetworkYear = 2020
+
+var cclkRegex = regexp.MustCompile(`\+CCLK:\s*"(\d{2}/\d{2}/\d{2},\d{2}:\d{2}:\d{2}[+-]\d{1,2})"`)
+
+// +CTZV: <tz> and +CTZE: <tz>,<dst>[,<time>], where tz is the offset from UTC
+// in quarter hours and dst the hours of daylight saving included in it
+var ctzRegex = regexp.MustCompile(`\+CTZ[VE]:\s*"?([+-]?\d+)"?(?:,(\d))?`)
+
+// Reads the modem's clock, which AT+CTZU keeps in step with the network
+func (m *Modem) handleClock(line string) {
+	matches := cclkRegex.FindStringSubmatch(line)
+	if matches == nil {
+		return
+	}
+
+	t, err := parseModemTime(matches[1])
+	if err != nil {
+		log.Println("⚠️ Bad modem clock:", err)
+		return
+	}
+	if t.Year() < minNetworkYear {
+		if m.DebugMode {
+			log.Println("🕒 Network hasn't sent the time yet")
+		}
+		return
+	}
+
+	m.mu.Lock()
+	dst := m.dst
+	m.mu.Unlock()
+
+	if m.DebugMode {
+		log.Printf("🕒 Network time: %s (DST %d)", t.Format(time.RFC3339), dst)
+	}
+	m.publish(ClockEvent{Time: t, DST: dst})
+}
+
+// The network sent its time zone, and with AT+CTZU the time along with it
+func (m *Modem) handleTimeZone(line string) {
+	matches := ctzRegex.FindStringSubmatch(line)
+	if matches == nil {
+		return
+	}
+
+	if matches[2] != "" {
+		dst, _ := strconv.Atoi(matches[2])
+		m.mu.Lock()
+		m.dst = dst
+		m.mu.Unlock()
+	}
+	if m.DebugMode {
+		log.Printf("🕒 Time zone: %s quarter hours", strings.TrimPrefix(matches[1], "+"))
+	}
+	m.RequestNetworkTime()
+}
+
+// RequestNetworkTime reads the modem's clock, which sends a ClockEvent if the
+// network has set it.
+func (m *Modem) RequestNetworkTime() {
+	if resp, _ := m.Exec(context.Background(), Command{Text: "AT+CCLK?"}); resp != nil {
+		m.HandleEvent(resp.String())
+	}
+}
@@ -0,0 +1,325 @@
+package phone
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrTimeout is returned when the modem doesn't finish a command in time
+var ErrTimeout = errors.New("modem did not answer in time")
+
+// ErrNoModem is returned when the modem isn't connected, or goes away before
+// it answers
+var ErrNoModem = errors.New("modem is not connected")
+
+// How long a command may take when its rule doesn't say otherwise
+const defaultCommandTimeout = 10 * time.Second
+
+// Command is an AT command and what it needs besides the text
+type Command struct {
+	Text    string
+	Timeout time.Duration // Overrides the command's rule when set
+	Body    string        // Written after the "> " prompt and ended with Ctrl+Z, for AT+CMGS
+}
+
+// Response is what the modem answered a command with
+type Response struct {
+	Lines  []string // Everything before the final result, without echo or URCs
+	Result string   // The final result, like OK, ERROR or +CME ERROR: 10
+}
+
+// String gives the lines and the final result the way the modem sent them
+func (r *Response) String() string {
+	return strings.Join(append(slices.Clone(r.Lines), r.Result), "\n")
+}
+
+// ResultError is a final result other than OK, like ERROR, NO CARRIER or BUSY
+type ResultError struct {
+	Command string
+	Result  string
+}
+
+func (e *ResultError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Command, e.Result)
+}
+
+// CMEError is a +CME ERROR final result, for modem, SIM and network failures.
+// Code is -1 when the modem gave the reason as text.
+type CMEError struct {
+	Command string
+	Code    int
+	Message string
+}
+
+func (e *CMEError) Error() string {
+	return fmt.Sprintf("%s: +CME ERROR: %s", e.Command, e.Message)
+}
+
+// +CME ERROR codes worth telling the user apart, from 27.007
+const (
+	CMENotAllowed        = 3
+	CMENotSupported      = 4
+	CMEIncorrectPassword = 16
+)
+
+// CMSError is a +CMS ERROR final result, for SMS failures. Code is -1 when
+// the modem gave the reason as text.
+type CMSError struct {
+	Command string
+	Code    int
+	Message string
+}
+
+func (e *CMSError) Error() string {
+	return fmt.Sprintf("%s: +CMS ERROR: %s", e.Command, e.Message)
+}
+
+// How to run the commands starting with a given prefix
+type commandRule struct {
+	prefix  string
+	timeout time.Duration
+	finals  []string // Final results on top of OK, ERROR, +CME ERROR and +CMS ERROR
+	prompt  bool     // Waits for "> " before the body
+}
+
+// Commands that don't follow the defaults, longest prefix first
+var commandRules = []commandRule{
+	{prefix: "AT+CMGS=", timeout: 60 * time.Second, prompt: true},
+	{prefix: "AT+COPS=", timeout: 180 * time.Second},
+	{prefix: "AT+CCFC=", timeout: 30 * time.Second},
+	{prefix: "AT+CLCK=", timeout: 30 * time.Second},
+	{prefix: "AT+CPWD=", timeout: 30 * time.Second},
+	{prefix: "AT+CUSD=", timeout: 30 * time.Second},
+	{prefix: "AT+CFUN=", timeout: 30 * time.Second},
+	{prefix: "ATD", timeout: 30 * time.Second, finals: []string{"NO CARRIER", "BUSY", "NO ANSWER", "NO DIALTONE"}},
+	{prefix: "ATA", timeout: 30 * time.Second, finals: []string{"NO CARRIER"}},
+}
+
+func ruleFor(text string) commandRule {
+	upper := strings.ToUpper(text)
+	for _, rule := range commandRules {
+		if strings.HasPrefix(upper, rule.prefix) {
+			return rule
+		}
+	}
+	return commandRule{timeout: defaultCommandTimeout}
+}
+
+var commandNameRegex = regexp.MustCompile(`^AT(\+[A-Z]+)`)
+
+// Commands that carry a PIN, PUK or password, and how many of their quoted
+// arguments come before it, like the facility of AT+CLCK
+var secretCommands = []struct {
+	prefix string
+	keep   int
+}{
+	{prefix: "AT+CPIN=", keep: 0},
+	{prefix: "AT+CLCK=", keep: 1},
+	{prefix: "AT+CPWD=", keep: 1},
+	{prefix: "AT+CPBS=", keep: 1},
+}
+
+var quotedRegex = regexp.MustCompile(`"[^"]*"`)
+
+// Masks the codes and passwords in a command so it can be logged
+func redact(text string) string {
+	upper := strings.ToUpper(text)
+	for _, secret := range secretCommands {
+		if !strings.HasPrefix(upper, secret.prefix) {
+			continue
+		}
+		n := 0
+		return quotedRegex.ReplaceAllStringFunc(text, func(arg string) string {
+			n++
+			if n <= secret.keep {
+				return arg
+			}
+			return `"****"`
+		})
+	}
+	return text
+}
+
+// A command that's been sent and is waiting for its final result
+type pendingCommand struct {
+	text   string
+	rule   commandRule
+	name   string // Like +CSQ, the prefix of the command's own answer lines
+	lines  []string
+	prompt chan struct{}
+	done   chan string
+	gone   <-chan struct{} // Closed if the modem goes away first
+}
+
+// Whether a line is the command's final result
+func (p *pendingCommand) isFinal(line string) bool {
+	if line == "OK" || line == "ERROR" || strings.HasPrefix(line, "+CME ERROR:") || strings.HasPrefix(line, "+CMS ERROR:") {
+		return true
+	}
+	for _, final := range p.rule.finals {
+		if strings.HasPrefix(line, final) {
+			return true
+		}
+	}
+	return false
+}
+
+// Whether a line that looks like a URC is really the command's answer
+func (p *pendingCommand) owns(line string) bool {
+	return p.name != "" && strings.HasPrefix(line, p.name)
+}
+
+// Turns a final result into an error, nil for OK
+func resultError(text, result string) error {
+	switch {
+	case result == "OK":
+		return nil
+	case strings.HasPrefix(result, "+CME ERROR:"):
+		message := strings.TrimSpace(strings.TrimPrefix(result, "+CME ERROR:"))
+		code, err := strconv.Atoi(message)
+		if err != nil {
+			code = -1
+		}
+		return &CMEError{Command: text, Code: code, Message: message}
+	case strings.HasPrefix(result, "+CMS ERROR:"):
+		message := strings.TrimSpace(strings.TrimPrefix(result, "+CMS ERROR:"))
+		code, err := strconv.Atoi(message)
+		if err != nil {
+			code = -1
+		}
+		return &CMSError{Command: text, Code: code, Message: message}
+	}
+	return &ResultError{Command: text, Result: result}
+}
+
+// Exec sends a command and waits for its final result, giving up when ctx
+// ends or the command's timeout runs out. The response comes back along with
+// the error when the modem answered with anything but OK.
+func (m *Modem) Exec(ctx context.Context, cmd Command) (*Response, error) {
+	p := &pendingCommand{
+		text:   cmd.Text,
+		rule:   ruleFor(cmd.Text),
+		prompt: make(chan struct{}, 1),
+		done:   make(chan string, 1),
+	}
+	if matches := commandNameRegex.FindStringSubmatch(strings.ToUpper(cmd.Text)); matches != nil {
+		p.name = matches[1]
+	}
+
+	timeout := p.rule.timeout
+	if cmd.Timeout > 0 {
+		timeout = cmd.Timeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	// Codes and passwords stay out of the log and out of errors
+	shown := redact(cmd.Text)
+
+	m.cmdMutex.Lock()
+	defer m.cmdMutex.Unlock()
+
+	log.Println(shown)
+	if err := m.write(p, cmd.Text+"\r"); err != nil {
+		return nil, err
+	}
+
+	if p.rule.prompt {
+		select {
+		case <-p.prompt:
+			if err := m.write(p, cmd.Body+"\x1a"); err != nil {
+				return nil, err
+			}
+		case result := <-p.done:
+			// Refused before it asked for the body
+			resp := &Response{Lines: p.lines, Result: result}
+			return resp, resultError(shown, result)
+		case <-p.gone:
+			return nil, fmt.Errorf("%s: %w", shown, ErrNoModem)
+		case <-ctx.Done():
+			// Escape backs out of the prompt without sending anything
+			m.write(nil, "\x1b")
+			return nil, m.abandon(p, ctx)
+		}
+	}
+
+	select {
+	case result := <-p.done:
+		resp := &Response{Lines: p.lines, Result: result}
+		return resp, resultError(shown, result)
+	case <-p.gone:
+		return nil, fmt.Errorf("%s: %w", shown, ErrNoModem)
+	case <-ctx.Done():
+		return nil, m.abandon(p, ctx)
+	}
+}
+
+// Writes to the modem, making p the command that's waiting for an answer
+func (m *Modem) write(p *pendingCommand, data string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.conn == nil {
+		return ErrNoModem
+	}
+	if p != nil {
+		p.gone = m.conn.gone
+		m.pending = p
+	}
+	if _, err := m.conn.port.Write([]byte(data)); err != nil {
+		m.pending = nil
+		return err
+	}
+	return nil
+}
+
+// Stops waiting for a command that ran out of time
+func (m *Modem) abandon(p *pendingCommand, ctx context.Context) error {
+	m.mu.Lock()
+	if m.pending == p {
+		m.pending = nil
+	}
+	m.mu.Unlock()
+
+	shown := redact(p.text)
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		log.Printf("⏱️ %s timed out", shown)
+		return fmt.Errorf("%s: %w", shown, ErrTimeout)
+	}
+	return fmt.Errorf("%s: %w", shown, ctx.Err())
+}
+
+// Hands a line to the command waiting for an answer. Returns false if it
+// isn't part of the answer, so it should be treated as a URC. Must be called
+// with mu held.
+func (m *Modem) routeLine(line string) bool {
+	p := m.pending
+	if p == nil {
+		return false
+	}
+
+	switch {
+	case p.isFinal(line):
+		m.pending = nil
+		p.done <- line
+	case line == ">" && p.rule.prompt:
+		select {
+		case p.prompt <- struct{}{}:
+		default:
+		}
+	case line == p.text:
+		// Echo
+	case m.isUnsolicited(line) && !p.owns(line):
+		return false
+	default:
+		p.lines = append(p.lines, line)
+	}
+	return true
+}
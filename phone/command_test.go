@@ -0,0 +1,54 @@
+package phone
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	tests := []struct {
+		cmd  string
+		want string
+	}{
+		{`AT+CPIN="1234"`, `AT+CPIN="****"`},
+		{`AT+CPIN="12345678","1234"`, `AT+CPIN="****","****"`},
+		{`at+cpin="1234"`, `at+cpin="****"`},
+		{`AT+CLCK="AI",1,"0000",1`, `AT+CLCK="AI",1,"****",1`},
+		{`AT+CLCK="SC",0,"1234"`, `AT+CLCK="SC",0,"****"`},
+		{`AT+CPWD="SC","1111","2222"`, `AT+CPWD="SC","****","****"`},
+		{`AT+CPBS="FD","5678"`, `AT+CPBS="FD","****"`},
+		{`AT+CPBS="SM"`, `AT+CPBS="SM"`},
+		{`AT+CLCK="AI",2`, `AT+CLCK="AI",2`},
+		{`AT+CPIN?`, `AT+CPIN?`},
+		{`AT+CUSD=1,"*100#",15`, `AT+CUSD=1,"*100#",15`},
+	}
+	for _, tt := range tests {
+		if got := redact(tt.cmd); got != tt.want {
+			t.Errorf("redact(%q) = %q, want %q", tt.cmd, got, tt.want)
+		}
+	}
+}
+
+func TestResultError(t *testing.T) {
+	if err := resultError("AT", "OK"); err != nil {
+		t.Errorf("resultError(OK) = %v, want nil", err)
+	}
+
+	var cme *CMEError
+	if err := resultError("AT+CLCK", "+CME ERROR: 16"); !errors.As(err, &cme) || cme.Code != CMEIncorrectPassword {
+		t.Errorf("resultError(+CME ERROR: 16) = %v, want a *CMEError with code 16", err)
+	}
+	if err := resultError("AT+CLCK", "+CME ERROR: incorrect password"); !errors.As(err, &cme) || cme.Code != -1 || cme.Message != "incorrect password" {
+		t.Errorf("resultError(+CME ERROR: incorrect password) = %v, want a *CMEError with code -1", err)
+	}
+
+	var cms *CMSError
+	if err := resultError("AT+CMGS", "+CMS ERROR: 500"); !errors.As(err, &cms) || cms.Code != 500 {
+		t.Errorf("resultError(+CMS ERROR: 500) = %v, want a *CMSError with code 500", err)
+	}
+
+	var result *ResultError
+	if err := resultError("ATD5551234;", "NO CARRIER"); !errors.As(err, &result) || result.Result != "NO CARRIER" {
+		t.Errorf("resultError(NO CARRIER) = %v, want a *ResultError", err)
+	}
+}
@@ -0,0 +1,39 @@
+package phone
+
+import (
+	"context"
+	"fmt"
+)
+
+// How the network checks the APN user name and password, as AT+CGAUTH takes
+// them
+const (
+	APNAuthNone = iota
+	APNAuthPAP
+	APNAuthCHAP
+	APNAuthPAPOrCHAP
+)
+
+// DataInterface is the network interface cellular data comes up on
+const DataInterface = "wwan0"
+
+// The PDP context the modem attaches to LTE with, and that data uses
+const dataContext = 1
+
+// SetAPN sets the access point for the default PDP context, along with the
+// user name, password and how they are checked. An empty APN leaves the choice
+// to the network. A setting the modem won't take comes back as a *CMEError
+// or *ResultError.
+func (m *Modem) SetAPN(ctx context.Context, apn, user, password string, auth int) error {
+	if _, err := m.Exec(ctx, Command{Text: fmt.Sprintf(`AT+CGDCONT=%d,"IP","%s"`, dataContext, apn)}); err != nil {
+		return err
+	}
+
+	// The SIM7600 takes the password before the user name
+	cmd := fmt.Sprintf("AT+CGAUTH=%d,0", dataContext)
+	if auth != APNAuthNone {
+		cmd = fmt.Sprintf(`AT+CGAUTH=%d,%d,"%s","%s"`, dataContext, auth, password, user)
+	}
+	_, err := m.Exec(ctx, Command{Text: cmd})
+	return err
+}
@@ -0,0 +1,96 @@
+package phone
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Reasons a call can be diverted for, as used by AT+CCFC
+const (
+	DivertUnconditional  = 0
+	DivertBusy           = 1
+	DivertNoReply        = 2
+	DivertNotReachable   = 3
+	DivertAll            = 4 // Every reason, only for erasing
+	DivertAllConditional = 5 // Busy, no reply and not reachable, only for erasing
+)
+
+// Only voice calls are diverted, data and fax are left alone
+const divertVoiceClass = 1
+
+// DivertRule is the network's forwarding setting for one reason. Time is how
+// many seconds a call rings before it's diverted, for DivertNoReply only.
+type DivertRule struct {
+	Reason int
+	Active bool
+	Number string
+	Time   int
+}
+
+var ccfcRegex = regexp.MustCompile(`\+CCFC:\s*(\d+),(\d+)(?:,"([^"]*)",(\d+)(?:,[^,]*,[^,]*(?:,(\d+))?)?)?`)
+
+// Sends an AT+CCFC command. A request the network turned down comes back as
+// a *CMEError.
+func (m *Modem) ccfc(ctx context.Context, args string) (string, error) {
+	resp, err := m.Exec(ctx, Command{Text: "AT+CCFC=" + args})
+	if err != nil {
+		return "", err
+	}
+	return resp.String(), nil
+}
+
+// QueryDivert asks the network whether voice calls are diverted for a reason,
+// and where to.
+func (m *Modem) QueryDivert(ctx context.Context, reason int) (*DivertRule, error) {
+	resp, err := m.ccfc(ctx, fmt.Sprintf("%d,2", reason))
+	if err != nil {
+		return nil, err
+	}
+	return parseDivert(reason, resp), nil
+}
+
+// Reads the voice rule out of an AT+CCFC query's answer. There's a line per
+// class, or a single inactive one for all of them.
+func parseDivert(reason int, resp string) *DivertRule {
+	rule := &DivertRule{Reason: reason}
+	for _, matches := range ccfcRegex.FindAllStringSubmatch(resp, -1) {
+		class, _ := strconv.Atoi(matches[2])
+		if matches[1] != "1" || class&divertVoiceClass == 0 {
+			continue
+		}
+		rule.Active = true
+		rule.Number = matches[3]
+		if matches[4] == "145" && !strings.HasPrefix(rule.Number, "+") {
+			rule.Number = "+" + rule.Number
+		}
+		rule.Time, _ = strconv.Atoi(matches[5])
+	}
+	return rule
+}
+
+// RegisterDivert diverts voice calls for a reason to a number. The delay in
+// seconds is only used for DivertNoReply, and the network picks its own
+// default when it's 0.
+func (m *Modem) RegisterDivert(ctx context.Context, reason int, number string, delay int) error {
+	number_type := 129
+	if strings.HasPrefix(number, "+") {
+		number_type = 145
+	}
+
+	args := fmt.Sprintf(`%d,3,"%s",%d,%d`, reason, number, number_type, divertVoiceClass)
+	if reason == DivertNoReply && delay > 0 {
+		args += fmt.Sprintf(",,,%d", delay)
+	}
+	_, err := m.ccfc(ctx, args)
+	return err
+}
+
+// EraseDivert stops diverting voice calls for a reason, or for several with
+// DivertAll and DivertAllConditional.
+func (m *Modem) EraseDivert(ctx context.Context, reason int) error {
+	_, err := m.ccfc(ctx, fmt.Sprintf("%d,4,,,%d", reason, divertVoiceClass))
+	return err
+}
@@ -0,0 +1,26 @@
+package phone
+
+import "testing"
+
+func TestParseDivert(t *testing.T) {
+	tests := []struct {
+		name string
+		resp string
+		want DivertRule
+	}{
+		{"off for every class", "+CCFC: 0,7\nOK", DivertRule{}},
+		{"national number", "+CCFC: 1,1,\"5551234567\",129\nOK", DivertRule{Active: true, Number: "5551234567"}},
+		{"international number", "+CCFC: 1,1,\"+15551234567\",145\nOK", DivertRule{Active: true, Number: "+15551234567"}},
+		{"international number without plus", "+CCFC: 1,1,\"15551234567\",145\nOK", DivertRule{Active: true, Number: "+15551234567"}},
+		{"no reply delay", "+CCFC: 1,1,\"5551234567\",129,,,20\nOK", DivertRule{Active: true, Number: "5551234567", Time: 20}},
+		{"data only", "+CCFC: 1,2,\"5550000\",129\nOK", DivertRule{}},
+		{"voice among other classes", "+CCFC: 1,2,\"5550000\",129\n+CCFC: 1,1,\"5551111\",129\nOK", DivertRule{Active: true, Number: "5551111"}},
+		{"voice and data together", "+CCFC: 1,3,\"5552222\",129\nOK", DivertRule{Active: true, Number: "5552222"}},
+	}
+	for _, tt := range tests {
+		tt.want.Reason = DivertNoReply
+		if got := parseDivert(DivertNoReply, tt.resp); *got != tt.want {
+			t.Errorf("%s: parseDivert(%q) = %+v, want %+v", tt.name, tt.resp, *got, tt.want)
+		}
+	}
+}
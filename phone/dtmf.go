@@ -0,0 +1,123 @@
+package phone
+
+import (
+	"log"
+	"strings"
+	"time"
+)
+
+// Characters in a dial string that hold back the tones after them. A pause
+// waits a few seconds, a wait until SendPostDial.
+const (
+	DTMFPause = 'p'
+	DTMFWait  = 'w'
+)
+
+// The tones AT+VTS can send
+const dtmfTones = "0123456789*#ABCD"
+
+// How long a pause holds back the tones after it
+const dtmfPauseDuration = 3 * time.Second
+
+// SplitDialString splits a dial string like +18005551234,,1234# into the
+// number to dial and the tones to send once the call is answered. Commas are
+// pauses and semicolons waits, as other phones write them.
+func SplitDialString(dial string) (number, tones string) {
+	i := strings.IndexAny(dial, "pPwW,;")
+	if i < 0 {
+		return dial, ""
+	}
+	tones = strings.Map(func(r rune) rune {
+		switch r {
+		case 'p', 'P', ',':
+			return DTMFPause
+		case 'w', 'W', ';':
+			return DTMFWait
+		}
+		return r
+	}, dial[i:])
+	return dial[:i], tones
+}
+
+// The outgoing call was answered, send the tones dialed after its number
+func (m *Modem) startPostDial() {
+	m.mu.Lock()
+	tones := m.postDial
+	m.postDial = ""
+	m.mu.Unlock()
+
+	if tones != "" {
+		m.sendTones(tones)
+	}
+}
+
+// Sends tones one at a time, sleeping at each pause and stopping at a wait
+// until SendPostDial. Gives up if the call is no longer active, or once
+// CancelPostDial is called so a later call doesn't get them.
+func (m *Modem) sendTones(tones string) {
+	m.mu.Lock()
+	generation := m.toneGeneration
+	m.mu.Unlock()
+
+	for i, tone := range tones {
+		if m.tonesCancelled(generation) {
+			return
+		}
+
+		switch tone {
+		case DTMFPause:
+			time.Sleep(dtmfPauseDuration)
+		case DTMFWait:
+			if rest := tones[i+1:]; rest != "" {
+				m.updateState(func(s *State) { s.PostDialWait = rest })
+			}
+			return
+		default:
+			if m.State().Call.Status != "active" {
+				return
+			}
+			if m.DebugMode {
+				log.Printf("☎️ Sending tone %c", tone)
+			}
+			m.EnterNumber(tone)
+		}
+	}
+}
+
+// Whether CancelPostDial was called after the tones of a generation started
+func (m *Modem) tonesCancelled(generation int) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.toneGeneration != generation
+}
+
+// SendDTMF sends tones during the active call, skipping anything that isn't
+// a DTMF tone.
+func (m *Modem) SendDTMF(tones string) {
+	tones = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(dtmfTones, r) {
+			return r
+		}
+		return -1
+	}, strings.ToUpper(tones))
+	go m.sendTones(tones)
+}
+
+// SendPostDial sends the tones held back at a wait, see State.PostDialWait.
+func (m *Modem) SendPostDial() {
+	var tones string
+	m.updateState(func(s *State) {
+		tones = s.PostDialWait
+		s.PostDialWait = ""
+	})
+	go m.sendTones(tones)
+}
+
+// CancelPostDial drops the tones held back at a wait, and any not sent yet.
+func (m *Modem) CancelPostDial() {
+	m.mu.Lock()
+	m.postDial = ""
+	m.toneGeneration++
+	m.mu.Unlock()
+	m.updateState(func(s *State) { s.PostDialWait = "" })
+}
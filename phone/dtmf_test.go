@@ -0,0 +1,27 @@
+package phone
+
+import "testing"
+
+func TestSplitDialString(t *testing.T) {
+	tests := []struct {
+		dial   string
+		number string
+		tones  string
+	}{
+		{"5551234", "5551234", ""},
+		{"+18005551234", "+18005551234", ""},
+		{"*#06#", "*#06#", ""},
+		{"18005551234,,1234#", "18005551234", "pp1234#"},
+		{"18005551234;1234", "18005551234", "w1234"},
+		{"123p4w56", "123", "p4w56"},
+		{"123P4W5", "123", "p4w5"},
+		{"p1", "", "p1"},
+		{"", "", ""},
+	}
+	for _, tt := range tests {
+		number, tones := SplitDialString(tt.dial)
+		if number != tt.number || tones != tt.tones {
+			t.Errorf("SplitDialString(%q) = %q, %q, want %q, %q", tt.dial, number, tones, tt.number, tt.tones)
+		}
+	}
+}
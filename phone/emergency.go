@@ -0,0 +1,238 @@
+package phone
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Numbers that reach the emergency services on any network (3GPP TS 22.101)
+var defaultEmergencyNumbers = []string{"112", "911"}
+
+// Numbers that are also treated as emergency numbers when there's no SIM to
+// say otherwise
+var noSIMEmergencyNumbers = []string{"000", "08", "110", "118", "119", "999"}
+
+// Emergency numbers a SIM's home country uses on top of the defaults, by MCC.
+// Carriers don't always put these in EF_ECC.
+var carrierEmergencyNumbers = map[string][]string{
+	"208": {"15", "17", "18"},           // France
+	"234": {"999"},                      // United Kingdom
+	"235": {"999"},                      // United Kingdom
+	"262": {"110"},                      // Germany
+	"404": {"100", "101", "102", "108"}, // India
+	"405": {"100", "101", "102", "108"}, // India
+	"440": {"110", "119"},               // Japan
+	"441": {"110", "119"},               // Japan
+	"450": {"119"},                      // South Korea
+	"454": {"999"},                      // Hong Kong
+	"460": {"110", "119", "120"},        // China
+	"505": {"000"},                      // Australia
+	"525": {"995", "999"},               // Singapore
+	"530": {"111"},                      // New Zealand
+	"724": {"190", "192", "193"},        // Brazil
+}
+
+// EF_ECC, the SIM's list of emergency numbers
+const efECC = 0x6FB7
+
+var crsmRegex = regexp.MustCompile(`\+CRSM:\s*(\d+),(\d+)(?:,"?([0-9A-Fa-f]*)"?)?`)
+
+// Reads an elementary file off the SIM with AT+CRSM. Record is 0 for a
+// transparent file.
+func (m *Modem) readSIMFile(file int, record int, length int) ([]byte, error) {
+	cmd := fmt.Sprintf("AT+CRSM=176,%d,0,0,%d", file, length)
+	if record > 0 {
+		cmd = fmt.Sprintf("AT+CRSM=178,%d,%d,4,%d", file, record, length)
+	}
+	resp, err := m.Exec(context.Background(), Command{Text: cmd})
+	if err != nil {
+		return nil, err
+	}
+
+	matches := crsmRegex.FindStringSubmatch(resp.String())
+	if matches == nil {
+		return nil, fmt.Errorf("failed to read SIM file %04X: %s", file, resp)
+	}
+	if matches[1] != "144" && matches[1] != "145" {
+		return nil, fmt.Errorf("failed to read SIM file %04X: status %s,%s", file, matches[1], matches[2])
+	}
+	return hex.DecodeString(matches[3])
+}
+
+// Decodes a BCD number as the SIM stores it, low digit first and padded with F
+func decodeBCDNumber(data []byte) string {
+	var number strings.Builder
+	for _, b := range data {
+		for _, digit := range []byte{b & 0x0F, b >> 4} {
+			if digit > 9 {
+				return number.String()
+			}
+			number.WriteByte('0' + digit)
+		}
+	}
+	return number.String()
+}
+
+var imsiRegex = regexp.MustCompile(`\b\d{6,15}\b`)
+
+// Reads the SIM's emergency numbers and home country, so IsEmergencyNumber
+// knows about them
+func (m *Modem) loadEmergencyNumbers() {
+	var numbers []string
+
+	// USIMs keep one number per record, with the rest of the record naming
+	// it. Length 0 reads the whole record.
+	for record := 1; record <= 10; record++ {
+		data, err := m.readSIMFile(efECC, record, 0)
+		if err != nil {
+			break
+		}
+		if number := decodeBCDNumber(data[:min(3, len(data))]); number != "" {
+			numbers = append(numbers, number)
+		}
+	}
+
+	// Older SIMs keep up to five numbers back to back
+	if len(numbers) == 0 {
+		if data, err := m.readSIMFile(efECC, 0, 15); err == nil {
+			for i := 0; i+3 <= len(data); i += 3 {
+				if number := decodeBCDNumber(data[i : i+3]); number != "" {
+					numbers = append(numbers, number)
+				}
+			}
+		}
+	}
+
+	mcc := ""
+	if resp, err := m.Exec(context.Background(), Command{Text: "AT+CIMI"}); err == nil {
+		if imsi := imsiRegex.FindString(resp.String()); imsi != "" {
+			mcc = imsi[:3]
+		}
+	}
+
+	m.stateMu.Lock()
+	m.simECC = numbers
+	m.homeMCC = mcc
+	m.stateMu.Unlock()
+	log.Printf("🆘 Emergency numbers: %v", m.EmergencyNumbers())
+}
+
+// EmergencyNumbers lists the numbers that can be called in an emergency: the
+// defaults, the ones on the SIM and the ones its home country uses.
+func (m *Modem) EmergencyNumbers() []string {
+	m.stateMu.RLock()
+	s, simECC, mcc := m.state, m.simECC, m.homeMCC
+	m.stateMu.RUnlock()
+
+	numbers := slices.Clone(defaultEmergencyNumbers)
+	if !s.SimCardInserted && s.SIMLock == "" {
+		numbers = append(numbers, noSIMEmergencyNumbers...)
+	}
+	numbers = append(numbers, simECC...)
+	numbers = append(numbers, carrierEmergencyNumbers[mcc]...)
+
+	slices.Sort(numbers)
+	return slices.Compact(numbers)
+}
+
+// IsEmergencyNumber checks whether a number reaches the emergency services.
+func (m *Modem) IsEmergencyNumber(number string) bool {
+	return slices.Contains(m.EmergencyNumbers(), strings.ReplaceAll(number, " ", ""))
+}
+
+// How long an emergency call waits for the radio to find a network after
+// coming out of airplane mode, before dialing anyway
+const emergencyRegistrationWait = 20 * time.Second
+
+// How long before a failed emergency call is tried again
+const emergencyRetryDelay = 3 * time.Second
+
+// DialEmergency calls an emergency number, with or without a SIM or service.
+// Airplane mode is lifted for the call and comes back with EndEmergency. A
+// call that doesn't go through is tried once more.
+func (m *Modem) DialEmergency(ctx context.Context, number string) error {
+	radioOn := false
+	if m.State().FlightMode {
+		log.Println("🆘 Turning the radio on for an emergency call")
+		events, unsubscribe := m.Subscribe()
+		err := m.ToggleFlightMode()
+		if err == nil {
+			radioOn = true
+			m.waitForNetwork(ctx, events)
+		}
+
+		// Nothing reads events past here, and call events wait for room
+		unsubscribe()
+		if err != nil {
+			return err
+		}
+	}
+
+	m.updateState(func(s *State) {
+		s.EmergencyCall = true
+		if radioOn {
+			m.emergencyRadio = true
+		}
+	})
+
+	err := m.dial(ctx, number)
+	if err != nil && !errors.Is(err, ErrNoModem) {
+		log.Printf("🆘 Emergency call failed (%v), trying again", err)
+		select {
+		case <-ctx.Done():
+		case <-time.After(emergencyRetryDelay):
+			err = m.dial(ctx, number)
+		}
+	}
+	if err != nil {
+		m.callFailed(err)
+	}
+	return err
+}
+
+// Waits until the modem reports a network, even one good for emergency calls
+// only, or gives up after emergencyRegistrationWait or when ctx ends
+func (m *Modem) waitForNetwork(ctx context.Context, events <-chan Event) {
+	if m.State().Connected {
+		return
+	}
+
+	timeout := time.After(emergencyRegistrationWait)
+	for {
+		select {
+		case event := <-events:
+			if reg, ok := event.(RegistrationEvent); ok && reg.Connected {
+				return
+			}
+		case <-timeout:
+			log.Println("🆘 No network yet, dialing anyway")
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// EndEmergency puts the radio back in airplane mode if an emergency call took
+// it out. Called once the calls are over.
+func (m *Modem) EndEmergency() {
+	wasEmergency, radioOn := false, false
+	m.updateState(func(s *State) {
+		wasEmergency, radioOn = s.EmergencyCall, m.emergencyRadio
+		s.EmergencyCall = false
+		if wasEmergency {
+			m.emergencyRadio = false
+		}
+	})
+	if wasEmergency && radioOn {
+		log.Println("🆘 Emergency call over, back to airplane mode")
+		m.ToggleFlightMode()
+	}
+}
@@ -7,4 +7,4 @@ require (
 	github.com/warthog618/sms v0.3.0
 )
 
-require golang.org/x/sys v0.41.0 // indirect
+require golang.org/x/sys v0.41.0
@@ -0,0 +1,112 @@
+package phone
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// MemoryTransport is an in-memory ModemTransport. Every command is answered
+// with OK, preceded by the matching entry in Responses if there is one, and
+// URCs can be pushed to the modem with Inject. AT+CMGS prompts for the
+// message body like the modem does.
+type MemoryTransport struct {
+	Responses map[string]string
+
+	mu        sync.Mutex
+	cond      *sync.Cond
+	in        bytes.Buffer
+	out       bytes.Buffer
+	closed    bool
+	smsPrompt bool // Waiting for a message body
+	smsRef    int
+}
+
+func NewMemoryTransport() *MemoryTransport {
+	t := &MemoryTransport{
+		Responses: map[string]string{
+			"AT+CPIN?": "+CPIN: READY",
+			"AT+COPS?": "+COPS: 0,0,\"Rakian\",7",
+			"AT+CSQ":   "+CSQ: 20,99",
+		},
+	}
+	t.cond = sync.NewCond(&t.mu)
+	return t
+}
+
+func (t *MemoryTransport) Read(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for t.out.Len() == 0 && !t.closed {
+		t.cond.Wait()
+	}
+	if t.out.Len() == 0 {
+		return 0, io.ErrClosedPipe
+	}
+	return t.out.Read(p)
+}
+
+func (t *MemoryTransport) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.closed {
+		return 0, io.ErrClosedPipe
+	}
+
+	t.in.Write(p)
+	for {
+		// Commands end with a carriage return, SMS bodies with Ctrl+Z
+		idx := bytes.IndexAny(t.in.Bytes(), "\r\x1a")
+		if idx < 0 {
+			break
+		}
+		cmd := strings.TrimSpace(string(t.in.Next(idx + 1)[:idx]))
+		if cmd == "" {
+			continue
+		}
+		if t.smsPrompt {
+			t.smsPrompt = false
+			t.smsRef++
+			t.writeLine(fmt.Sprintf("+CMGS: %d", t.smsRef))
+			t.writeLine("OK")
+			continue
+		}
+		if strings.HasPrefix(cmd, "AT+CMGS=") {
+			// The prompt has no line ending
+			t.smsPrompt = true
+			t.out.WriteString("\r\n> ")
+			continue
+		}
+		if resp, ok := t.Responses[cmd]; ok {
+			t.writeLine(resp)
+		}
+		t.writeLine("OK")
+	}
+	t.cond.Broadcast()
+
+	return len(p), nil
+}
+
+// Inject queues an unsolicited line (such as "RING") for the modem to read.
+func (t *MemoryTransport) Inject(line string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.writeLine(line)
+	t.cond.Broadcast()
+}
+
+func (t *MemoryTransport) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.closed = true
+	t.cond.Broadcast()
+	return nil
+}
+
+func (t *MemoryTransport) writeLine(line string) {
+	t.out.WriteString("\r\n" + line + "\r\n")
+}
@@ -0,0 +1,108 @@
+package phone
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// Operator availability, as reported by AT+COPS=?
+const (
+	OperatorUnknown   = 0
+	OperatorAvailable = 1
+	OperatorCurrent   = 2
+	OperatorForbidden = 3
+)
+
+// Preferred radio technology, as AT+CNMP takes it
+const (
+	NetworkModeAuto = 2
+	NetworkModeGSM  = 13
+	NetworkModeUMTS = 14
+	NetworkModeLTE  = 38
+)
+
+// Operator is a network found by ScanOperators
+type Operator struct {
+	Status    int
+	Name      string
+	ShortName string
+	Numeric   string // MCC and MNC, which is what SelectOperator takes
+	Act       int    // Access technology, see mapActToGen
+}
+
+// Generation returns the network generation the operator was found on, like
+// LTE.
+func (o Operator) Generation() string {
+	return mapActToGen(o.Act)
+}
+
+var copsListRegex = regexp.MustCompile(`\((\d),"([^"]*)","([^"]*)","(\d+)"(?:,(\d+))?\)`)
+
+// Sends a network selection command. A network that can't be registered on
+// comes back as a *CMEError.
+func (m *Modem) sendOperator(ctx context.Context, cmd string) (string, error) {
+	resp, err := m.Exec(ctx, Command{Text: cmd})
+	if err != nil {
+		return "", err
+	}
+	return resp.String(), nil
+}
+
+// Asks the modem which network it's on, which updates Carrier
+func (m *Modem) readOperator(ctx context.Context) {
+	if resp, _ := m.Exec(ctx, Command{Text: "AT+COPS?"}); resp != nil {
+		m.HandleEvent(resp.String())
+	}
+}
+
+// ScanOperators searches for the networks in range. This can take a minute
+// or two.
+func (m *Modem) ScanOperators(ctx context.Context) ([]Operator, error) {
+	resp, err := m.sendOperator(ctx, "AT+COPS=?")
+	if err != nil {
+		return nil, err
+	}
+
+	var operators []Operator
+	for _, matches := range copsListRegex.FindAllStringSubmatch(resp, -1) {
+		op := Operator{
+			Name:      matches[2],
+			ShortName: matches[3],
+			Numeric:   matches[4],
+		}
+		op.Status, _ = strconv.Atoi(matches[1])
+		op.Act, _ = strconv.Atoi(matches[5])
+		operators = append(operators, op)
+	}
+	return operators, nil
+}
+
+// SelectOperator registers on the given network and stays on it.
+func (m *Modem) SelectOperator(ctx context.Context, op Operator) error {
+	if _, err := m.sendOperator(ctx, fmt.Sprintf(`AT+COPS=1,2,"%s",%d`, op.Numeric, op.Act)); err != nil {
+		return err
+	}
+
+	// Selecting by number switches +COPS to numbers too, go back to names
+	m.Exec(ctx, Command{Text: "AT+COPS=3,0"})
+	m.readOperator(ctx)
+	return nil
+}
+
+// AutomaticOperator lets the modem pick the network again.
+func (m *Modem) AutomaticOperator(ctx context.Context) error {
+	if _, err := m.sendOperator(ctx, "AT+COPS=0"); err != nil {
+		return err
+	}
+	m.readOperator(ctx)
+	return nil
+}
+
+// SetNetworkMode sets which radio technology the modem prefers, one of the
+// NetworkMode values.
+func (m *Modem) SetNetworkMode(ctx context.Context, mode int) error {
+	_, err := m.sendOperator(ctx, fmt.Sprintf("AT+CNMP=%d", mode))
+	return err
+}
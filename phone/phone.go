@@ -2,6 +2,7 @@ package phone
 
 import (
 	"bufio"
+	"context"
 	"encoding/hex"
 	"fmt"
 	"io"
@@ -9,12 +10,14 @@ import (
 	"os"
 	"os/exec"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/tarm/serial"
+	"github.com/warthog618/sms"
 	"github.com/warthog618/sms/encoding/ucs2"
 )
 
@@ -26,72 +29,90 @@ type CallState struct {
 	IsCallInbound    bool
 	PhoneNumber      string
 	StartTime        time.Time
+	Rings            int // RINGs heard so far, while it's incoming
+}
+
+// SMS is a text message delivered by the network
+type SMS struct {
+	Sender    string
+	Body      string
+	Timestamp time.Time
+}
+
+// ModemTransport is the AT command channel to the modem. On the device this is
+// the /dev/ttyUSB2 serial port; MemoryTransport stands in for it otherwise.
+type ModemTransport interface {
+	io.ReadWriteCloser
 }
 
 type Modem struct {
-	CallState         *CallState
-	Port              *serial.Port
-	AudioPort         *serial.Port
-	audioCmd          *exec.Cmd
-	DebugMode         bool
-	mu                sync.Mutex
-	cmdMutex          sync.Mutex
-	lastResponse      strings.Builder
-	cmdWait           sync.WaitGroup
-	RingingChan       chan bool
-	MissedCallChan    chan bool
-	CallStartChan     chan bool
-	CallEndChan       chan bool
-	CallErrorChan     chan bool
-	CallHandledChan   chan bool
-	SimCardInserted   bool
-	NowRinging        bool
-	urcChan           chan string
-	inCommand         bool
-	handlers          map[string]func(string)
-	SignalStrength    int
-	Carrier           string // i.e. T-Mobile, Verizon, AT&T, Fi
-	DataEnabled       bool
-	DataConnected     bool
-	NetworkGeneration string // 2g/3g/4g/negotiating
-	Connected         bool
-	gatheredRingData  bool
-	batteryWindow     []int
-	FlightMode        bool
-	SimulationMode    bool
-}
-
-func NewModem(port string, baud int, debug bool) (*Modem, error) {
+	callTable        map[int]*CallState
+	callTableMu      sync.Mutex
+	rings            int  // RINGs since the last call ended, guarded by callTableMu
+	dialing          bool // Whether Dial is waiting on ATD, guarded by callTableMu
+	AudioPort        *serial.Port
+	audioCmd         *exec.Cmd
+	DebugMode        bool
+	mu               sync.Mutex
+	cmdMutex         sync.Mutex      // One command at a time
+	pending          *pendingCommand // The command waiting for its final result, if any
+	conn             *connection     // The open port, nil while the modem is gone
+	lastHeard        time.Time       // When the modem last sent anything, for the watchdog
+	stateMu          sync.RWMutex
+	state            State // What State hands out, changed through updateState
+	subMu            sync.Mutex
+	subscribers      map[chan Event]struct{} // Channels handed out by Subscribe
+	USSDChan         chan *USSD
+	callLogMu        sync.Mutex
+	calls            map[int]*trackedCall // Calls in progress, by +CLCC index
+	smsEncoder       *sms.Encoder
+	smsCollector     *sms.Collector
+	NowRinging       bool
+	urcChan          chan string
+	handlers         map[string]func(string)
+	gatheredRingData bool
+	emergencyRadio   bool     // Whether DialEmergency took the radio out of airplane mode, guarded by stateMu
+	simECC           []string // The SIM's emergency numbers, guarded by stateMu
+	homeMCC          string   // Country the SIM comes from, for carrierEmergencyNumbers, guarded by stateMu
+	dst              int      // Daylight saving hours from the last +CTZE, guarded by mu
+	batteryWindow    []int
+	fixedNumbers     []string // The SIM's fixed dialing list while it's on, guarded by mu
+	postDial         string   // Tones to send once the outgoing call is answered, guarded by mu
+	toneGeneration   int      // Bumped by CancelPostDial to stop tones still being sent, guarded by mu
+	SimulationMode   bool
+}
+
+// Opens the modem's AT command serial port
+func OpenSerial(port string, baud int) (ModemTransport, error) {
 	cfg := &serial.Config{Name: port, Baud: baud, ReadTimeout: time.Second}
-	p, err := serial.OpenPort(cfg)
-	if err != nil {
-		return nil, err
-	}
+	return serial.OpenPort(cfg)
+}
 
+// Sets up a modem that isn't connected yet, see Run
+func newModem(debug bool) *Modem {
 	m := &Modem{
-		CallState:       &CallState{},
-		Carrier:         "Searching...",
-		Port:            p,
-		DataEnabled:     false,
-		DataConnected:   false, // stub - TODO: check if a wwan0 connection is alive
-		RingingChan:     make(chan bool, 1),
-		CallStartChan:   make(chan bool, 1),
-		CallEndChan:     make(chan bool, 1),
-		CallErrorChan:   make(chan bool, 1),
-		CallHandledChan: make(chan bool, 1),
-		urcChan:         make(chan string, 20),
-		DebugMode:       debug,
+		state:       State{Carrier: "Searching...", Fault: true},
+		subscribers: make(map[chan Event]struct{}),
+		callTable:   make(map[int]*CallState),
+		USSDChan:    make(chan *USSD, 1),
+		calls:       make(map[int]*trackedCall),
+		urcChan:     make(chan string, 20),
+		DebugMode:   debug,
+		smsEncoder:  sms.NewEncoder(sms.AsSubmit),
 	}
+	m.smsCollector = sms.NewCollector(sms.WithReassemblyTimeout(smsReassemblyTimeout, m.deliverSegments))
 
 	m.handlers = map[string]func(string){
-		"RING":  m.handleCall,
-		"+CMT:": m.handleSMSDirectly,
-		// "+CMTI:":       m.handleSMS,
+		"RING":         m.handleCall,
+		"+CMT:":        m.handleSMSDirectly,
+		"+CBM:":        m.handleCellBroadcast,
 		"+CSQ:":        m.handleSignalStrength,
 		"+CNSMOD:":     m.handleConnectionType,
 		"+SIMCARD:":    m.handleSIMCard,
 		"+CPIN:":       m.handleCPIN,
 		"+CCLK:":       m.handleClock,
+		"+CTZV:":       m.handleTimeZone,
+		"+CTZE:":       m.handleTimeZone,
 		"+CME ERROR:":  m.handleCMEError,
 		"+CMEE":        m.handleCMEE,
 		"+CLCC:":       m.handleCallStatus,
@@ -101,82 +122,123 @@ func NewModem(port string, baud int, debug bool) (*Modem, error) {
 		"+CREG:":       m.handleRegistrationUpdate,
 		"+CGREG:":      m.handleRegistrationUpdate,
 		"+CEREG:":      m.handleRegistrationUpdate,
+		"+CUSD:":       m.handleCUSD,
 	}
 
-	go m.listenLoop()
+	return m
+}
 
+// Runs the init sequence, on startup and whenever the modem reconnects
+func (m *Modem) initialize() {
 	// Initial setup sequence
 	initCmds := []string{
-		"AT+CFUN=1",                    // Enable
-		"ATE0",                         // Disable echo early to prevent polluted buffers
-		"AT+COPS?",                     // Check network status
-		"AT+CSQ",                       // Check signal strength
-		"AT+CSCLK=0",                   // Disable sleep mode during initialization
-		"AT+CATR=0",                    // Ensure URCs only go to the active port
-		"AT+AUTOCSQ=0,0",               // Disable early signal reports until ready
-		"AT+CLCC=1",                    // Call reporting
-		"AT+COUTGAIN=8",                // Set speaker gain
-		"AT+CMICGAIN=8",                // Set mic gain
-		"AT+CSMS=1",                    // Enable SMS (GSM Phase 2+)
-		"AT+CSCA=\"+19037029920\"",     // Set short code address for Verizon SMS
-		"AT+CMGF=1",                    // Set SMS text mode
-		"AT+CPMS=\"ME\",\"ME\",\"ME\"", // Set SMS storage to RAM
-		"AT+CNMI=2,2,0,0,0",            // Configure notifications
-		"AT+CNMP=2",                    // Automatic network mode
-		"AT+CNSMOD=1",                  // Network mode updates
-		"AT+CPCMFRM=1",                 // Configure 16 KHz audio mode
-		"AT+CREG=2",                    // Configure network registration
-		"AT+CEREG=2",                   // Configure network registration
-		"AT+CPIN?",                     // Check SIM card status
-		"AT+AUTOCSQ=1,1",               // Enable signal reports since we're ready
+		"AT+CFUN=1",      // Enable
+		"ATE0",           // Disable echo early to prevent polluted buffers
+		"AT+COPS?",       // Check network status
+		"AT+CSQ",         // Check signal strength
+		"AT+CSCLK=0",     // Disable sleep mode during initialization
+		"AT+CATR=0",      // Ensure URCs only go to the active port
+		"AT+AUTOCSQ=0,0", // Disable early signal reports until ready
+		"AT+CLCC=1",      // Call reporting
+		"AT+COUTGAIN=8",  // Set speaker gain
+		"AT+CMICGAIN=8",  // Set mic gain
+		"AT+CNSMOD=1",    // Network mode updates
+		"AT+CPCMFRM=1",   // Configure 16 KHz audio mode
+		"AT+CREG=2",      // Configure network registration
+		"AT+CEREG=2",     // Configure network registration
+		"AT+CTZU=1",      // Set the clock from network time
+		"AT+CTZR=2",      // Report time zone changes, with daylight saving
+		"AT+AUTOCSQ=1,1", // Enable signal reports since we're ready
+		"AT+CCLK?",       // Check the time, if the network already sent it
 	}
 	for _, cmd := range initCmds {
-		resp, _ := m.send(cmd)
-		m.HandleEvent(resp)
+		if resp, _ := m.Exec(context.Background(), Command{Text: cmd}); resp != nil {
+			m.HandleEvent(resp.String())
+		}
+	}
+
+	// Check SIM card status. A locked SIM is set up once the code has been
+	// entered.
+	if err := m.CheckSIM(); err == nil && m.State().SIMLock == "" {
+		m.initSIM()
 	}
 
-	return m, nil
+	// The SIM's emergency numbers can be read even while it's locked
+	if s := m.State(); s.SimCardInserted || s.SIMLock != "" {
+		m.loadEmergencyNumbers()
+	}
 }
 
-// async reader splits command results from events
-func (m *Modem) listenLoop() {
-	reader := bufio.NewReader(m.Port)
+// async reader splits command results from events. Returns once the port
+// stops working, and drops the connection.
+func (m *Modem) listenLoop(c *connection) {
+	reader := bufio.NewReader(c.port)
 
 	for {
-		line, err := reader.ReadString('\r')
+		line, err := readLine(reader)
 		if err != nil && err != io.EOF {
-			log.Println("🔌 Read error:", err)
-			continue
+			select {
+			case <-c.gone:
+			default:
+				log.Println("🔌 Read error:", err)
+			}
+			m.disconnect(c)
+			return
 		}
 		line = strings.TrimSpace(line)
 		if line == "" {
 			continue
 		}
 
-		m.mu.Lock()
-		if m.inCommand {
-			log.Println(line)
-			m.lastResponse.WriteString(line + "\n")
-			if line == "OK" || strings.Contains(line, "ERROR") || strings.Contains(line, "NO CARRIER") {
-				m.inCommand = false
-				m.cmdWait.Done()
-			}
-			m.mu.Unlock()
-		} else {
-			m.mu.Unlock()
-			log.Println(line)
-			if m.isUnsolicited(line) {
-				if strings.HasPrefix(line, "+CMT:") {
-					body, err := reader.ReadString('\r')
-					if err == nil {
-						cleanBody := strings.TrimSpace(body)
-						cleanBody = strings.ReplaceAll(cleanBody, "\n", "\r")
-						line = fmt.Sprintf("%s\r%s", line, cleanBody)
-					}
+		// USSD menus span several lines, keep reading up to the closing
+		// quote. The line breaks are kept as carriage returns.
+		if strings.HasPrefix(line, "+CUSD:") {
+			for strings.Count(line, `"`)%2 == 1 {
+				next, err := readLine(reader)
+				if err != nil {
+					break
 				}
-				m.urcChan <- line
+				line += "\r" + strings.TrimSpace(next)
 			}
 		}
+
+		// The modem echoes commands back, codes and all
+		log.Println(redact(line))
+
+		// Answers go to the command waiting for them, URCs to their handlers
+		m.mu.Lock()
+		m.lastHeard = time.Now()
+		answered := m.routeLine(line)
+		m.mu.Unlock()
+		if answered || !m.isUnsolicited(line) {
+			continue
+		}
+
+		if strings.HasPrefix(line, "+CMT:") || strings.HasPrefix(line, "+CBM:") {
+			body, err := reader.ReadString('\r')
+			if err == nil {
+				cleanBody := strings.TrimSpace(body)
+				cleanBody = strings.ReplaceAll(cleanBody, "\n", "\r")
+				line = fmt.Sprintf("%s\r%s", line, cleanBody)
+			}
+		}
+		m.urcChan <- line
+	}
+}
+
+// Reads up to the next carriage return, or up to the "> " prompt the modem
+// sends when it wants an SMS body, which has no line ending of its own
+func readLine(reader *bufio.Reader) (string, error) {
+	var sb strings.Builder
+	for {
+		b, err := reader.ReadByte()
+		if err != nil {
+			return sb.String(), err
+		}
+		sb.WriteByte(b)
+		if b == '\r' || (b == ' ' && strings.TrimSpace(sb.String()) == ">") {
+			return sb.String(), nil
+		}
 	}
 }
 
@@ -241,38 +303,63 @@ func (m *Modem) handleRegistrationUpdate(line string) {
 		}
 	}
 
-	// Update connected state based on stat
-	switch stat {
-	case 1, 5, 6, 7, 8:
-		m.Connected = true
-		actInt, _ := strconv.Atoi(act)
-		m.NetworkGeneration = mapActToGen(actInt)
-	case 0, 4:
-		m.Connected = false
-		m.SignalStrength = 0
-		m.NetworkGeneration = ""
-		m.Carrier = "No Service"
-	case 2:
-		m.Connected = false
-		m.NetworkGeneration = ""
-		m.SignalStrength = 0
-		m.Carrier = "Searching..."
-	}
+	// Update connected state based on stat. +CREG and +CEREG both report a
+	// denial, only tell about it once.
+	denied := false
+	state := m.updateState(func(s *State) {
+		switch stat {
+		case 1, 5, 6, 7, 8:
+			s.Connected = true
+			actInt, _ := strconv.Atoi(act)
+			s.NetworkGeneration = mapActToGen(actInt)
+		case 0, 4:
+			s.Connected = false
+			s.SignalStrength = 0
+			s.NetworkGeneration = ""
+			s.Carrier = "No Service"
+		case 2:
+			s.Connected = false
+			s.NetworkGeneration = ""
+			s.SignalStrength = 0
+			s.Carrier = "Searching..."
+		case 3:
+			s.Connected = false
+			s.NetworkGeneration = ""
+			s.SignalStrength = 0
+			s.Carrier = "No Service"
+		}
+
+		denied = stat == 3 && !s.RegDenied
+		s.RegDenied = stat == 3
+
+		// The area name belongs to the area, drop it when we leave
+		if lac != "" && ci != "" {
+			if lac != s.AreaCode {
+				s.AreaName = ""
+			}
+			s.AreaCode = lac
+			s.CellID = ci
+		}
+	})
+	m.publishRegistration(state, denied)
 
 	// Send AT+COPS? request
-	resp, _ := m.send("AT+COPS?")
-	m.HandleEvent(resp)
+	if resp, _ := m.Exec(context.Background(), Command{Text: "AT+COPS?"}); resp != nil {
+		m.HandleEvent(resp.String())
+	}
 }
 
 func (m *Modem) EnterNumber(key rune) {
-	m.send("AT+VTS=\"" + string(key) + "\"")
+	if _, err := m.Exec(context.Background(), Command{Text: "AT+VTS=\"" + string(key) + "\""}); err != nil {
+		log.Println("⚠️ Failed to send tone:", err)
+	}
 }
 
 func (m *Modem) isUnsolicited(line string) bool {
 	prefixes := []string{
-		"RING", "+CMT:", "+CMTI:", "+CSQ:", "+CLCC:", "+CCLK:", "+SIMCARD:",
+		"RING", "+CMT:", "+CMTI:", "+CBM:", "+CSQ:", "+CLCC:", "+CCLK:", "+SIMCARD:",
 		"+CPIN", "+CNSMOD:", "+CME ERROR:", "+CMEE", "MISSED_CALL:",
-		"NO CARRIER", "+CBC:", "+CREG:", "+CEREG:",
+		"NO CARRIER", "+CBC:", "+CREG:", "+CEREG:", "+CUSD:", "+CTZV:", "+CTZE:",
 	}
 	for _, p := range prefixes {
 		if strings.HasPrefix(line, p) {
@@ -282,70 +369,67 @@ func (m *Modem) isUnsolicited(line string) bool {
 	return false
 }
 
-func (m *Modem) send(cmd string) (string, error) {
-	m.cmdMutex.Lock()
-	defer m.cmdMutex.Unlock()
-
-	m.lastResponse.Reset()
-	m.inCommand = true
-	m.cmdWait = sync.WaitGroup{}
-	m.cmdWait.Add(1)
-
-	m.mu.Lock()
-	log.Println(cmd)
-	_, err := m.Port.Write([]byte(cmd + "\r"))
-	m.mu.Unlock()
+// convenience methods
 
+// Dial calls a number. Tones dialed after a pause or a wait in it are sent
+// once the call is answered, see SplitDialString.
+func (m *Modem) Dial(ctx context.Context, number string) error {
+	err := m.dial(ctx, number)
 	if err != nil {
-		return "", err
+		m.callFailed(err)
 	}
-
-	m.cmdWait.Wait()
-
-	resp := m.lastResponse.String()
-
-	// Trim newlines from response
-	resp = strings.TrimSuffix(resp, "\n")
-	resp = strings.TrimSuffix(resp, "\r")
-
-	return resp, nil
+	return err
 }
 
-func (m *Modem) send_no_wait(cmd string) error {
-	m.cmdMutex.Lock()
-	defer m.cmdMutex.Unlock()
-
+// Sends the ATD, without telling anyone if it fails
+func (m *Modem) dial(ctx context.Context, number string) error {
+	number, tones := SplitDialString(number)
 	m.mu.Lock()
-	m.lastResponse.Reset()
-	m.inCommand = true
-	log.Println(cmd)
-	_, err := m.Port.Write([]byte(cmd + "\r"))
+	m.postDial = tones
 	m.mu.Unlock()
 
-	m.inCommand = false
-	return err
-}
+	m.callTableMu.Lock()
+	m.dialing = true
+	m.callTableMu.Unlock()
 
-// convenience methods
-func (m *Modem) Dial(number string) error {
-	resp, err := m.send("ATD" + number + ";")
-	m.HandleEvent(resp)
-	if strings.Contains(resp, "ERROR") {
+	resp, err := m.Exec(ctx, Command{Text: "ATD" + number + ";"})
 
-		// Attempt state recovery
-		m.CallErrorChan <- true
-		<-m.CallHandledChan
+	m.callTableMu.Lock()
+	m.dialing = false
+	m.callTableMu.Unlock()
 
-		// End the call
-		m.CallEndChan <- true
+	// Only the lines, a NO CARRIER result would end the call a second time
+	if resp != nil {
+		m.HandleEvent(strings.Join(resp.Lines, "\n"))
+	}
+	if err != nil {
+		m.CancelPostDial()
+		if m.dialBarred(ctx, err) {
+			err = fmt.Errorf("%w: %w", ErrCallBarred, err)
+		}
 	}
-
 	return err
 }
+
+// Lets the call screens know a call never got going, and closes them unless
+// it was a new call during another. Either way they get one CallEnded.
+func (m *Modem) callFailed(err error) {
+	m.publish(CallEvent{Kind: CallFailed, Call: m.State().Call, Err: err})
+	if len(m.Calls()) == 0 {
+		m.publish(CallEvent{Kind: CallEnded, Call: m.State().Call})
+		return
+	}
+
+	// The failed call may not have been reported gone yet
+	m.syncCalls()
+}
 func (m *Modem) Answer() error {
 	if !m.SimulationMode {
-		resp, err := m.send("ATA")
-		m.HandleEvent(resp)
+		// NO CARRIER here means the caller gave up first
+		resp, err := m.Exec(context.Background(), Command{Text: "ATA"})
+		if resp != nil {
+			m.HandleEvent(resp.String())
+		}
 		return err
 	}
 
@@ -355,9 +439,12 @@ func (m *Modem) Answer() error {
 	return nil
 }
 func (m *Modem) Hangup() error {
+	m.hungUp(-1)
 	if !m.SimulationMode {
-		resp, err := m.send("AT+CHUP")
-		m.HandleEvent(resp)
+		resp, err := m.Exec(context.Background(), Command{Text: "AT+CHUP"})
+		if resp != nil {
+			m.HandleEvent(resp.String())
+		}
 		return err
 	}
 
@@ -366,48 +453,58 @@ func (m *Modem) Hangup() error {
 
 	return nil
 }
-func (m *Modem) MuteMic(b bool) error     { return m.toggle("AT+CMUT", b) }
+
+// MuteMic mutes or unmutes the microphone during calls, see State.MicMuted.
+func (m *Modem) MuteMic(b bool) error {
+	if err := m.toggle("AT+CMUT", b); err != nil {
+		return err
+	}
+	m.updateState(func(s *State) { s.MicMuted = b })
+	return nil
+}
 func (m *Modem) MuteSpeaker(b bool) error { return m.toggle("AT+VMUTE", b) }
 func (m *Modem) toggle(cmd string, b bool) error {
 	val := 0
 	if b {
 		val = 1
 	}
-	_, err := m.send(fmt.Sprintf("%s=%d", cmd, val))
+	_, err := m.Exec(context.Background(), Command{Text: fmt.Sprintf("%s=%d", cmd, val)})
 	return err
 }
-func (m *Modem) SendSMS(to, message string) error {
-	if _, err := m.send("AT+CMGF=1"); err != nil {
-		return err
-	}
-	if _, err := m.send(fmt.Sprintf("AT+CMGS=\"%s\"", to)); err != nil {
-		return err
-	}
-	_, err := m.send(message + string(rune(26))) // Ctrl+Z
-	return err
-}
-
 func (m *Modem) ToggleFlightMode() error {
-	if m.FlightMode {
-		resp, err := m.send("AT+CFUN=1")
-		m.HandleEvent(resp)
-		m.FlightMode = false
-		return err
+	if m.State().FlightMode {
+		resp, err := m.Exec(context.Background(), Command{Text: "AT+CFUN=1"})
+		if err != nil {
+			return err
+		}
+		m.HandleEvent(resp.String())
+		m.updateState(func(s *State) { s.FlightMode = false })
+		return nil
 	} else {
-		resp, err := m.send("AT+CFUN=0")
-		m.HandleEvent(resp)
-		m.FlightMode = true
-		m.SimCardInserted = false
-		return err
+		resp, err := m.Exec(context.Background(), Command{Text: "AT+CFUN=0"})
+		if err != nil {
+			return err
+		}
+		m.HandleEvent(resp.String())
+		state := m.updateState(func(s *State) {
+			s.FlightMode = true
+			s.SimCardInserted = false
+		})
+		m.publishSIM(state)
+		return nil
 	}
 }
 
 func (m *Modem) handleCarrierInfo(line string) {
 	// 1. Check for the "Searching" or "Deregistered" state first
 	if line == "+COPS: 0" || strings.Contains(line, ",,,") {
-		m.Carrier = "Searching..."
-		m.SignalStrength = 0
-		m.Connected = false
+		state := m.updateState(func(s *State) {
+			s.Carrier = "Searching..."
+			s.SignalStrength = 0
+			s.Connected = false
+		})
+		m.publishRegistration(state, false)
+		m.publish(SignalEvent{Strength: 0})
 		return
 	}
 
@@ -416,23 +513,24 @@ func (m *Modem) handleCarrierInfo(line string) {
 	re := regexp.MustCompile(`\+COPS:\s*\d+,\d+,"([^"]+)"(?:,(\d+))?`)
 	matches := re.FindStringSubmatch(line)
 
-	if len(matches) > 1 {
-		m.Carrier = matches[1]
-
-		// Trim suffix spaces
-		m.Carrier = strings.TrimSuffix(m.Carrier, " ")
+	state := m.updateState(func(s *State) {
+		if len(matches) > 1 {
+			// Trim suffix spaces
+			s.Carrier = strings.TrimSuffix(matches[1], " ")
 
-		m.Connected = true
+			s.Connected = true
 
-		// Map the Access Technology (matches[2])
-		if len(matches) > 2 && matches[2] != "" {
-			act, _ := strconv.Atoi(matches[2])
-			m.NetworkGeneration = mapActToGen(act)
+			// Map the Access Technology (matches[2])
+			if len(matches) > 2 && matches[2] != "" {
+				act, _ := strconv.Atoi(matches[2])
+				s.NetworkGeneration = mapActToGen(act)
+			}
 		}
-	}
+	})
+	m.publishRegistration(state, false)
 
 	if m.DebugMode {
-		log.Printf("📶 Carrier Update: %s (%s)", m.Carrier, m.NetworkGeneration)
+		log.Printf("📶 Carrier Update: %s (%s)", state.Carrier, state.NetworkGeneration)
 	}
 }
 
@@ -486,6 +584,20 @@ func (m *Modem) handleCall(_ string) {
 	if m.DebugMode {
 		log.Println("📞 Incoming call...")
 	}
+
+	// Count the rings, which automatic answer waits for. RING can come
+	// before the +CLCC for the call.
+	m.callTableMu.Lock()
+	defer m.callTableMu.Unlock()
+	m.rings++
+	for _, call := range m.callTable {
+		if call.Status == "incoming" {
+			call.Rings = m.rings
+		}
+	}
+	if foreground := m.foregroundCall(); foreground != nil {
+		m.updateState(func(s *State) { s.Call = *foreground })
+	}
 }
 
 func (m *Modem) handleMissedCall(line string) {
@@ -500,10 +612,7 @@ func (m *Modem) handleMissedCall(line string) {
 		log.Println("📞 Missed call (unknown details)...")
 	}
 
-	select {
-	case m.MissedCallChan <- true:
-	default:
-	}
+	m.publish(CallEvent{Kind: CallMissed})
 }
 
 func (m *Modem) handleSignalStrength(line string) {
@@ -514,22 +623,17 @@ func (m *Modem) handleSignalStrength(line string) {
 			log.Printf("📶 Signal strength: RSSI=%d, BER=%d", rssi, ber)
 		}
 
-		if rssi >= 0 && rssi <= 31 {
-			m.SignalStrength = min(rssi*8/31, 7)
-			m.Connected = true
-		} else {
-			m.SignalStrength = 0
-			m.Carrier = "No Service"
-			m.Connected = false
-		}
-
-		// Get network connection type
-		// network_type, _ := m.send("AT+CNSMOD?")
-		// go m.handlers["+CNSMOD:"](network_type)
-
-		// Get carrier status
-		// carrier_status, _ := m.send("AT+COPS?")
-		// go m.handlers["+COPS:"](carrier_status)
+		state := m.updateState(func(s *State) {
+			if rssi >= 0 && rssi <= 31 {
+				s.SignalStrength = min(rssi*8/31, 7)
+				s.Connected = true
+			} else {
+				s.SignalStrength = 0
+				s.Carrier = "No Service"
+				s.Connected = false
+			}
+		})
+		m.publish(SignalEvent{Strength: state.SignalStrength})
 	}
 }
 
@@ -548,88 +652,209 @@ func (m *Modem) handleCallStatus(line string) {
 	is_multiparty, _ := strconv.Atoi(matches[5])
 	call_number := matches[6]
 
-	call_statuses := map[int]string{
-		0: "active",
-		1: "held",
-		2: "dialing",  // Outbound
-		3: "ringing",  // Outbound
-		4: "incoming", // Inbound
-		5: "waiting",  // Inbound
-		6: "disconnected",
-	}
-
-	call_types := map[int]string{
-		0: "voice",
-		1: "data",
-		2: "fax",
-		9: "unknown",
-	}
-
 	if m.DebugMode {
 		log.Printf("☎️ Call status %s [Index: %d]", call_number, call_index_number)
 		log.Println("   Is this a conference call? ", is_multiparty == 1)
 		log.Println("   Was this call inbound? ", is_call_inbound == 1)
-		log.Printf("   Status of call: %s", call_statuses[call_status])
-		log.Printf("   Type of call: %s", call_types[call_type])
+		log.Printf("   Status of call: %s", callStatuses[call_status])
+		log.Printf("   Type of call: %s", callTypes[call_type])
 	}
 
-	m.CallState.Index = call_index_number
-	m.CallState.IsCallInbound = is_call_inbound == 1
-	m.CallState.IsConferenceCall = is_multiparty == 1
-	m.CallState.PhoneNumber = call_number
-	m.CallState.Status = call_statuses[call_status]
-	m.CallState.ConnectionType = call_types[call_type]
+	m.trackCall(call_index_number, is_call_inbound == 1, call_status, call_number)
 
+	state := CallState{
+		Index:            call_index_number,
+		IsCallInbound:    is_call_inbound == 1,
+		IsConferenceCall: is_multiparty == 1,
+		PhoneNumber:      call_number,
+		Status:           callStatuses[call_status],
+		ConnectionType:   callTypes[call_type],
+	}
+
+	// Update the call table, then pick which call the screens should show
+	m.callTableMu.Lock()
+	previous, known := m.callTable[call_index_number]
+	was_active := known && previous.Status == "active"
+	dialing := m.dialing
+	if call_status == 4 {
+		state.Rings = m.rings
+	}
 	switch call_status {
-	case 0:
-		if m.CallState.StartTime.IsZero() {
-			m.CallState.StartTime = time.Now()
+	case 0, 1: // active, held
+		if known && previous.PhoneNumber == call_number {
+			state.StartTime = previous.StartTime
 		}
-	case 2, 3, 4, 5:
-		m.CallState.StartTime = time.Time{}
+		if state.StartTime.IsZero() {
+			state.StartTime = time.Now()
+		}
+	}
+	if call_status == 6 {
+		delete(m.callTable, call_index_number)
+	} else {
+		m.callTable[call_index_number] = &state
 	}
+	remaining := len(m.callTable)
+	if remaining == 0 {
+		m.rings = 0
+	}
+	shown := state
+	if foreground := m.foregroundCall(); foreground != nil {
+		shown = *foreground
+	}
+	m.updateState(func(s *State) { s.Call = shown })
+	m.callTableMu.Unlock()
 
-	if is_call_inbound == 1 { // Handle inbound call state
+	// The first call opens the call screens and the last one closes them.
+	// Anything in between shows up in the call table.
+	switch call_status {
+	case 0: // active
+		if is_call_inbound == 1 && !was_active && remaining == 1 {
+			m.publish(CallEvent{Kind: CallStarted, Call: shown})
+			go m.InitPCMStream()
+		}
+		if is_call_inbound == 0 && !was_active {
+			go m.startPostDial()
+		}
 
-		switch call_status {
-		case 0: // active
-			m.CallStartChan <- true
+	case 2: // dialing
+		if remaining == 1 {
+			m.publish(CallEvent{Kind: CallStarted, Call: shown})
 			go m.InitPCMStream()
+		}
 
-		case 4: // incoming
-			m.RingingChan <- true
+	case 4: // incoming
+		if remaining == 1 {
+			m.publish(CallEvent{Kind: CallRinging, Call: shown})
+		}
+
+	case 5: // waiting
+		m.publish(CallEvent{Kind: CallWaiting, Call: state})
+
+	case 6: // disconnected
+		if remaining == 0 {
+			m.CancelPostDial()
 
-		case 6: // disconnected
-			m.CallEndChan <- true
+			// A Dial that fails closes the screens itself, after CallFailed
+			if !dialing {
+				m.publish(CallEvent{Kind: CallEnded, Call: state})
+			}
 			go m.EndPCMStream()
 			if m.SimulationMode {
 				m.SimulationMode = false
 			}
 		}
+	}
+}
 
-	} else { // Handle outbound call state
+var callStatuses = map[int]string{
+	0: "active",
+	1: "held",
+	2: "dialing",  // Outbound
+	3: "ringing",  // Outbound
+	4: "incoming", // Inbound
+	5: "waiting",  // Inbound
+	6: "disconnected",
+}
 
-		switch call_status {
+var callTypes = map[int]string{
+	0: "voice",
+	1: "data",
+	2: "fax",
+	9: "unknown",
+}
 
-		case 0: // active
+// The order calls are put in front of the user, most important first
+var callStatusPriority = map[string]int{
+	"active":   0,
+	"dialing":  1,
+	"ringing":  1,
+	"incoming": 2,
+	"held":     3,
+	"waiting":  4,
+}
 
-		case 2: // dialing
-			m.CallStartChan <- true
-			go m.InitPCMStream()
+// Picks the call the screens should be about. Must be called with
+// callTableMu held.
+func (m *Modem) foregroundCall() *CallState {
+	var best *CallState
+	for _, call := range m.callTable {
+		if best == nil || callStatusPriority[call.Status] < callStatusPriority[best.Status] ||
+			(callStatusPriority[call.Status] == callStatusPriority[best.Status] && call.Index < best.Index) {
+			best = call
+		}
+	}
+	return best
+}
 
-		case 6: // disconnected
-			m.CallEndChan <- true
-			go m.EndPCMStream()
-			if m.SimulationMode {
-				m.SimulationMode = false
+// Calls returns every call in progress, ordered by +CLCC index.
+func (m *Modem) Calls() []CallState {
+	m.callTableMu.Lock()
+	defer m.callTableMu.Unlock()
+
+	calls := make([]CallState, 0, len(m.callTable))
+	for _, call := range m.callTable {
+		calls = append(calls, *call)
+	}
+	sort.Slice(calls, func(i, j int) bool {
+		return calls[i].Index < calls[j].Index
+	})
+	return calls
+}
+
+// Asks the modem which calls are still up and drops the ones that aren't,
+// for when NO CARRIER doesn't say which call ended
+func (m *Modem) syncCalls() {
+	resp, err := m.Exec(context.Background(), Command{Text: "AT+CLCC"})
+	if err != nil {
+		return
+	}
+
+	present := make(map[int]bool)
+	for _, matches := range regexp.MustCompile(`\+CLCC:\s*(\d+),`).FindAllStringSubmatch(resp.String(), -1) {
+		index, _ := strconv.Atoi(matches[1])
+		present[index] = true
+	}
+
+	for _, call := range m.Calls() {
+		if !present[call.Index] {
+			inbound := 0
+			if call.IsCallInbound {
+				inbound = 1
 			}
+			m.handleCallStatus(fmt.Sprintf("+CLCC: %d,%d,6,0,0,\"%s\",129", call.Index, inbound, call.PhoneNumber))
 		}
 	}
 }
 
+// Sends an AT+CHLD call hold and multiparty command
+func (m *Modem) chld(ctx context.Context, arg string) error {
+	_, err := m.Exec(ctx, Command{Text: "AT+CHLD=" + arg})
+	return err
+}
+
+// HoldAndAccept puts the active call on hold and answers the waiting call,
+// or swaps the active and held calls.
+func (m *Modem) HoldAndAccept(ctx context.Context) error { return m.chld(ctx, "2") }
+
+// ReleaseAndAccept ends the active call and answers the waiting call, or
+// picks the held call back up.
+func (m *Modem) ReleaseAndAccept(ctx context.Context) error { return m.chld(ctx, "1") }
+
+// ReleaseHeld ends the held calls, or rejects the waiting call.
+func (m *Modem) ReleaseHeld(ctx context.Context) error { return m.chld(ctx, "0") }
+
+// ReleaseCall ends one call, by its +CLCC index.
+func (m *Modem) ReleaseCall(ctx context.Context, index int) error {
+	m.hungUp(index)
+	return m.chld(ctx, fmt.Sprintf("1%d", index))
+}
+
+// JoinConference joins the active and held calls into one conference call.
+func (m *Modem) JoinConference(ctx context.Context) error { return m.chld(ctx, "3") }
+
 func (m *Modem) InitPCMStream() {
 	<-time.After(100 * time.Millisecond)
-	resp, err := m.send("AT+CPCMREG=1")
+	resp, err := m.Exec(context.Background(), Command{Text: "AT+CPCMREG=1"})
 	if err == nil {
 		if m.DebugMode {
 			log.Printf("🚿 PCM Stream started! (%s)", resp)
@@ -672,7 +897,7 @@ func (m *Modem) EndPCMStream() {
 		m.audioCmd = nil
 	}
 	<-time.After(100 * time.Millisecond)
-	resp, err := m.send("AT+CPCMREG=0")
+	resp, err := m.Exec(context.Background(), Command{Text: "AT+CPCMREG=0"})
 	if err == nil {
 		if m.DebugMode {
 			log.Printf("🚿 PCM Stream stopped! (%s)", resp)
@@ -682,15 +907,6 @@ func (m *Modem) EndPCMStream() {
 	}
 }
 
-func (m *Modem) handleClock(line string) {
-	re := regexp.MustCompile(`\+CCLK:\s*"(\d{2})/(\d{2})/(\d{2}),(\d{2}):(\d{2}):(\d{2})\+(\d+)"`)
-	if matches := re.FindStringSubmatch(line); len(matches) == 8 {
-		if m.DebugMode {
-			log.Printf("🕒 Clock: %s/%s/%s %s:%s:%s (TZ +%s)", matches[1], matches[2], matches[3], matches[4], matches[5], matches[6], matches[7])
-		}
-	}
-}
-
 func (m *Modem) handleSMSDirectly(line string) {
 	parts := strings.Split(line, "\r")
 	if len(parts) < 2 {
@@ -700,6 +916,11 @@ func (m *Modem) handleSMSDirectly(line string) {
 	header := parts[0]
 	body := strings.Join(parts[1:], "\n")
 
+	if pduHeaderRegex.MatchString(header) {
+		m.handleSMSPDU(body)
+		return
+	}
+
 	re := regexp.MustCompile(`\+CMT:\s*"([^"]+)"`)
 	matches := re.FindStringSubmatch(header)
 	sender := "Unknown"
@@ -707,40 +928,42 @@ func (m *Modem) handleSMSDirectly(line string) {
 		sender = matches[1]
 	}
 
-	log.Printf("📩 New SMS from %s: %s", sender, autoDecodeSMS(body))
-}
-
-/* func (m *Modem) handleSMS(line string) {
-	if m.DebugMode {
-		log.Println("💡 New SMS:", line)
+	message := &SMS{
+		Sender:    sender,
+		Body:      autoDecodeSMS(body),
+		Timestamp: time.Now(),
 	}
-	parts := strings.Split(line, ",")
-	if len(parts) != 2 {
-		return
+
+	// Prefer the service centre's timestamp over our own clock
+	re = regexp.MustCompile(`"(\d{2}/\d{2}/\d{2},\d{2}:\d{2}:\d{2}[+-]\d{1,2})"`)
+	if matches := re.FindStringSubmatch(header); len(matches) > 1 {
+		if ts, err := parseModemTime(matches[1]); err == nil {
+			message.Timestamp = ts
+		}
 	}
-	index := strings.TrimSpace(parts[1])
 
-	// Wait a moment for the message to be fully written to memory
-	time.Sleep(500 * time.Millisecond)
+	log.Printf("📩 New SMS from %s: %s", message.Sender, message.Body)
+	m.publish(SMSEvent{Message: message})
+}
 
-	msg, _ := m.send("AT+CMGR=" + index)
+// Parses the "yy/MM/dd,hh:mm:ss±zz" timestamps used by +CMT and +CCLK, where
+// zz is the offset from UTC in quarter hours
+func parseModemTime(s string) (time.Time, error) {
+	if len(s) < 18 {
+		return time.Time{}, fmt.Errorf("timestamp too short: %q", s)
+	}
 
-	lines := strings.SplitSeq(msg, "\n")
-	for l := range lines {
-		l = strings.TrimSpace(l)
-		if l == "" || strings.HasPrefix(l, "+CMGR:") {
-			continue
-		}
-		if strings.HasPrefix(l, "OK") {
-			continue
-		}
-		if m.DebugMode {
-			log.Println("📩 SMS:", autoDecodeSMS(l))
-		}
+	quarters, err := strconv.Atoi(s[17:])
+	if err != nil {
+		return time.Time{}, fmt.Errorf("bad timezone in %q: %w", s, err)
+	}
+	if s[17] != '+' && s[17] != '-' {
+		return time.Time{}, fmt.Errorf("bad timezone in %q", s)
 	}
 
-	// m.send("AT+CMGD=" + index)
-}*/
+	zone := time.FixedZone("", quarters*15*60)
+	return time.ParseInLocation("06/01/02,15:04:05", s[:17], zone)
+}
 
 // unused but registered
 func (m *Modem) handleConnectionType(line string) {
@@ -773,17 +996,18 @@ func (m *Modem) handleConnectionType(line string) {
 
 	if len(matches) > 1 {
 		gen, _ := strconv.Atoi(matches[len(matches)-1])
-		if val, ok := knownGens[gen]; ok {
-			m.NetworkGeneration = val
-			if m.DebugMode {
-				log.Printf("📶 Connected to a %s network", val)
-			}
-			m.Connected = true
-		} else {
-			m.NetworkGeneration = ""
-			m.Carrier = "Searching..."
-			m.Connected = false
+		val, ok := knownGens[gen]
+		if ok && m.DebugMode {
+			log.Printf("📶 Connected to a %s network", val)
 		}
+		state := m.updateState(func(s *State) {
+			s.NetworkGeneration = val
+			s.Connected = ok
+			if !ok {
+				s.Carrier = "Searching..."
+			}
+		})
+		m.publishRegistration(state, false)
 	}
 }
 func (m *Modem) handleSIMCard(string) {}
@@ -793,17 +1017,24 @@ func (m *Modem) handleCPIN(line string) {
 		status := strings.TrimSpace(matches[1])
 		log.Printf("🔒 SIM Status: %s", status)
 
-		switch status {
-		case "READY":
-			m.SimCardInserted = true
-		// TODO: add the rest
-		case "SIM PIN":
-		case "SIM PUK":
-		case "PH-SIM PIN":
-		case "SIM PIN2":
-		case "SIM PUK2":
-		case "PH-NET PIN":
-		}
+		state := m.updateState(func(s *State) {
+			switch status {
+			case "READY":
+				s.SimCardInserted = true
+				s.SIMLock = ""
+			case SIMNeedsPIN, SIMNeedsPUK, SIMNeedsPhoneSIM, SIMNeedsPIN2, SIMNeedsPUK2, SIMNeedsNetwork:
+				// Nothing works until the code has been entered
+				s.SimCardInserted = false
+				s.SIMLock = status
+				s.Carrier = "SIM locked"
+			case "NOT INSERTED", "SIM REMOVED":
+				// The SIM7600 says SIM REMOVED when the card is pulled out
+				s.SimCardInserted = false
+				s.SIMLock = ""
+				s.Carrier = "Insert SIM card"
+			}
+		})
+		m.publishSIM(state)
 	}
 }
 func (m *Modem) handleCMEE(string) {}
@@ -818,9 +1049,12 @@ func (m *Modem) handleCMEError(line string) {
 
 		switch code {
 		case "10": // No SIM card
-			m.SimCardInserted = false
-			m.NetworkGeneration = ""
-			m.Carrier = "Insert SIM card"
+			state := m.updateState(func(s *State) {
+				s.SimCardInserted = false
+				s.NetworkGeneration = ""
+				s.Carrier = "Insert SIM card"
+			})
+			m.publishSIM(state)
 			log.Printf("🚫 No SIM card inserted!")
 		case "14": // SIM busy (ignore if we're starting up)
 			log.Println("⚠️ SIM card is busy...")
@@ -829,7 +1063,19 @@ func (m *Modem) handleCMEError(line string) {
 }
 
 func (m *Modem) handleNoCarrier(string) {
-	m.CallEndChan <- true
+	// With more than one call up, only the modem knows which one ended
+	if len(m.Calls()) > 1 {
+		m.syncCalls()
+		return
+	}
+
+	m.finishAllCalls()
+	m.callTableMu.Lock()
+	clear(m.callTable)
+	m.rings = 0
+	m.callTableMu.Unlock()
+	m.CancelPostDial()
+	m.publish(CallEvent{Kind: CallEnded, Call: m.State().Call})
 }
 
 // event listener
@@ -851,30 +1097,6 @@ func (m *Modem) HandleEvent(event string) {
 	}
 }
 
-func Run(debug bool) *Modem {
-	modem, err := NewModem("/dev/ttyUSB2", 115200, debug)
-	if err != nil {
-		log.Println(err)
-		return nil
-	}
-
-	go modem.MonitorEvents()
-
-	if debug {
-		log.Println("📡 Modem initialized and monitoring events")
-	}
-
-	/* go func() {
-		time.Sleep(8 * time.Second)
-
-		// Simulate an incoming call
-		modem.SimulationMode = true
-		modem.handleCallStatus("+CLCC: 1,1,4,0,0,\"TEST NUMBER\",0")
-	}() */
-
-	return modem
-}
-
 func (m *Modem) SwitchToPowerSaveMode() {
 	if m.DebugMode {
 		log.Println("📡 Modem switching to low power mode")
@@ -883,8 +1105,9 @@ func (m *Modem) SwitchToPowerSaveMode() {
 	for _, cmd := range []string{
 		"AT+AUTOCSQ=0,0",
 	} {
-		resp, _ := m.send(cmd)
-		m.HandleEvent(resp)
+		if resp, _ := m.Exec(context.Background(), Command{Text: cmd}); resp != nil {
+			m.HandleEvent(resp.String())
+		}
 	}
 }
 
@@ -897,7 +1120,8 @@ func (m *Modem) SwitchToNormalMode() {
 		"AT+COPS?",
 		"AT+AUTOCSQ=1,1",
 	} {
-		resp, _ := m.send(cmd)
-		m.HandleEvent(resp)
+		if resp, _ := m.Exec(context.Background(), Command{Text: cmd}); resp != nil {
+			m.HandleEvent(resp.String())
+		}
 	}
 }
@@ -0,0 +1,119 @@
+package phone
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// SIMContact is an entry in one of the SIM's phonebooks
+type SIMContact struct {
+	Index  int
+	Number string
+	Name   string
+}
+
+var (
+	cpbrRegex      = regexp.MustCompile(`\+CPBR:\s*(\d+),"([^"]*)",(\d+),"([^"]*)"`)
+	cpbrRangeRegex = regexp.MustCompile(`\+CPBR:\s*\((\d+)-(\d+)\),(\d+),(\d+)`)
+)
+
+// Selects a SIM phonebook and returns its first and last index and the
+// longest name it can hold. The fixed dialing list needs the PIN2 as the
+// password before it can be written, the others take none.
+func (m *Modem) selectPhonebook(ctx context.Context, storage, password string) (first, last, name_length int, err error) {
+	// Names are read and written as plain ASCII
+	if _, err = m.Exec(ctx, Command{Text: `AT+CSCS="IRA"`}); err != nil {
+		return
+	}
+
+	cmd := fmt.Sprintf(`AT+CPBS="%s"`, storage)
+	if password != "" {
+		cmd += fmt.Sprintf(`,"%s"`, password)
+	}
+	if _, err = m.Exec(ctx, Command{Text: cmd}); err != nil {
+		return
+	}
+
+	resp, err := m.Exec(ctx, Command{Text: "AT+CPBR=?"})
+	if err != nil {
+		return
+	}
+	matches := cpbrRangeRegex.FindStringSubmatch(resp.String())
+	if matches == nil {
+		err = fmt.Errorf("unexpected phonebook info: %s", resp)
+		return
+	}
+	first, _ = strconv.Atoi(matches[1])
+	last, _ = strconv.Atoi(matches[2])
+	name_length, _ = strconv.Atoi(matches[4])
+	return
+}
+
+// ReadSIMPhonebook reads every entry from a SIM phonebook: "SM" for the
+// contacts stored on the SIM, "SD" for the operator's service numbers or "FD"
+// for the fixed dialing list.
+func (m *Modem) ReadSIMPhonebook(ctx context.Context, storage string) ([]SIMContact, error) {
+	first, last, _, err := m.selectPhonebook(ctx, storage, "")
+	if err != nil {
+		return nil, err
+	}
+
+	// An empty phonebook answers with an error rather than no entries
+	resp, err := m.Exec(ctx, Command{Text: fmt.Sprintf("AT+CPBR=%d,%d", first, last)})
+	if resp == nil {
+		return nil, err
+	}
+
+	var contacts []SIMContact
+	for _, matches := range cpbrRegex.FindAllStringSubmatch(resp.String(), -1) {
+		index, _ := strconv.Atoi(matches[1])
+		number := matches[2]
+		if matches[3] == "145" && !strings.HasPrefix(number, "+") {
+			number = "+" + number
+		}
+		contacts = append(contacts, SIMContact{Index: index, Number: number, Name: matches[4]})
+	}
+	return contacts, nil
+}
+
+// WriteSIMContact stores a contact in the first free slot of the SIM
+// phonebook. Names are cut down to what the SIM can hold.
+func (m *Modem) WriteSIMContact(ctx context.Context, number, name string) error {
+	return m.writePhonebook(ctx, "SM", "", 0, number, name)
+}
+
+// Stores an entry in a SIM phonebook, in the slot at index or the first free
+// one if it's 0
+func (m *Modem) writePhonebook(ctx context.Context, storage, password string, index int, number, name string) error {
+	_, _, name_length, err := m.selectPhonebook(ctx, storage, password)
+	if err != nil {
+		return err
+	}
+
+	// Quotes would end the string early, and anything outside ASCII can't be
+	// written in IRA
+	name = strings.Map(func(r rune) rune {
+		if r == '"' || r > '~' {
+			return -1
+		}
+		return r
+	}, name)
+	if name_length > 0 && len(name) > name_length {
+		name = name[:name_length]
+	}
+
+	number_type := 129
+	if strings.HasPrefix(number, "+") {
+		number_type = 145
+	}
+
+	slot := ""
+	if index > 0 {
+		slot = strconv.Itoa(index)
+	}
+	_, err = m.Exec(ctx, Command{Text: fmt.Sprintf(`AT+CPBW=%s,"%s",%d,"%s"`, slot, number, number_type, name)})
+	return err
+}
@@ -0,0 +1,83 @@
+package phone
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// A scenario scripts what the Simulator does on its own, one step per line.
+// Blank lines and lines starting with # are ignored.
+//
+//	wait 5s                 pause before the next step
+//	send RING               emit a line (URC) to the modem
+//	expect ATA              block until the modem sends a command starting with ATA
+//	reply AT+COPS? +COPS: 0 answer a command with this line (before OK) from now on
+//	hang                    stop answering commands until AT+CRESET
+//	loop                    start the scenario over from the top
+//
+// A +CMT delivery is two send steps, the header and then the body.
+type ScenarioStep struct {
+	Line   int    // Line number in the scenario file, for errors
+	Action string // wait, send, expect, reply, hang or loop
+	Arg    string
+	Delay  time.Duration
+}
+
+// Reads a scenario file, see ScenarioStep for the syntax
+func LoadScenario(path string) ([]ScenarioStep, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseScenario(f)
+}
+
+func ParseScenario(r io.Reader) ([]ScenarioStep, error) {
+	var steps []ScenarioStep
+
+	scanner := bufio.NewScanner(r)
+	line_number := 0
+	for scanner.Scan() {
+		line_number++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		action, arg, _ := strings.Cut(line, " ")
+		arg = strings.TrimSpace(arg)
+		step := ScenarioStep{Line: line_number, Action: strings.ToLower(action), Arg: arg}
+
+		switch step.Action {
+		case "wait":
+			delay, err := time.ParseDuration(arg)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: bad wait duration %q", line_number, arg)
+			}
+			step.Delay = delay
+		case "send", "expect":
+			if arg == "" {
+				return nil, fmt.Errorf("line %d: %s needs an argument", line_number, step.Action)
+			}
+		case "reply":
+			if cmd, _, _ := strings.Cut(arg, " "); cmd == "" || cmd == arg {
+				return nil, fmt.Errorf("line %d: reply needs a command and a response", line_number)
+			}
+		case "hang", "loop":
+		default:
+			return nil, fmt.Errorf("line %d: unknown action %q", line_number, action)
+		}
+
+		steps = append(steps, step)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return steps, nil
+}
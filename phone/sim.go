@@ -0,0 +1,183 @@
+package phone
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// What a locked SIM is waiting for, as reported by AT+CPIN?
+const (
+	SIMNeedsPIN      = "SIM PIN"
+	SIMNeedsPUK      = "SIM PUK"
+	SIMNeedsPIN2     = "SIM PIN2"
+	SIMNeedsPUK2     = "SIM PUK2"
+	SIMNeedsPhoneSIM = "PH-SIM PIN"
+	SIMNeedsNetwork  = "PH-NET PIN"
+)
+
+// Codes that can be changed with ChangeCode
+const (
+	FacilitySIM  = "SC" // The PIN code, which also turns the PIN request on and off
+	FacilityPIN2 = "P2"
+)
+
+// CodeAttempts is how many tries each code has left before it's blocked
+type CodeAttempts struct {
+	PIN  int
+	PUK  int
+	PIN2 int
+	PUK2 int
+}
+
+var (
+	spicRegex  = regexp.MustCompile(`\+SPIC:\s*(\d+),(\d+),(\d+),(\d+)`)
+	cpinrRegex = regexp.MustCompile(`\+CPINR:\s*"?([^",]+)"?,(\d+)`)
+	clckRegex  = regexp.MustCompile(`\+CLCK:\s*(\d)`)
+)
+
+// Commands from the init sequence that only work once the SIM is unlocked
+var simInitCmds = []string{
+	"AT+CSMS=1",                    // Enable SMS (GSM Phase 2+)
+	"AT+CSCA=\"+19037029920\"",     // Set short code address for Verizon SMS
+	"AT+CMGF=0",                    // Set SMS PDU mode
+	"AT+CPMS=\"ME\",\"ME\",\"ME\"", // Set SMS storage to RAM
+	"AT+CNMI=2,2,2,0,0",            // Configure notifications, with cell broadcasts
+	"AT+COPS?",                     // Check network status
+}
+
+// Runs the setup that needed the SIM to be unlocked first
+func (m *Modem) initSIM() {
+	for _, cmd := range simInitCmds {
+		if resp, _ := m.Exec(context.Background(), Command{Text: cmd}); resp != nil {
+			m.HandleEvent(resp.String())
+		}
+	}
+	m.loadFixedDialing()
+}
+
+// Sends a command that takes a SIM code. A rejected code comes back as a
+// *CMEError, like 16 for a wrong password.
+func (m *Modem) sendCode(ctx context.Context, cmd string) error {
+	_, err := m.Exec(ctx, Command{Text: cmd})
+	return err
+}
+
+// CheckSIM asks the SIM whether it's ready or waiting for a code, which
+// updates SimCardInserted and SIMLock.
+func (m *Modem) CheckSIM() error {
+	resp, err := m.Exec(context.Background(), Command{Text: "AT+CPIN?"})
+	if resp == nil {
+		return err
+	}
+
+	// Handled here rather than by HandleEvent, so the state is up to date
+	// by the time this returns. Without a SIM it's +CME ERROR: 10.
+	if strings.Contains(resp.String(), "+CPIN:") {
+		m.handleCPIN(resp.String())
+	} else {
+		m.HandleEvent(resp.String())
+	}
+	return nil
+}
+
+// CodeAttempts asks the SIM how many tries are left for each code. AT+SPIC is
+// tried first, then the standard AT+CPINR.
+func (m *Modem) CodeAttempts(ctx context.Context) (*CodeAttempts, error) {
+	resp, err := m.Exec(ctx, Command{Text: "AT+SPIC"})
+	if resp == nil {
+		return nil, err
+	}
+	if matches := spicRegex.FindStringSubmatch(resp.String()); matches != nil {
+		attempts := &CodeAttempts{}
+		attempts.PIN, _ = strconv.Atoi(matches[1])
+		attempts.PUK, _ = strconv.Atoi(matches[2])
+		attempts.PIN2, _ = strconv.Atoi(matches[3])
+		attempts.PUK2, _ = strconv.Atoi(matches[4])
+		return attempts, nil
+	}
+
+	resp, err = m.Exec(ctx, Command{Text: "AT+CPINR"})
+	if err != nil {
+		return nil, err
+	}
+	all := cpinrRegex.FindAllStringSubmatch(resp.String(), -1)
+	if all == nil {
+		return nil, fmt.Errorf("unexpected code attempts: %s", resp)
+	}
+	attempts := &CodeAttempts{}
+	for _, matches := range all {
+		count, _ := strconv.Atoi(matches[2])
+		switch matches[1] {
+		case SIMNeedsPIN:
+			attempts.PIN = count
+		case SIMNeedsPUK:
+			attempts.PUK = count
+		case SIMNeedsPIN2:
+			attempts.PIN2 = count
+		case SIMNeedsPUK2:
+			attempts.PUK2 = count
+		}
+	}
+	return attempts, nil
+}
+
+// Checks the SIM again after a code was sent, and finishes setting up once it
+// has been unlocked
+func (m *Modem) afterCode() {
+	m.CheckSIM()
+	if m.State().SIMLock == "" {
+		m.initSIM()
+	}
+}
+
+// EnterPIN unlocks the SIM with the code it's asking for.
+func (m *Modem) EnterPIN(ctx context.Context, pin string) error {
+	err := m.sendCode(ctx, fmt.Sprintf(`AT+CPIN="%s"`, pin))
+	m.afterCode()
+	return err
+}
+
+// EnterPUK unblocks the SIM after the PIN was entered wrong too many times,
+// and sets a new PIN.
+func (m *Modem) EnterPUK(ctx context.Context, puk, pin string) error {
+	err := m.sendCode(ctx, fmt.Sprintf(`AT+CPIN="%s","%s"`, puk, pin))
+	m.afterCode()
+	return err
+}
+
+// PINRequest returns whether the SIM asks for the PIN when the phone starts.
+func (m *Modem) PINRequest(ctx context.Context) (bool, error) {
+	resp, err := m.Exec(ctx, Command{Text: `AT+CLCK="SC",2`})
+	if err != nil {
+		return false, err
+	}
+	matches := clckRegex.FindStringSubmatch(resp.String())
+	if matches == nil {
+		return false, fmt.Errorf("unexpected PIN request status: %s", resp)
+	}
+	return matches[1] == "1", nil
+}
+
+// SetPINRequest turns the PIN request on or off, which needs the PIN.
+func (m *Modem) SetPINRequest(ctx context.Context, enabled bool, pin string) error {
+	mode := 0
+	if enabled {
+		mode = 1
+	}
+	err := m.sendCode(ctx, fmt.Sprintf(`AT+CLCK="SC",%d,"%s"`, mode, pin))
+
+	// Too many wrong codes block the SIM
+	m.CheckSIM()
+	return err
+}
+
+// ChangeCode changes the PIN (FacilitySIM), PIN2 (FacilityPIN2) or the
+// network's barring password (BarAll).
+func (m *Modem) ChangeCode(ctx context.Context, facility, old_code, new_code string) error {
+	err := m.sendCode(ctx, fmt.Sprintf(`AT+CPWD="%s","%s","%s"`, facility, old_code, new_code))
+	m.CheckSIM()
+	return err
+}
@@ -0,0 +1,1145 @@
+package phone
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/warthog618/sms/encoding/ucs2"
+	"golang.org/x/sys/unix"
+)
+
+// Simulator pretends to be a SIM7600 on the far side of a pseudo-terminal, so
+// OpenSerial(sim.Port(), ...) gets a port that behaves like /dev/ttyUSB2. It
+// answers the init sequence, walks outgoing calls through dialing → alerting →
+// active (or busy, for simulatedBusyNumber), handles up to two calls with
+// AT+CHLD hold, swap and conference, keeps a small SIM phonebook, emergency
+// numbers, PIN codes, fixed dialing, call divert, call barring and closed
+// user group settings, answers a few USSD codes, lists a few operators to
+// pick from, describes the cell it's on and its neighbours, and plays a
+// scenario (see ScenarioStep) for everything the network would normally do
+// on its own. A hung modem comes back with AT+CRESET, like the real one after
+// a power cycle.
+type Simulator struct {
+	master *os.File
+	port   string
+	debug  bool
+
+	writeLock sync.Mutex // Keeps responses and URCs from interleaving
+
+	mu        sync.Mutex
+	responses map[string]string
+	echo      bool
+	hung      bool // Ignoring everything but AT+CRESET
+	smsPrompt bool
+	smsRef    int
+	cmtHeader string // +CMT or +CBM header waiting for its body
+	calls     []*simulatedCall
+	phonebook map[string][]SIMContact // SIM phonebooks by storage name
+	pbStorage string
+	pbUnlock  bool               // Whether AT+CPBS="FD" was given the PIN2, so the list can be written
+	diverts   map[int]DivertRule // Call forwarding set with AT+CCFC, by reason
+	barring   map[string]bool    // Calls barred with AT+CLCK, by facility
+	barringPW string             // The network's barring password
+	cug       CUGSetting         // Set with AT+CCUG
+	cause     string             // Why the last call failed, for AT+CEER
+	volume    int                // Call volume, set with AT+CLVL
+	sim       simulatedSIM
+	ussdMenu  string   // The USSD menu waiting for a reply, if any
+	seen      []string // Recent commands not yet matched by an expect step, at most maxSeen
+	notify    chan struct{}
+}
+
+type simulatedCall struct {
+	index      int
+	inbound    bool
+	status     int
+	multiparty bool
+	number     string
+}
+
+// The SIM's codes and how many tries each has left. Whether it's locked is
+// the AT+CPIN? reply, so a scenario can start with a locked SIM.
+type simulatedSIM struct {
+	pin          string
+	puk          string
+	pin2         string
+	pinTries     int
+	pukTries     int
+	pin2Tries    int
+	puk2Tries    int
+	pinRequest   bool
+	fixedDialing bool
+}
+
+var clccRegex = regexp.MustCompile(`\+CLCC:\s*(\d+),(\d+),(\d+),\d+,(\d+),"(.*?)"`)
+
+// How many unmatched commands the simulator remembers for expect steps. Pings
+// and status queries keep coming after a scenario's last expect, so older
+// ones are dropped.
+const maxSeen = 50
+
+// Opens a new pseudo-terminal pair and starts answering commands on it
+func NewSimulator(debug bool) (*Simulator, error) {
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR|unix.O_NOCTTY, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open pty: %w", err)
+	}
+
+	fd := int(master.Fd())
+	if err := unix.IoctlSetPointerInt(fd, unix.TIOCSPTLCK, 0); err != nil {
+		master.Close()
+		return nil, fmt.Errorf("failed to unlock pty: %w", err)
+	}
+	n, err := unix.IoctlGetInt(fd, unix.TIOCGPTN)
+	if err != nil {
+		master.Close()
+		return nil, fmt.Errorf("failed to get pty number: %w", err)
+	}
+
+	s := &Simulator{
+		master: master,
+		port:   fmt.Sprintf("/dev/pts/%d", n),
+		debug:  debug,
+		responses: map[string]string{
+			"AT+CPIN?":  "+CPIN: READY",
+			"AT+COPS?":  "+COPS: 0,0,\"Rakian\",7",
+			"AT+CSQ":    "+CSQ: 20,99",
+			"AT+CREG?":  "+CREG: 2,1",
+			"AT+CEREG?": "+CEREG: 2,1",
+			"AT+CGSN":   "864512040312087",
+			"AT+CIMI":   "001010123456789",
+			"AT+CPSI?":  "+CPSI: LTE,Online,001-01,0x1A2B,12812526,238,EUTRAN-BAND4,2175,5,5,-94,-985,-680,15",
+			"AT+CESQ":   "+CESQ: 99,99,255,255,22,43",
+
+			// EF_ECC holds 112 and 119
+			"AT+CRSM=178,28599,1,4,0": `+CRSM: 144,0,"11F2FFFFFFFF00"`,
+			"AT+CRSM=178,28599,2,4,0": `+CRSM: 144,0,"11F9FFFFFFFF00"`,
+			"AT+CRSM=178,28599,3,4,0": "+CRSM: 106,131",
+		},
+		phonebook: map[string][]SIMContact{
+			"SM": {
+				{Index: 1, Number: "+15550001111", Name: "Mom"},
+				{Index: 2, Number: "+15550002222", Name: "Work"},
+			},
+			"SD": {
+				{Index: 1, Number: "611", Name: "Customer Care"},
+				{Index: 2, Number: "*86", Name: "Voicemail"},
+				{Index: 3, Number: "411", Name: "Directory"},
+			},
+			"FD": {
+				{Index: 1, Number: "+1555", Name: "Area 555"},
+				{Index: 2, Number: "611", Name: "Customer Care"},
+			},
+		},
+		pbStorage: "SM",
+		diverts:   make(map[int]DivertRule),
+		barring:   make(map[string]bool),
+		barringPW: "0000",
+		cause:     "No cause information available",
+		volume:    4,
+		sim: simulatedSIM{
+			pin:       "1234",
+			puk:       "12345678",
+			pin2:      "5678",
+			pinTries:  3,
+			pukTries:  10,
+			pin2Tries: 3,
+			puk2Tries: 10,
+		},
+		echo:   true,
+		smsRef: 1,
+		notify: make(chan struct{}, 1),
+	}
+
+	go s.serve()
+
+	return s, nil
+}
+
+// Returns the path of the modem side of the pty
+func (s *Simulator) Port() string {
+	return s.port
+}
+
+func (s *Simulator) Close() error {
+	return s.master.Close()
+}
+
+// Play runs the scenario until it finishes or ctx is cancelled
+func (s *Simulator) Play(ctx context.Context, steps []ScenarioStep) error {
+	for i := 0; i < len(steps); i++ {
+		step := steps[i]
+		if s.debug {
+			log.Printf("🧪 Scenario line %d: %s %s", step.Line, step.Action, step.Arg)
+		}
+
+		switch step.Action {
+		case "wait":
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(step.Delay):
+			}
+		case "send":
+			s.Send(step.Arg)
+		case "expect":
+			if err := s.expect(ctx, step.Arg); err != nil {
+				return err
+			}
+		case "reply":
+			cmd, resp, _ := strings.Cut(step.Arg, " ")
+			s.mu.Lock()
+			s.responses[cmd] = strings.TrimSpace(resp)
+			s.mu.Unlock()
+		case "hang":
+			s.mu.Lock()
+			s.hung = true
+			s.mu.Unlock()
+		case "loop":
+			i = -1
+		}
+	}
+	return nil
+}
+
+// Send emits an unsolicited line to the modem. Lines that report state the
+// modem can also query (+CPIN, +CSQ, +COPS, +CLCC) update the simulator too,
+// so later queries and ATA/AT+CHUP agree with what was sent. A +CMT header is
+// held back and sent along with the line after it, which is the SMS body.
+func (s *Simulator) Send(line string) {
+	s.mu.Lock()
+
+	// A +CMT header and its body go out together, the way the modem sends them
+	if s.cmtHeader != "" {
+		header := s.cmtHeader
+		s.cmtHeader = ""
+		s.mu.Unlock()
+		s.write("\r\n" + header + "\r\n" + line + "\r\n")
+		return
+	}
+
+	switch {
+	case strings.HasPrefix(line, "+CMT:"), strings.HasPrefix(line, "+CBM:"):
+		s.cmtHeader = line
+		s.mu.Unlock()
+		return
+	case strings.HasPrefix(line, "+CPIN:"):
+		s.responses["AT+CPIN?"] = line
+	case strings.HasPrefix(line, "+CSQ:"):
+		s.responses["AT+CSQ"] = line
+	case strings.HasPrefix(line, "+COPS:"):
+		s.responses["AT+COPS?"] = line
+	case strings.HasPrefix(line, "+CLCC:"):
+		s.trackCall(line)
+	case line == "NO CARRIER" && len(s.calls) == 1:
+		s.calls = nil
+	}
+	s.mu.Unlock()
+
+	s.writeLines(line)
+}
+
+func (s *Simulator) trackCall(line string) {
+	matches := clccRegex.FindStringSubmatch(line)
+	if matches == nil {
+		return
+	}
+	call := &simulatedCall{number: matches[5], inbound: matches[2] == "1", multiparty: matches[4] == "1"}
+	fmt.Sscan(matches[1], &call.index)
+	fmt.Sscan(matches[3], &call.status)
+
+	for i, c := range s.calls {
+		if c.index == call.index {
+			if call.status == 6 {
+				s.calls = append(s.calls[:i], s.calls[i+1:]...)
+			} else {
+				s.calls[i] = call
+			}
+			return
+		}
+	}
+	if call.status != 6 {
+		s.calls = append(s.calls, call)
+	}
+}
+
+// Returns the first call in one of the statuses. Must be called with mu held.
+func (s *Simulator) findCall(statuses ...int) *simulatedCall {
+	for _, c := range s.calls {
+		if slices.Contains(statuses, c.status) {
+			return c
+		}
+	}
+	return nil
+}
+
+// Works out what AT+CHLD does to the calls and returns the +CLCC lines that
+// report it. The calls aren't changed until the lines are sent. Must be
+// called with mu held.
+func (s *Simulator) holdCommand(arg string) ([]string, bool) {
+	var lines []string
+	report := func(c *simulatedCall, status int, multiparty bool) {
+		changed := *c
+		changed.status = status
+		changed.multiparty = multiparty
+		lines = append(lines, changed.clcc())
+	}
+	waiting := s.findCall(5)
+
+	switch {
+	case arg == "0":
+		// Reject the waiting call, or end the held ones
+		if waiting != nil {
+			report(waiting, 6, false)
+			break
+		}
+		for _, c := range s.calls {
+			if c.status == 1 {
+				report(c, 6, false)
+			}
+		}
+
+	case arg == "1":
+		// End the active calls and take the waiting or held one
+		for _, c := range s.calls {
+			if c.status == 0 {
+				report(c, 6, false)
+			}
+		}
+		if waiting != nil {
+			report(waiting, 0, false)
+		} else if held := s.findCall(1); held != nil {
+			report(held, 0, held.multiparty)
+		}
+
+	case arg == "2":
+		// Hold the active calls and take the waiting or held ones
+		for _, c := range s.calls {
+			if c.status == 0 {
+				report(c, 1, c.multiparty)
+			}
+		}
+		if waiting != nil {
+			report(waiting, 0, false)
+		} else {
+			for _, c := range s.calls {
+				if c.status == 1 {
+					report(c, 0, c.multiparty)
+				}
+			}
+		}
+
+	case arg == "3":
+		// Join everything into a conference
+		if len(s.calls) < 2 {
+			return nil, false
+		}
+		for _, c := range s.calls {
+			if c.status == 0 || c.status == 1 {
+				report(c, 0, true)
+			}
+		}
+
+	case len(arg) > 1 && arg[0] == '1':
+		// End one call
+		index, _ := strconv.Atoi(arg[1:])
+		for _, c := range s.calls {
+			if c.index == index {
+				report(c, 6, false)
+			}
+		}
+
+	default:
+		return nil, false
+	}
+
+	return lines, len(lines) > 0
+}
+
+func (s *Simulator) expect(ctx context.Context, prefix string) error {
+	for {
+		s.mu.Lock()
+		for i, cmd := range s.seen {
+			if strings.HasPrefix(cmd, prefix) {
+				s.seen = s.seen[i+1:]
+				s.mu.Unlock()
+				return nil
+			}
+		}
+		s.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-s.notify:
+		}
+	}
+}
+
+// Reads commands from the modem until the pty is closed
+func (s *Simulator) serve() {
+	var pending bytes.Buffer
+	buf := make([]byte, 256)
+
+	for {
+		n, err := s.master.Read(buf)
+		if err != nil {
+			// EIO just means nothing has the port open right now
+			if errors.Is(err, os.ErrClosed) {
+				return
+			}
+			time.Sleep(100 * time.Millisecond)
+			continue
+		}
+
+		pending.Write(buf[:n])
+		for {
+			// Commands end with a carriage return, SMS bodies with Ctrl+Z
+			idx := bytes.IndexAny(pending.Bytes(), "\r\x1a")
+			if idx < 0 {
+				break
+			}
+			terminator := pending.Bytes()[idx]
+			text := string(pending.Next(idx + 1)[:idx])
+			s.handle(strings.TrimSpace(text), terminator)
+		}
+	}
+}
+
+func (s *Simulator) handle(cmd string, terminator byte) {
+	s.mu.Lock()
+
+	if s.smsPrompt {
+		if terminator == '\r' {
+			// Multi-line body, keep waiting for Ctrl+Z
+			s.mu.Unlock()
+			return
+		}
+		s.smsPrompt = false
+		ref := s.smsRef
+		s.smsRef++
+		s.mu.Unlock()
+
+		if s.debug {
+			log.Printf("🧪 Simulator sent SMS: %q", cmd)
+		}
+		s.writeLines(fmt.Sprintf("+CMGS: %d", ref), "OK")
+		return
+	}
+
+	if cmd == "" {
+		s.mu.Unlock()
+		return
+	}
+
+	if s.debug {
+		log.Println("🧪 Simulator got", cmd)
+	}
+
+	s.seen = append(s.seen, cmd)
+	if len(s.seen) > maxSeen {
+		s.seen = slices.Delete(s.seen, 0, len(s.seen)-maxSeen)
+	}
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+
+	upper := strings.ToUpper(cmd)
+	if s.hung {
+		if upper != "AT+CRESET" {
+			s.mu.Unlock()
+			return
+		}
+		s.hung = false
+		s.echo = true
+	}
+
+	echo := s.echo
+	var lines []string
+	final := "OK"
+	var after func()
+	var delay time.Duration // How long the modem takes to answer
+
+	switch {
+	case upper == "ATE0":
+		s.echo = false
+	case upper == "ATE1":
+		s.echo = true
+	case strings.HasPrefix(upper, "AT+CMGS="):
+		// The body follows the prompt, which has no line ending
+		s.smsPrompt = true
+		s.mu.Unlock()
+		if echo {
+			s.write(cmd + "\r\n")
+		}
+		s.write("\r\n> ")
+		return
+	case strings.HasPrefix(upper, "ATD"):
+		number := strings.TrimSuffix(cmd[3:], ";")
+		if s.findCall(2, 3, 4, 5) != nil || len(s.calls) >= 2 {
+			final = "ERROR"
+			break
+		}
+		if s.dialBarred(number) {
+			s.cause = "Call barred"
+			final = "NO CARRIER"
+			break
+		}
+
+		// A call that's already up goes on hold, like AT+CHLD=2
+		var held []string
+		for _, c := range s.calls {
+			changed := *c
+			changed.status = 1
+			held = append(held, changed.clcc())
+		}
+
+		index := 1
+		for s.hasCall(index) {
+			index++
+		}
+		s.calls = append(s.calls, &simulatedCall{index: index, status: 2, number: number})
+		after = func() {
+			for _, line := range held {
+				s.Send(line)
+			}
+			s.connectOutgoing(index, number)
+		}
+	case upper == "ATA":
+		call := s.findCall(4, 5)
+		if call == nil {
+			final = "NO CARRIER"
+			break
+		}
+		var changes []string
+		if call.status == 5 {
+			// Answering a waiting call holds the active one
+			changes, _ = s.holdCommand("2")
+		} else {
+			answered := *call
+			answered.status = 0
+			changes = []string{answered.clcc()}
+		}
+		after = func() {
+			for _, line := range changes {
+				s.Send(line)
+			}
+		}
+	case upper == "AT+CHUP":
+		var changes []string
+		for _, c := range s.calls {
+			ended := *c
+			ended.status = 6
+			changes = append(changes, ended.clcc())
+		}
+		after = func() {
+			for _, line := range changes {
+				s.Send(line)
+			}
+		}
+	case strings.HasPrefix(upper, "AT+CHLD="):
+		changes, ok := s.holdCommand(cmd[8:])
+		if !ok {
+			final = "+CME ERROR: operation not allowed"
+			break
+		}
+		after = func() {
+			for _, line := range changes {
+				s.Send(line)
+			}
+		}
+	case upper == "AT+CLCC":
+		for _, c := range s.calls {
+			lines = append(lines, c.clcc())
+		}
+	case strings.HasPrefix(upper, "AT+CPBS="):
+		storage, password, _ := strings.Cut(cmd[8:], ",")
+		storage = strings.Trim(storage, `"`)
+		if _, ok := s.phonebook[storage]; !ok {
+			final = "+CME ERROR: operation not supported"
+			break
+		}
+
+		// The PIN2 unlocks the fixed dialing list for writing
+		s.pbUnlock = false
+		if storage == FacilityFixedDialing && password != "" {
+			if !s.checkCode(strings.Trim(password, `"`), s.sim.pin2, &s.sim.pin2Tries) {
+				final = "+CME ERROR: incorrect password"
+				break
+			}
+			s.pbUnlock = true
+		}
+		s.pbStorage = storage
+	case strings.HasPrefix(upper, "AT+CPB"):
+		lines, final = s.phonebookCommand(cmd)
+	case upper == "AT+CPIN?" && (s.responses[cmd] == "+CPIN: SIM REMOVED" || s.responses[cmd] == "+CPIN: NOT INSERTED"):
+		// Without a card the modem fails the query rather than answering it
+		final = "+CME ERROR: 10"
+	case strings.HasPrefix(upper, "AT+CPIN="), upper == "AT+SPIC", upper == "AT+CPINR",
+		strings.HasPrefix(upper, "AT+CLCK="), strings.HasPrefix(upper, "AT+CPWD="):
+		lines, final = s.simCommand(cmd)
+	case strings.HasPrefix(upper, "AT+CCFC="):
+		lines, final = s.divertCommand(cmd[8:])
+	case upper == "AT+CCUG?":
+		enabled := 0
+		if s.cug.Enabled {
+			enabled = 1
+		}
+		lines = []string{fmt.Sprintf("+CCUG: %d,%d,0", enabled, s.cug.Index)}
+	case strings.HasPrefix(upper, "AT+CCUG="):
+		var enabled, index int
+		if n, _ := fmt.Sscanf(cmd[8:], "%d,%d", &enabled, &index); n == 0 || index < 0 || index > CUGPreferred {
+			final = "ERROR"
+			break
+		}
+		s.cug = CUGSetting{Enabled: enabled == 1, Index: index}
+	case upper == "AT+CLVL?":
+		lines = []string{fmt.Sprintf("+CLVL: %d", s.volume)}
+	case strings.HasPrefix(upper, "AT+CLVL="):
+		level, err := strconv.Atoi(cmd[8:])
+		if err != nil || level < 0 || level > MaxCallVolume {
+			final = "ERROR"
+			break
+		}
+		s.volume = level
+	case strings.HasPrefix(upper, "AT+VTS="):
+		// Tones only go out during a call
+		if s.findCall(0) == nil {
+			final = "ERROR"
+		}
+	case upper == "AT+CEER":
+		lines = []string{"+CEER: " + s.cause}
+	case upper == "AT+CCINFO":
+		lines = simulatedNeighbours
+	case upper == "AT+COPS=?":
+		lines = []string{simulatedOperators}
+		delay = 3 * time.Second
+	case strings.HasPrefix(upper, "AT+COPS=0"), strings.HasPrefix(upper, "AT+COPS=1,"):
+		final = s.selectOperator(cmd[8:])
+	case strings.HasPrefix(upper, "AT+CUSD="):
+		var reply string
+		reply, final = s.ussdCommand(cmd[8:])
+		if reply != "" {
+			after = func() {
+				time.Sleep(time.Second)
+				s.Send(reply)
+			}
+		}
+	default:
+		if resp, ok := s.responses[cmd]; ok {
+			lines = append(lines, resp)
+		}
+	}
+	lines = append(lines, final)
+	s.mu.Unlock()
+
+	time.Sleep(delay)
+	if echo {
+		s.write(cmd + "\r\n")
+	}
+	s.writeLines(lines...)
+
+	if after != nil {
+		go after()
+	}
+}
+
+// Answers AT+CPBR and AT+CPBW against the selected SIM phonebook. Must be
+// called with mu held.
+func (s *Simulator) phonebookCommand(cmd string) ([]string, string) {
+	const size = 250
+	entries := s.phonebook[s.pbStorage]
+
+	switch {
+	case cmd == "AT+CPBR=?":
+		return []string{fmt.Sprintf("+CPBR: (1-%d),40,14", size)}, "OK"
+
+	case strings.HasPrefix(cmd, "AT+CPBR="):
+		var first, last int
+		if n, _ := fmt.Sscanf(cmd, "AT+CPBR=%d,%d", &first, &last); n == 1 {
+			last = first
+		}
+		var lines []string
+		for _, c := range entries {
+			if c.Index >= first && c.Index <= last {
+				number_type := 129
+				if strings.HasPrefix(c.Number, "+") {
+					number_type = 145
+				}
+				lines = append(lines, fmt.Sprintf("+CPBR: %d,\"%s\",%d,\"%s\"", c.Index, c.Number, number_type, c.Name))
+			}
+		}
+		if len(lines) == 0 {
+			return nil, "+CME ERROR: not found"
+		}
+		return lines, "OK"
+
+	case strings.HasPrefix(cmd, "AT+CPBW="):
+		switch {
+		case s.pbStorage == FacilityFixedDialing && !s.pbUnlock:
+			return nil, "+CME ERROR: SIM PIN2 required"
+		case s.pbStorage != "SM" && s.pbStorage != FacilityFixedDialing:
+			return nil, "+CME ERROR: operation not allowed"
+		}
+		fields := strings.Split(cmd[8:], ",")
+		index, _ := strconv.Atoi(fields[0])
+
+		var kept []SIMContact
+		for _, c := range entries {
+			if c.Index != index {
+				kept = append(kept, c)
+			}
+		}
+		if len(fields) < 4 {
+			// No number given, so the entry is deleted
+			s.phonebook[s.pbStorage] = kept
+			return nil, "OK"
+		}
+
+		// No index given means the first free slot
+		if index == 0 {
+			used := make(map[int]bool)
+			for _, c := range entries {
+				used[c.Index] = true
+			}
+			for index = 1; used[index]; index++ {
+			}
+			if index > size {
+				return nil, "+CME ERROR: memory full"
+			}
+		}
+
+		contact := SIMContact{Index: index, Number: strings.Trim(fields[1], `"`), Name: strings.Trim(strings.Join(fields[3:], ","), `"`)}
+		s.phonebook[s.pbStorage] = append(kept, contact)
+		return nil, "OK"
+	}
+
+	return nil, "ERROR"
+}
+
+// Facilities the simulated network can bar calls for
+var simulatedBarrings = []string{BarAllOutgoing, BarOutgoingInternational, BarAllIncoming, BarIncomingRoaming, BarAll}
+
+// Whether call barring or fixed dialing stops a number being called, like
+// the network and SIM would. Emergency numbers always go through. Must be
+// called with mu held.
+func (s *Simulator) dialBarred(number string) bool {
+	if slices.Contains(defaultEmergencyNumbers, number) {
+		return false
+	}
+	if s.barring[BarAllOutgoing] {
+		return true
+	}
+
+	// The simulated SIM comes from the US, so anything else is international
+	international := strings.HasPrefix(number, "011") || (strings.HasPrefix(number, "+") && !strings.HasPrefix(number, "+1"))
+	if s.barring[BarOutgoingInternational] && international {
+		return true
+	}
+
+	if !s.sim.fixedDialing {
+		return false
+	}
+	for _, c := range s.phonebook[FacilityFixedDialing] {
+		if strings.HasPrefix(number, c.Number) {
+			return false
+		}
+	}
+	return true
+}
+
+// Checks a code against the SIM, counting down its tries. Must be called
+// with mu held.
+func (s *Simulator) checkCode(code, want string, tries *int) bool {
+	if *tries == 0 {
+		return false
+	}
+	if code != want {
+		*tries--
+		return false
+	}
+	*tries = 3
+	return true
+}
+
+// Answers the SIM code commands: AT+CPIN to unlock, AT+SPIC and AT+CPINR for
+// the tries left, AT+CLCK for the PIN request, fixed dialing and call barring,
+// and AT+CPWD to change a code or the barring password. Must be called with
+// mu held.
+func (s *Simulator) simCommand(cmd string) ([]string, string) {
+	const wrong = "+CME ERROR: incorrect password"
+	sim := &s.sim
+	status := strings.TrimSpace(strings.TrimPrefix(s.responses["AT+CPIN?"], "+CPIN:"))
+
+	_, params, _ := strings.Cut(cmd, "=")
+	var args []string
+	for _, arg := range strings.Split(params, ",") {
+		args = append(args, strings.Trim(arg, `"`))
+	}
+
+	// Out of PIN tries, the SIM wants the PUK
+	blockPIN := func() {
+		if sim.pinTries == 0 {
+			s.responses["AT+CPIN?"] = "+CPIN: " + SIMNeedsPUK
+		}
+	}
+
+	switch {
+	case strings.HasPrefix(cmd, "AT+CPIN="):
+		switch {
+		case status == SIMNeedsPIN:
+			if !s.checkCode(args[0], sim.pin, &sim.pinTries) {
+				blockPIN()
+				return nil, wrong
+			}
+		case status == SIMNeedsPUK && len(args) == 2:
+			if sim.pukTries == 0 || args[0] != sim.puk {
+				sim.pukTries = max(sim.pukTries-1, 0)
+				return nil, wrong
+			}
+			sim.pukTries = 10
+			sim.pinTries = 3
+			sim.pin = args[1]
+		default:
+			return nil, "+CME ERROR: operation not allowed"
+		}
+		sim.pinRequest = true
+		s.responses["AT+CPIN?"] = "+CPIN: READY"
+		return nil, "OK"
+
+	case cmd == "AT+SPIC":
+		return []string{fmt.Sprintf("+SPIC: %d,%d,%d,%d", sim.pinTries, sim.pukTries, sim.pin2Tries, sim.puk2Tries)}, "OK"
+
+	case cmd == "AT+CPINR":
+		return []string{
+			fmt.Sprintf("+CPINR: \"SIM PIN\",%d,3", sim.pinTries),
+			fmt.Sprintf("+CPINR: \"SIM PUK\",%d,10", sim.pukTries),
+			fmt.Sprintf("+CPINR: \"SIM PIN2\",%d,3", sim.pin2Tries),
+			fmt.Sprintf("+CPINR: \"SIM PUK2\",%d,10", sim.puk2Tries),
+		}, "OK"
+
+	case strings.HasPrefix(cmd, "AT+CLCK=") && len(args) >= 2 && args[0] == FacilityFixedDialing:
+		if args[1] == "2" {
+			enabled := 0
+			if sim.fixedDialing {
+				enabled = 1
+			}
+			return []string{fmt.Sprintf("+CLCK: %d", enabled)}, "OK"
+		}
+		if len(args) < 3 || !s.checkCode(args[2], sim.pin2, &sim.pin2Tries) {
+			return nil, wrong
+		}
+		sim.fixedDialing = args[1] == "1"
+		return nil, "OK"
+
+	case strings.HasPrefix(cmd, "AT+CLCK=") && len(args) >= 2 && slices.Contains(simulatedBarrings, args[0]):
+		if args[1] == "2" {
+			if s.barring[args[0]] {
+				return []string{"+CLCK: 1,1"}, "OK"
+			}
+			return []string{"+CLCK: 0,7"}, "OK"
+		}
+		if len(args) < 3 || args[2] != s.barringPW {
+			return nil, wrong
+		}
+		switch {
+		case args[0] == BarAll && args[1] == "0":
+			clear(s.barring)
+		case args[0] == BarAll:
+			return nil, "+CME ERROR: operation not allowed"
+		default:
+			s.barring[args[0]] = args[1] == "1"
+		}
+		return nil, "OK"
+
+	case strings.HasPrefix(cmd, "AT+CLCK="):
+		if len(args) < 2 || args[0] != FacilitySIM {
+			return nil, "+CME ERROR: operation not supported"
+		}
+		if args[1] == "2" {
+			request := 0
+			if sim.pinRequest {
+				request = 1
+			}
+			return []string{fmt.Sprintf("+CLCK: %d", request)}, "OK"
+		}
+		if len(args) < 3 || !s.checkCode(args[2], sim.pin, &sim.pinTries) {
+			blockPIN()
+			return nil, wrong
+		}
+		sim.pinRequest = args[1] == "1"
+		return nil, "OK"
+
+	case strings.HasPrefix(cmd, "AT+CPWD="):
+		if len(args) < 3 {
+			return nil, "ERROR"
+		}
+		switch args[0] {
+		case FacilitySIM:
+			if !s.checkCode(args[1], sim.pin, &sim.pinTries) {
+				blockPIN()
+				return nil, wrong
+			}
+			sim.pin = args[2]
+		case FacilityPIN2:
+			if !s.checkCode(args[1], sim.pin2, &sim.pin2Tries) {
+				return nil, wrong
+			}
+			sim.pin2 = args[2]
+		case BarAll:
+			if args[1] != s.barringPW {
+				return nil, wrong
+			}
+			s.barringPW = args[2]
+		default:
+			return nil, "+CME ERROR: operation not supported"
+		}
+		return nil, "OK"
+	}
+
+	return nil, "ERROR"
+}
+
+// Answers AT+CCFC queries, registrations and erasures for voice calls. Must
+// be called with mu held.
+func (s *Simulator) divertCommand(args string) ([]string, string) {
+	fields := strings.Split(args, ",")
+	if len(fields) < 2 {
+		return nil, "ERROR"
+	}
+	reason, _ := strconv.Atoi(fields[0])
+	mode, _ := strconv.Atoi(fields[1])
+
+	switch mode {
+	case 2:
+		rule, ok := s.diverts[reason]
+		if !ok {
+			return []string{"+CCFC: 0,7"}, "OK"
+		}
+		number_type := 129
+		if strings.HasPrefix(rule.Number, "+") {
+			number_type = 145
+		}
+		line := fmt.Sprintf("+CCFC: 1,1,\"%s\",%d", rule.Number, number_type)
+		if rule.Time > 0 {
+			line += fmt.Sprintf(",,,%d", rule.Time)
+		}
+		return []string{line}, "OK"
+
+	case 1, 3:
+		if reason > DivertNotReachable {
+			return nil, "+CME ERROR: operation not supported"
+		}
+		rule, ok := s.diverts[reason]
+		if len(fields) > 2 {
+			rule = DivertRule{Reason: reason, Number: strings.Trim(fields[2], `"`)}
+		} else if !ok {
+			// Enabling needs a number registered before
+			return nil, "+CME ERROR: operation not allowed"
+		}
+		if reason == DivertNoReply {
+			rule.Time = 20
+			if len(fields) > 7 {
+				rule.Time, _ = strconv.Atoi(fields[7])
+			}
+		}
+		rule.Active = true
+		s.diverts[reason] = rule
+		return nil, "OK"
+
+	case 0, 4:
+		switch reason {
+		case DivertAll:
+			clear(s.diverts)
+		case DivertAllConditional:
+			delete(s.diverts, DivertBusy)
+			delete(s.diverts, DivertNoReply)
+			delete(s.diverts, DivertNotReachable)
+		default:
+			delete(s.diverts, reason)
+		}
+		return nil, "OK"
+	}
+
+	return nil, "ERROR"
+}
+
+// The customer care menu behind *611#
+const simulatedUSSDMenu = "+CUSD: 1,\"Rakian Mobile\r\n1. Balance\r\n2. Data usage\r\n3. Exit\",15"
+
+var cusdCommandRegex = regexp.MustCompile(`^(\d)(?:,"([^"]*)"(?:,\d+)?)?$`)
+
+// Answers AT+CUSD requests and menu replies, returning the +CUSD report to
+// send once the network has "thought about it". *611# is an interactive menu,
+// and *225# answers in UCS2. Must be called with mu held.
+func (s *Simulator) ussdCommand(args string) (string, string) {
+	matches := cusdCommandRegex.FindStringSubmatch(args)
+	if matches == nil {
+		return "", "ERROR"
+	}
+
+	if matches[1] == "2" {
+		s.ussdMenu = ""
+		return "", "OK"
+	}
+	text := matches[2]
+
+	if s.ussdMenu != "" {
+		menu := s.ussdMenu
+		s.ussdMenu = "main"
+		switch {
+		case menu == "main" && text == "1":
+			s.ussdMenu = ""
+			return "+CUSD: 0,\"Your balance is $23.50. Your plan renews on the 1st.\",15", "OK"
+		case menu == "main" && text == "2":
+			s.ussdMenu = "data"
+			return "+CUSD: 1,\"1.2 GB of 5 GB used this month.\r\n0. Back\",15", "OK"
+		case menu == "main" && text == "3":
+			s.ussdMenu = ""
+			return "+CUSD: 2", "OK"
+		case menu == "data" && text == "0":
+			return simulatedUSSDMenu, "OK"
+		}
+		return strings.Replace(simulatedUSSDMenu, `"`, `"Invalid choice\r\n`, 1), "OK"
+	}
+
+	switch text {
+	case "*611#":
+		s.ussdMenu = "main"
+		return simulatedUSSDMenu, "OK"
+	case "*225#":
+		body := ucs2.Encode([]rune("Balance: €23.50"))
+		return fmt.Sprintf("+CUSD: 0,\"%X\",72", body), "OK"
+	}
+	return "+CUSD: 4", "OK"
+}
+
+// Networks in range: the home network, another one on 3G and a forbidden one
+const simulatedOperators = `+COPS: (2,"Rakian","Rakian","00101",7),(1,"Fictional Tel","FicTel","00102",2),(3,"Blocked Mobile","Blocked","00103",7),,(0,1,2,3,4),(0,1,2)`
+
+// Cells heard around the simulated one, for AT+CCINFO
+var simulatedNeighbours = []string{
+	"+CCINFO:[NCELL1],ARFCN:27,MCC:001,MNC:01,LAC:6699,ID:12401,BSIC:52,RXLev:-85dbm",
+	"+CCINFO:[NCELL2],ARFCN:31,MCC:001,MNC:01,LAC:6700,ID:12877,BSIC:17,RXLev:-97dbm",
+}
+
+var copsSelectRegex = regexp.MustCompile(`^1,2,"(\d+)"(?:,(\d+))?$`)
+
+// Answers AT+COPS=0 and AT+COPS=1 by registering on the network asked for,
+// unless it's forbidden. Must be called with mu held.
+func (s *Simulator) selectOperator(args string) string {
+	if args == "0" {
+		s.responses["AT+COPS?"] = "+COPS: 0,0,\"Rakian\",7"
+		return "OK"
+	}
+
+	matches := copsSelectRegex.FindStringSubmatch(args)
+	if matches == nil {
+		return "ERROR"
+	}
+	for _, op := range copsListRegex.FindAllStringSubmatch(simulatedOperators, -1) {
+		if op[4] != matches[1] {
+			continue
+		}
+		if op[1] == "3" {
+			return "+CME ERROR: network not allowed - emergency calls only"
+		}
+		s.responses["AT+COPS?"] = fmt.Sprintf("+COPS: 1,0,\"%s\",%s", op[2], op[5])
+		return "OK"
+	}
+	return "+CME ERROR: no network service"
+}
+
+// Must be called with mu held
+func (s *Simulator) hasCall(index int) bool {
+	for _, c := range s.calls {
+		if c.index == index {
+			return true
+		}
+	}
+	return false
+}
+
+// A number that's always busy, for trying automatic redial
+const simulatedBusyNumber = "+15550009999"
+
+// Walks an outgoing call to active, unless it's hung up along the way or the
+// other end is busy
+func (s *Simulator) connectOutgoing(index int, number string) {
+	statuses := []int{2, 3, 0}
+	if number == simulatedBusyNumber {
+		statuses = []int{2, 6}
+	}
+
+	for i, status := range statuses {
+		if i > 0 {
+			time.Sleep(2 * time.Second)
+		}
+
+		s.mu.Lock()
+		var call simulatedCall
+		found := false
+		for _, c := range s.calls {
+			if c.index == index && !c.inbound && c.number == number {
+				c.status = status
+				call = *c
+				found = true
+			}
+		}
+		if status == 6 {
+			s.cause = "User busy"
+		}
+		s.mu.Unlock()
+		if !found {
+			return
+		}
+
+		s.Send(call.clcc())
+		if status == 6 {
+			s.Send("BUSY")
+		}
+	}
+}
+
+func (c simulatedCall) clcc() string {
+	dir := 0
+	if c.inbound {
+		dir = 1
+	}
+	number_type := 129
+	if strings.HasPrefix(c.number, "+") {
+		number_type = 145
+	}
+	multiparty := 0
+	if c.multiparty {
+		multiparty = 1
+	}
+	return fmt.Sprintf("+CLCC: %d,%d,%d,0,%d,\"%s\",%d", c.index, dir, c.status, multiparty, c.number, number_type)
+}
+
+func (s *Simulator) writeLines(lines ...string) {
+	var sb strings.Builder
+	for _, line := range lines {
+		sb.WriteString("\r\n" + line + "\r\n")
+	}
+	s.write(sb.String())
+}
+
+func (s *Simulator) write(text string) {
+	s.writeLock.Lock()
+	defer s.writeLock.Unlock()
+	if _, err := s.master.WriteString(text); err != nil && s.debug {
+		log.Println("🧪 Simulator write failed:", err)
+	}
+}
@@ -0,0 +1,169 @@
+package phone
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/warthog618/sms"
+	"github.com/warthog618/sms/encoding/pdumode"
+	"github.com/warthog618/sms/encoding/tpdu"
+)
+
+// How long to wait for the rest of a multipart message before giving up and
+// delivering what arrived
+const smsReassemblyTimeout = 10 * time.Minute
+
+// In PDU mode +CMT carries an optional alpha and the TPDU length, with the
+// hex PDU on the following line
+var pduHeaderRegex = regexp.MustCompile(`^\+CMT:\s*(".*")?,\s*\d+$`)
+
+// Builds the destination address for a message. Numbers starting with + are
+// international, anything else (national numbers, short codes) goes out as is.
+func smsAddress(number string) tpdu.Address {
+	addr := tpdu.NewAddress()
+	addr.SetNumberingPlan(tpdu.NpISDN)
+	if strings.HasPrefix(number, "+") {
+		addr.SetTypeOfNumber(tpdu.TonInternational)
+		number = number[1:]
+	}
+	addr.Addr = number
+	return addr
+}
+
+// Encodes a message into the PDU mode strings for AT+CMGS, one per part. The
+// body is split into concatenated parts when it doesn't fit in one SMS, and
+// is sent as GSM 7-bit when possible and UCS-2 otherwise.
+func (m *Modem) encodeSMS(to, message string) ([]string, []int, error) {
+	tpdus, err := m.smsEncoder.Encode([]byte(message), sms.WithTemplateOption(tpdu.WithDA(smsAddress(to))))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var pdus []string
+	var lengths []int
+	for _, p := range tpdus {
+		b, err := p.MarshalBinary()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		// An empty SMSC address makes the modem use the one set with AT+CSCA
+		pdu := pdumode.PDU{TPDU: b}
+		s, err := pdu.MarshalHexString()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		pdus = append(pdus, s)
+		lengths = append(lengths, len(b))
+	}
+	return pdus, lengths, nil
+}
+
+// Handles a +CMT delivered in PDU mode. Parts of a multipart message are held
+// until the whole message has arrived.
+func (m *Modem) handleSMSPDU(body string) {
+	pdu, err := pdumode.UnmarshalHexString(strings.TrimSpace(body))
+	if err != nil {
+		log.Println("⚠️ Failed to read SMS PDU:", err)
+		return
+	}
+
+	t, err := sms.Unmarshal(pdu.TPDU)
+	if err != nil {
+		log.Println("⚠️ Failed to unmarshal SMS:", err)
+		return
+	}
+
+	segments, err := m.smsCollector.Collect(*t)
+	if err != nil {
+		log.Println("⚠️ Failed to collect SMS part:", err)
+		return
+	}
+	if segments == nil {
+		if count, seqno, _, ok := t.ConcatInfo(); ok && m.DebugMode {
+			log.Printf("📩 Received part %d of %d from %s", seqno, count, t.OA.Number())
+		}
+		return
+	}
+
+	m.deliverSegments(segments)
+}
+
+// Decodes a complete (or timed out) set of message parts and passes it on
+func (m *Modem) deliverSegments(segments []*tpdu.TPDU) {
+	var parts []*tpdu.TPDU
+	for _, s := range segments {
+		if s != nil {
+			parts = append(parts, s)
+		}
+	}
+	if len(parts) == 0 {
+		return
+	}
+
+	var body string
+	if len(parts) == len(segments) {
+		msg, err := sms.Decode(parts)
+		if err != nil {
+			log.Println("⚠️ Failed to decode SMS:", err)
+			return
+		}
+		body = string(msg)
+	} else {
+		// Decode what we have one part at a time and mark the gaps
+		log.Printf("⚠️ Multipart SMS incomplete (%d of %d parts)", len(parts), len(segments))
+		var texts []string
+		for _, s := range segments {
+			if s == nil {
+				texts = append(texts, "…")
+				continue
+			}
+			if msg, err := sms.Decode([]*tpdu.TPDU{s}); err == nil {
+				texts = append(texts, string(msg))
+			}
+		}
+		body = strings.Join(texts, "")
+	}
+
+	message := &SMS{
+		Sender:    parts[0].OA.Number(),
+		Body:      body,
+		Timestamp: parts[0].SCTS.Time,
+	}
+	if message.Timestamp.IsZero() {
+		message.Timestamp = time.Now()
+	}
+
+	log.Printf("📩 New SMS from %s: %s", message.Sender, message.Body)
+	m.publish(SMSEvent{Message: message})
+}
+
+// SendSMS sends a message in PDU mode, as one or more concatenated parts.
+func (m *Modem) SendSMS(ctx context.Context, to, message string) error {
+	pdus, lengths, err := m.encodeSMS(to, message)
+	if err != nil {
+		return fmt.Errorf("failed to encode message: %w", err)
+	}
+
+	if _, err := m.Exec(ctx, Command{Text: "AT+CMGF=0"}); err != nil {
+		return err
+	}
+
+	for i, pdu := range pdus {
+		if len(pdus) > 1 && m.DebugMode {
+			log.Printf("📩 Sending part %d of %d to %s", i+1, len(pdus), to)
+		}
+
+		// The PDU goes out once the modem prompts for it
+		cmd := Command{Text: fmt.Sprintf("AT+CMGS=%d", lengths[i]), Body: pdu}
+		if _, err := m.Exec(ctx, cmd); err != nil {
+			return fmt.Errorf("failed to send message: %w", err)
+		}
+	}
+	return nil
+}
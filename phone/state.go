@@ -0,0 +1,195 @@
+package phone
+
+import (
+	"log"
+	"time"
+)
+
+// State is a snapshot of what the modem last reported, see Modem.State
+type State struct {
+	SignalStrength    int    // 0 to 7
+	Carrier           string // i.e. T-Mobile, Verizon, AT&T, Fi
+	AreaName          string // From cell broadcast, see SetAreaInfo
+	AreaCode          string // LAC or TAC of the serving cell, in hex as +CREG gives it
+	CellID            string // Serving cell, in hex as +CREG gives it
+	NetworkGeneration string // 2g/3g/4g/negotiating
+	Connected         bool
+	RegDenied         bool   // Whether the network turned the SIM away
+	SimCardInserted   bool   // Whether there's an unlocked SIM
+	SIMLock           string // The code a locked SIM is waiting for, empty once it's unlocked
+	FixedDialing      bool   // Whether only numbers on the SIM's list can be called, see FixedDialingAllows
+	FlightMode        bool
+	DataEnabled       bool      // Whether the user turned cellular data on
+	DataConnected     bool      // Whether DataInterface is up, kept current by the caller
+	EmergencyCall     bool      // Whether the call in progress went through DialEmergency
+	PostDialWait      string    // Tones dialed after a wait, until SendPostDial or CancelPostDial
+	MicMuted          bool      // Whether the microphone is muted for calls, see MuteMic
+	Loudspeaker       bool      // Whether calls play through the loudspeaker, see SetLoudspeaker
+	Fault             bool      // Whether the modem is missing or not answering
+	Call              CallState // The call shown on screen, see Calls for all of them
+}
+
+// Event is something the modem reported, as delivered by Subscribe. It's one
+// of RegistrationEvent, SignalEvent, CallEvent, SMSEvent, SIMEvent,
+// HealthEvent or ClockEvent.
+type Event interface {
+	event()
+}
+
+// RegistrationEvent is a change in which network the modem is on
+type RegistrationEvent struct {
+	Connected         bool
+	Carrier           string
+	NetworkGeneration string
+	Denied            bool // Set on the first report of a denial only
+}
+
+// SignalEvent is a new signal strength, from 0 to 7
+type SignalEvent struct {
+	Strength int
+}
+
+// What happened to a call
+type CallEventKind int
+
+const (
+	CallRinging CallEventKind = iota // The first call is coming in
+	CallStarted                      // The first call is being dialed or was answered
+	CallWaiting                      // Another call is coming in during one
+	CallFailed                       // The modem wouldn't place the call
+	CallEnded                        // The last call is over
+	CallMissed                       // An incoming call wasn't answered
+	CallLogged                       // A call finished, see Record
+)
+
+// CallEvent is a call coming in, starting or ending. The call screens open on
+// the first call and close after the last one, the calls in between show up
+// in Calls.
+type CallEvent struct {
+	Kind   CallEventKind
+	Call   CallState
+	Record *CallRecord // For CallLogged, and CallMissed when it's known
+	Err    error       // Why a CallFailed call never got going, like ErrCallBarred
+}
+
+// SMSEvent is a text message that arrived
+type SMSEvent struct {
+	Message *SMS
+}
+
+// SIMEvent is a change in whether the SIM is there and locked
+type SIMEvent struct {
+	Inserted bool
+	Lock     string
+}
+
+// HealthEvent is the modem going away or stopping answering, or coming back
+// and being set up again
+type HealthEvent struct {
+	Fault bool
+}
+
+// ClockEvent is the time the network sent, in the time zone it sent. DST is
+// how many hours of daylight saving that zone includes, if the network said.
+type ClockEvent struct {
+	Time time.Time
+	DST  int
+}
+
+func (RegistrationEvent) event() {}
+func (SignalEvent) event()       {}
+func (CallEvent) event()         {}
+func (SMSEvent) event()          {}
+func (SIMEvent) event()          {}
+func (HealthEvent) event()       {}
+func (ClockEvent) event()        {}
+
+// How many events a subscriber can fall behind by before they're dropped
+const subscriberBuffer = 32
+
+// How long a call event waits for a subscriber that's fallen behind. Losing
+// one would leave the call screens open or the call out of the register.
+const callEventWait = 10 * time.Second
+
+// State returns a snapshot of the modem's state, safe to read from any
+// goroutine.
+func (m *Modem) State() State {
+	m.stateMu.RLock()
+	defer m.stateMu.RUnlock()
+	return m.state
+}
+
+// Changes the state under the lock and returns the result
+func (m *Modem) updateState(update func(s *State)) State {
+	m.stateMu.Lock()
+	defer m.stateMu.Unlock()
+	update(&m.state)
+	return m.state
+}
+
+// SetDataEnabled records whether the user turned cellular data on.
+func (m *Modem) SetDataEnabled(enabled bool) {
+	m.updateState(func(s *State) { s.DataEnabled = enabled })
+}
+
+// SetDataConnected records whether DataInterface is up.
+func (m *Modem) SetDataConnected(connected bool) {
+	m.updateState(func(s *State) { s.DataConnected = connected })
+}
+
+// Subscribe returns a channel that gets every event from now on, and a
+// function that stops them. Events are dropped if the channel falls too far
+// behind, so the modem never waits on a slow subscriber.
+func (m *Modem) Subscribe() (<-chan Event, func()) {
+	ch := make(chan Event, subscriberBuffer)
+
+	m.subMu.Lock()
+	m.subscribers[ch] = struct{}{}
+	m.subMu.Unlock()
+
+	return ch, func() {
+		m.subMu.Lock()
+		delete(m.subscribers, ch)
+		m.subMu.Unlock()
+	}
+}
+
+// Hands an event to every subscriber. Call events wait for room, everything
+// else is dropped if a subscriber is too far behind.
+func (m *Modem) publish(e Event) {
+	m.subMu.Lock()
+	defer m.subMu.Unlock()
+
+	_, isCall := e.(CallEvent)
+	for ch := range m.subscribers {
+		select {
+		case ch <- e:
+			continue
+		default:
+		}
+
+		if isCall {
+			select {
+			case ch <- e:
+				continue
+			case <-time.After(callEventWait):
+			}
+		}
+		log.Printf("⚠️ Subscriber too slow, dropping %T", e)
+	}
+}
+
+// Publishes the registration part of the state
+func (m *Modem) publishRegistration(s State, denied bool) {
+	m.publish(RegistrationEvent{
+		Connected:         s.Connected,
+		Carrier:           s.Carrier,
+		NetworkGeneration: s.NetworkGeneration,
+		Denied:            denied,
+	})
+}
+
+// Publishes the SIM part of the state
+func (m *Modem) publishSIM(s State) {
+	m.publish(SIMEvent{Inserted: s.SimCardInserted, Lock: s.SIMLock})
+}
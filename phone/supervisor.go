@@ -0,0 +1,264 @@
+package phone
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	reconnectInterval = 2 * time.Second  // How often the supervisor looks at the modem
+	watchdogInterval  = 30 * time.Second // How long the modem can stay quiet before it's pinged
+	watchdogTimeout   = 5 * time.Second  // How long a ping may take
+	watchdogFailures  = 3                // Missed pings before the modem is reset
+)
+
+// Device is where the modem is plugged in. The supervisor opens it whenever
+// the modem isn't connected.
+type Device interface {
+	Open() (ModemTransport, error)
+	Present() bool // Whether the modem is still plugged in
+}
+
+// SerialDevice is a modem on a USB serial port, like /dev/ttyUSB2, which
+// disappears when the modem is unplugged or resets.
+type SerialDevice struct {
+	Path string
+	Baud int
+}
+
+func (d SerialDevice) Open() (ModemTransport, error) {
+	return OpenSerial(d.Path, d.Baud)
+}
+
+func (d SerialDevice) Present() bool {
+	_, err := os.Stat(d.Path)
+	return err == nil
+}
+
+// StaticDevice wraps a transport that's already open, like MemoryTransport or
+// the simulator's port. It can't be opened again once it's lost.
+func StaticDevice(transport ModemTransport) Device {
+	return &staticDevice{transport: transport}
+}
+
+type staticDevice struct {
+	transport ModemTransport
+	opened    bool
+}
+
+func (d *staticDevice) Open() (ModemTransport, error) {
+	if d.opened {
+		return nil, errors.New("modem can't be reopened")
+	}
+	d.opened = true
+	return d.transport, nil
+}
+
+func (d *staticDevice) Present() bool { return true }
+
+// An open port to the modem
+type connection struct {
+	port  ModemTransport
+	gone  chan struct{} // Closed once the port has been dropped
+	once  sync.Once
+	ready bool // Whether the init sequence has run, only touched by the supervisor
+}
+
+// Run sets up the modem and starts the supervisor, which connects to it
+// whenever it shows up, pings it when it's been quiet and resets it with
+// AT+CRESET when it stops answering. Returns once the modem has been set up,
+// or straight away if it isn't there yet.
+func Run(ctx context.Context, device Device, debug bool) *Modem {
+	m := newModem(debug)
+	go m.MonitorEvents()
+
+	var c *connection
+	if port, err := device.Open(); err != nil {
+		log.Println("⚠️ Failed to open modem:", err)
+	} else {
+		c = m.connect(port)
+		m.start(c)
+	}
+
+	go m.supervise(ctx, device, c)
+
+	if debug {
+		log.Println("📡 Modem initialized and monitoring events")
+	}
+
+	return m
+}
+
+// Keeps the modem connected and answering, see Run
+func (m *Modem) supervise(ctx context.Context, device Device, c *connection) {
+	ticker := time.NewTicker(reconnectInterval)
+	defer ticker.Stop()
+
+	failures := 0
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if c != nil {
+			select {
+			case <-c.gone:
+				c = nil
+			default:
+			}
+		}
+
+		// Wait for the modem to come back
+		if c == nil {
+			if !device.Present() {
+				continue
+			}
+			port, err := device.Open()
+			if err != nil {
+				if m.DebugMode {
+					log.Println("⚠️ Failed to open modem:", err)
+				}
+				continue
+			}
+			log.Println("🔌 Modem connected")
+			c = m.connect(port)
+			failures = 0
+		}
+
+		if !device.Present() {
+			log.Println("🔌 Modem unplugged")
+			m.disconnect(c)
+			c = nil
+			continue
+		}
+
+		// Set it up once it answers, then only ping it when it's gone quiet
+		if c.ready {
+			if !m.quietFor(watchdogInterval) {
+				continue
+			}
+			if m.ping() {
+				failures = 0
+				m.setFault(false)
+				continue
+			}
+			m.setFault(true)
+		} else if m.start(c) {
+			failures = 0
+			continue
+		}
+
+		failures++
+		log.Printf("⚠️ Modem isn't answering (%d/%d)", failures, watchdogFailures)
+		if failures < watchdogFailures {
+			continue
+		}
+
+		// Power cycle it, it shows up again once it has restarted
+		log.Println("🔌 Resetting the modem")
+		m.Exec(ctx, Command{Text: "AT+CRESET", Timeout: watchdogTimeout})
+		m.disconnect(c)
+		c = nil
+		failures = 0
+	}
+}
+
+// Starts using a port that was just opened
+func (m *Modem) connect(port ModemTransport) *connection {
+	c := &connection{port: port, gone: make(chan struct{})}
+
+	m.mu.Lock()
+	m.conn = c
+	m.lastHeard = time.Now()
+	m.mu.Unlock()
+
+	go m.listenLoop(c)
+	return c
+}
+
+// Runs the init sequence if the modem answers. Returns false if it didn't.
+func (m *Modem) start(c *connection) bool {
+	if !m.ping() {
+		return false
+	}
+
+	m.initialize()
+	c.ready = true
+	m.setFault(false)
+	return true
+}
+
+// Drops a port that stopped working, and ends whatever was going on over it
+func (m *Modem) disconnect(c *connection) {
+	c.once.Do(func() {
+		m.mu.Lock()
+		if m.conn == c {
+			m.conn = nil
+			m.pending = nil
+		}
+		m.mu.Unlock()
+
+		close(c.gone)
+		c.port.Close()
+		log.Println("🔌 Modem disconnected")
+
+		// Calls don't survive the modem going away
+		if len(m.Calls()) > 0 {
+			m.finishAllCalls()
+			m.callTableMu.Lock()
+			clear(m.callTable)
+			m.rings = 0
+			m.callTableMu.Unlock()
+			m.CancelPostDial()
+			m.publish(CallEvent{Kind: CallEnded, Call: m.State().Call})
+		}
+
+		state := m.updateState(func(s *State) {
+			s.Connected = false
+			s.SignalStrength = 0
+			s.NetworkGeneration = ""
+			s.Carrier = "No Service"
+			s.AreaName = ""
+			s.SimCardInserted = false
+			s.SIMLock = ""
+		})
+		m.publishRegistration(state, false)
+		m.publishSIM(state)
+		m.setFault(true)
+	})
+}
+
+// Reports the modem going away or coming back, if that's news
+func (m *Modem) setFault(fault bool) {
+	changed := false
+	m.updateState(func(s *State) {
+		changed = s.Fault != fault
+		s.Fault = fault
+	})
+	if changed {
+		m.publish(HealthEvent{Fault: fault})
+	}
+}
+
+// Whether the modem hasn't sent anything for a while, and isn't busy with a
+// command
+func (m *Modem) quietFor(d time.Duration) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.pending == nil && time.Since(m.lastHeard) >= d
+}
+
+// Checks the modem answers a plain AT
+func (m *Modem) ping() bool {
+	_, err := m.Exec(context.Background(), Command{Text: "AT", Timeout: watchdogTimeout})
+	if err != nil && m.DebugMode {
+		log.Println("⚠️ Modem ping failed:", err)
+	}
+	return err == nil
+}
@@ -0,0 +1,180 @@
+package phone
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Session states reported by +CUSD
+const (
+	USSDDone        = 0 // The network doesn't want a reply
+	USSDReply       = 1 // The network is waiting for a reply, like a menu choice
+	USSDTerminated  = 2 // The network ended the session
+	USSDOtherClient = 3 // Another client on the modem answered
+	USSDUnsupported = 4 // The network doesn't support the request
+	USSDTimeout     = 5 // The network stopped waiting for a reply
+)
+
+// USSD is a message from the network in a USSD session
+type USSD struct {
+	Status  int
+	Message string
+}
+
+// Code the phone answers itself instead of sending it to the network
+const IMEICode = "*#06#"
+
+var (
+	// Codes start with * or # and end with #, like *611# or *#06#
+	ussdCodeRegex = regexp.MustCompile(`^[*#][0-9*#]*#$`)
+	cusdRegex     = regexp.MustCompile(`\+CUSD:\s*(\d)(?:,"(.*?)"(?:,(\d+))?)?`)
+	imeiRegex     = regexp.MustCompile(`\b\d{15}\b`)
+)
+
+// How the text of a USSD message is encoded
+const (
+	ussdGSM7 = iota
+	ussd8Bit
+	ussdUCS2
+)
+
+// IsUSSD returns whether a dialed string is a USSD/MMI code rather than a
+// number to call.
+func IsUSSD(number string) bool {
+	return ussdCodeRegex.MatchString(number)
+}
+
+// Works out the alphabet from a CBS data coding scheme (3GPP TS 23.038),
+// which USSD uses too. Returns whether the text starts with a language.
+func ussdAlphabet(dcs int) (int, bool) {
+	switch {
+	case dcs == 0x10:
+		return ussdGSM7, true
+	case dcs == 0x11:
+		return ussdUCS2, true
+	case dcs&0xF0 <= 0x30:
+		return ussdGSM7, false
+	case dcs&0xC0 == 0x40, dcs&0xF0 == 0x90:
+		switch (dcs >> 2) & 0x03 {
+		case 1:
+			return ussd8Bit, false
+		case 2:
+			return ussdUCS2, false
+		}
+	case dcs&0xF0 == 0xF0:
+		if dcs&0x04 != 0 {
+			return ussd8Bit, false
+		}
+	}
+	return ussdGSM7, false
+}
+
+// Decodes the text of a +CUSD report. The modem converts GSM 7-bit text to
+// its character set itself, but 8-bit and UCS2 text arrive as hex.
+func decodeUSSD(text string, dcs int) string {
+	alphabet, language := ussdAlphabet(dcs)
+
+	switch alphabet {
+	case ussdUCS2:
+		if !isLikelyHexUCS2(text) {
+			return text
+		}
+		// The language is packed into the first two octets
+		if language && len(text) >= 4 {
+			text = text[4:]
+		}
+		decoded, err := decodeUCS2(text)
+		if err != nil {
+			log.Println("⚠️ Failed to decode USSD message:", err)
+			return text
+		}
+		return decoded
+
+	case ussd8Bit:
+		raw, err := hex.DecodeString(text)
+		if err != nil {
+			return text
+		}
+		runes := make([]rune, len(raw))
+		for i, b := range raw {
+			runes[i] = rune(b)
+		}
+		return string(runes)
+	}
+
+	// The language comes first, followed by a carriage return
+	if language && len(text) > 3 {
+		text = strings.TrimSpace(text[3:])
+	}
+	return text
+}
+
+// SendUSSD starts a USSD session with a code like *611#, or replies to the
+// network in one that's waiting for a reply. The response arrives on USSDChan.
+func (m *Modem) SendUSSD(ctx context.Context, text string) error {
+	// Drop anything left over from an earlier session
+	select {
+	case <-m.USSDChan:
+	default:
+	}
+
+	resp, err := m.Exec(ctx, Command{Text: fmt.Sprintf(`AT+CUSD=1,"%s",15`, text)})
+	if err != nil {
+		return err
+	}
+
+	// Some modems answer before the OK
+	m.HandleEvent(resp.String())
+	return nil
+}
+
+// CancelUSSD ends the USSD session in progress.
+func (m *Modem) CancelUSSD(ctx context.Context) error {
+	_, err := m.Exec(ctx, Command{Text: "AT+CUSD=2"})
+	return err
+}
+
+// IMEI asks the modem for its IMEI, which is what *#06# shows.
+func (m *Modem) IMEI(ctx context.Context) (string, error) {
+	resp, err := m.Exec(ctx, Command{Text: "AT+CGSN"})
+	if err != nil {
+		return "", err
+	}
+	imei := imeiRegex.FindString(resp.String())
+	if imei == "" {
+		return "", fmt.Errorf("unexpected IMEI: %s", resp)
+	}
+	return imei, nil
+}
+
+func (m *Modem) handleCUSD(line string) {
+	matches := cusdRegex.FindStringSubmatch(line)
+	if matches == nil {
+		return
+	}
+
+	status, _ := strconv.Atoi(matches[1])
+	dcs := 0x0F
+	if matches[3] != "" {
+		dcs, _ = strconv.Atoi(matches[3])
+	}
+
+	// Line breaks in the message were kept as carriage returns
+	message := strings.ReplaceAll(matches[2], "\r", "\n")
+	ussd := &USSD{Status: status, Message: decodeUSSD(message, dcs)}
+
+	if m.DebugMode {
+		log.Printf("📨 USSD (%d): %q", ussd.Status, ussd.Message)
+	}
+
+	select {
+	case m.USSDChan <- ussd:
+	default:
+		log.Println("⚠️ USSD channel full, dropping message")
+	}
+}
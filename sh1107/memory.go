@@ -0,0 +1,144 @@
+package sh1107
+
+import (
+	"image"
+	"image/color"
+	"sync"
+)
+
+// MemoryDisplay is an in-memory stand-in for the SH1107 panel. It decodes the
+// command stream written by SH1107 and keeps its own copy of the display RAM,
+// so the UI can run (and be inspected) without an I2C bus.
+type MemoryDisplay struct {
+	mu       sync.Mutex
+	width    int
+	pages    int
+	ram      []byte
+	page     int
+	column   int
+	on       bool
+	contrast byte
+	pending  byte // Two-byte command waiting for its argument
+	frames   int
+}
+
+// Creates a new in-memory panel with the given dimensions
+func NewMemoryDisplay(width, height int) *MemoryDisplay {
+	return &MemoryDisplay{
+		width:    width,
+		pages:    height / 8,
+		ram:      make([]byte, width*(height/8)),
+		contrast: 0x7F,
+	}
+}
+
+// Commands that take a single argument byte after the opcode
+var two_byte_commands = map[byte]bool{
+	0x81: true, // contrast
+	0xA8: true, // multiplex ratio
+	0xAD: true, // charge pump
+	0xD3: true, // display offset
+	0xD5: true, // oscillator
+	0xD9: true, // precharge
+	0xDB: true, // vcomh
+	0xDC: true, // display start line
+}
+
+func (d *MemoryDisplay) WriteBytes(buf []byte) (int, error) {
+	if len(buf) == 0 {
+		return 0, nil
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	switch buf[0] {
+	case 0x00:
+		for _, b := range buf[1:] {
+			d.command(b)
+		}
+	case 0x40:
+		for _, b := range buf[1:] {
+			if d.page < d.pages && d.column < d.width {
+				d.ram[d.page*d.width+d.column] = b
+			}
+			d.column++
+		}
+
+		// A frame is complete once the last page has been filled
+		if d.page == d.pages-1 && d.column >= d.width {
+			d.frames++
+		}
+	}
+
+	return len(buf), nil
+}
+
+func (d *MemoryDisplay) command(b byte) {
+	if d.pending != 0 {
+		if d.pending == 0x81 {
+			d.contrast = b
+		}
+		d.pending = 0
+		return
+	}
+
+	switch {
+	case two_byte_commands[b]:
+		d.pending = b
+	case b == 0xAE:
+		d.on = false
+	case b == 0xAF:
+		d.on = true
+	case b&0xF0 == 0xB0:
+		d.page = int(b & 0x0F)
+	case b <= 0x0F:
+		d.column = (d.column & 0xF0) | int(b)
+	case b >= 0x10 && b <= 0x17:
+		d.column = (d.column & 0x0F) | int(b&0x07)<<4
+	}
+}
+
+func (d *MemoryDisplay) Close() error {
+	return nil
+}
+
+// Returns whether the panel has been switched on
+func (d *MemoryDisplay) IsOn() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.on
+}
+
+// Returns how many complete frames have been written to the panel
+func (d *MemoryDisplay) Frames() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.frames
+}
+
+// Returns the last contrast value written to the panel
+func (d *MemoryDisplay) Contrast() byte {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.contrast
+}
+
+// Decodes the display RAM into an image of what the panel is showing
+func (d *MemoryDisplay) Frame() *image.Gray {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	frame := image.NewGray(image.Rect(0, 0, d.width, d.pages*8))
+	for page := range d.pages {
+		for x := range d.width {
+			b := d.ram[page*d.width+x]
+			for bit := range 8 {
+				if b&(1<<bit) != 0 {
+					frame.SetGray(x, page*8+bit, color.Gray{Y: 255})
+				}
+			}
+		}
+	}
+	return frame
+}
@@ -24,9 +24,17 @@ var sprite_fs embed.FS
 var Black color.Color = color.Gray{Y: 0}
 var White color.Color = color.Gray{Y: 255}
 
+// Display is the panel an SH1107 framebuffer is flushed to. Each write is a
+// control byte (0x00 for commands, 0x40 for display data) followed by its
+// payload, exactly as it would be sent over I2C.
+type Display interface {
+	WriteBytes(buf []byte) (int, error)
+	Close() error
+}
+
 type SH1107 struct {
 	*gg.Context   // Embed drawing context
-	bus           *i2c.I2C
+	bus           Display
 	rot           int
 	Width, Height int
 	IsOn          bool
@@ -53,14 +61,19 @@ const (
 	AlignCenter int = 5
 )
 
-// Creates a new SH1107 display connection
-func New(address byte, bus_device int, rotation int, width, height int) *SH1107 {
+// Creates a new SH1107 display connection on the given I2C bus
+func New(address byte, bus_device int, rotation int, width, height int) (*SH1107, error) {
 	logger.ChangePackageLogLevel("i2c", logger.PanicLevel)
 	bus, err := i2c.NewI2C(address, bus_device)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
+	return NewWithDisplay(bus, rotation, width, height), nil
+}
+
+// Creates a new SH1107 framebuffer that renders to the given panel
+func NewWithDisplay(bus Display, rotation int, width, height int) *SH1107 {
 	display := &SH1107{
 		gg.NewContextForImage(image.NewGray(image.Rect(0, 0, width, height))),
 		bus,
@@ -0,0 +1,34 @@
+package sh1107
+
+import (
+	"image"
+)
+
+// VirtualDisplay is a MemoryDisplay that hands every completed frame to
+// OnFrame, along with whether the panel is switched on. The desktop emulator
+// uses it to mirror the screen.
+type VirtualDisplay struct {
+	*MemoryDisplay
+	OnFrame func(frame *image.Gray, on bool)
+}
+
+// Creates a new virtual panel with the given dimensions
+func NewVirtualDisplay(width, height int, onFrame func(frame *image.Gray, on bool)) *VirtualDisplay {
+	return &VirtualDisplay{
+		MemoryDisplay: NewMemoryDisplay(width, height),
+		OnFrame:       onFrame,
+	}
+}
+
+func (d *VirtualDisplay) WriteBytes(buf []byte) (int, error) {
+	frames, on := d.Frames(), d.IsOn()
+
+	n, err := d.MemoryDisplay.WriteBytes(buf)
+
+	// Redraw after each full frame, and whenever the panel is switched on or off
+	if d.OnFrame != nil && (d.Frames() != frames || d.IsOn() != on) {
+		d.OnFrame(d.Frame(), d.IsOn())
+	}
+
+	return n, err
+}
@@ -0,0 +1,46 @@
+package tones
+
+import (
+	"context"
+	"time"
+)
+
+// Silent is a TonePlayer that makes no sound. Play and Vibrate still take as
+// long as the real thing, so ringtone loops pace themselves the same way.
+type Silent struct{}
+
+func NewSilent() *Silent {
+	return &Silent{}
+}
+
+func (*Silent) Tone(note int, divider uint8) {}
+func (*Silent) Stop()                        {}
+func (*Silent) StartVibrate()                {}
+func (*Silent) StopVibrate()                 {}
+
+func (*Silent) Play(ctx context.Context, notes []Note) {
+	for _, n := range notes {
+		if !wait(ctx, n.Duration) {
+			return
+		}
+	}
+}
+
+func (*Silent) Vibrate(ctx context.Context, states []Vibrate) {
+	for _, n := range states {
+		if !wait(ctx, n.Duration) {
+			return
+		}
+	}
+}
+
+func wait(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
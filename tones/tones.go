@@ -2,6 +2,7 @@ package tones
 
 import (
 	"context"
+	"errors"
 	"math"
 	"time"
 
@@ -11,6 +12,17 @@ import (
 	"periph.io/x/host/v3"
 )
 
+// TonePlayer drives the buzzer and vibrator. Tones is the PWM implementation
+// used on the device; Silent stands in for it when there is no hardware.
+type TonePlayer interface {
+	Tone(note int, divider uint8)
+	Stop()
+	Play(ctx context.Context, notes []Note)
+	StartVibrate()
+	StopVibrate()
+	Vibrate(ctx context.Context, states []Vibrate)
+}
+
 type Tones struct {
 	pout     gpio.PinOut
 	vibrator gpio.PinOut
@@ -27,19 +39,19 @@ type Vibrate struct {
 	Duration time.Duration
 }
 
-func New() *Tones {
+func New() (*Tones, error) {
 	if _, err := host.Init(); err != nil {
-		panic(err)
+		return nil, err
 	}
 
 	p := gpioreg.ByName("GPIO13")
 	if p == nil {
-		panic(" Failed to find tone pin!")
+		return nil, errors.New("failed to find tone pin")
 	}
 
 	pout, ok := p.(gpio.PinOut)
 	if !ok {
-		panic(" Tone pin does not support PWM!")
+		return nil, errors.New("tone pin does not support PWM")
 	}
 
 	/* vibrator_pin := gpioreg.ByName("GPIO12")
@@ -54,7 +66,7 @@ func New() *Tones {
 	return &Tones{
 		pout: pout,
 		// vibrator: vibrator_pin,
-	}
+	}, nil
 }
 
 func (t *Tones) starttone(freq physic.Frequency, divider uint8) {